@@ -0,0 +1,479 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/26
+	@note: 在客户端对记录流做分组聚合：COUNT、COUNT DISTINCT、SUM、MIN、MAX、AVG
+
+*
+*/
+package aggregate
+
+import (
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"strconv"
+	"strings"
+	"test/iterator"
+)
+
+// Func 聚合函数
+type Func int
+
+const (
+	Count         Func = iota // 非 null 值的个数，Column 为空时为行数
+	CountDistinct             // 不同的非 null 值的个数
+	Sum                       // 整数求和为 int64/uint64，浮点数为 float64，decimal 精确求和且保留列的 scale
+	Min                       // 最小值，类型与列相同
+	Max                       // 最大值，类型与列相同
+	Avg                       // 平均值，整数和浮点数为 float64，decimal 精确计算并多保留 AvgExtraScale 位小数
+)
+
+// AvgExtraScale decimal 列求平均值时在列的 scale 之外多保留的小数位数
+const AvgExtraScale = 4
+
+var funcNames = map[Func]string{Count: "count", CountDistinct: "count distinct", Sum: "sum", Min: "min", Max: "max", Avg: "avg"}
+
+func (f Func) String() string {
+	if name, ok := funcNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Func(%d)", int(f))
+}
+
+// ParseFunc 解析函数名（不区分大小写），distinct 只能用于 count
+func ParseFunc(name string, distinct bool) (Func, error) {
+	switch strings.ToLower(name) {
+	case "count":
+		if distinct {
+			return CountDistinct, nil
+		}
+		return Count, nil
+	case "sum":
+		if !distinct {
+			return Sum, nil
+		}
+	case "min":
+		if !distinct {
+			return Min, nil
+		}
+	case "max":
+		if !distinct {
+			return Max, nil
+		}
+	case "avg":
+		if !distinct {
+			return Avg, nil
+		}
+	default:
+		return 0, fmt.Errorf("unknown aggregate function %s", name)
+	}
+	return 0, fmt.Errorf("DISTINCT is only supported with count, not %s", strings.ToLower(name))
+}
+
+// Spec 一个聚合列
+type Spec struct {
+	Func   Func
+	Column string // 聚合的列，只有 Count 可以为空（即 COUNT(*)）
+	Name   string // 结果列名，为空时为 sum(col)、count(*) 这样的形式
+}
+
+// ParseSpec 解析 func[:column][=name] 形式的聚合列，如 count、count:score、count_distinct:name、sum:amount=total
+func ParseSpec(text string) (Spec, error) {
+	var spec Spec
+	body, name, _ := strings.Cut(text, "=")
+	fn, column, _ := strings.Cut(body, ":")
+	spec.Column, spec.Name = strings.TrimSpace(column), strings.TrimSpace(name)
+	fn = strings.ToLower(strings.TrimSpace(fn))
+	f, err := ParseFunc(strings.TrimSuffix(fn, "_distinct"), strings.HasSuffix(fn, "_distinct"))
+	if err != nil {
+		return Spec{}, err
+	}
+	spec.Func = f
+	if spec.Column == "" && f != Count {
+		return Spec{}, fmt.Errorf("aggregate %q needs a column, e.g. %s:col", text, fn)
+	}
+	return spec, nil
+}
+
+// OutputName 返回结果列名
+func (s Spec) OutputName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	switch {
+	case s.Column == "":
+		return s.Func.String() + "(*)"
+	case s.Func == CountDistinct:
+		return "count(distinct " + s.Column + ")"
+	}
+	return s.Func.String() + "(" + s.Column + ")"
+}
+
+func (s Spec) String() string {
+	return s.OutputName()
+}
+
+// Aggregator 增量计算分组聚合：每次 Add 一批记录，Result 返回当前的结果
+//
+// 结果的列依次为分组列（类型与输入相同）和各聚合列，每个分组一行，按分组首次出现的顺序排列；
+// 没有分组列时总是只有一行。null 值不参与聚合，没有非 null 值的分组在 SUM、MIN、MAX、AVG 列为 null。
+// 分组列和聚合列的类型以第一批记录为准，之后的批次类型不同时返回错误。
+type Aggregator struct {
+	groupBy []string
+	specs   []Spec
+	mem     memory.Allocator
+
+	ready    bool
+	keyTypes []arrow.DataType
+	states   []state
+	groups   map[string]int
+	keys     [][]string // keys[列][分组]，ValueStr 的结果（已复制，不引用记录的内存）
+	keyValid [][]bool
+	n        int // 分组数
+	rows     int64
+	key      strings.Builder
+}
+
+// New 创建 Aggregator，mem 为空时使用 GoAllocator
+func New(groupBy []string, specs []Spec, mem memory.Allocator) (*Aggregator, error) {
+	if len(specs) == 0 && len(groupBy) == 0 {
+		return nil, fmt.Errorf("nothing to aggregate")
+	}
+	for _, spec := range specs {
+		if _, ok := funcNames[spec.Func]; !ok {
+			return nil, fmt.Errorf("unknown aggregate function %v", spec.Func)
+		}
+		if spec.Column == "" && spec.Func != Count {
+			return nil, fmt.Errorf("%s(*) is not supported, only count(*)", spec.Func)
+		}
+	}
+	if mem == nil {
+		mem = memory.NewGoAllocator()
+	}
+	return &Aggregator{
+		groupBy:  groupBy,
+		specs:    specs,
+		mem:      mem,
+		groups:   make(map[string]int),
+		keys:     make([][]string, len(groupBy)),
+		keyValid: make([][]bool, len(groupBy)),
+	}, nil
+}
+
+// Rows 已聚合的行数
+func (a *Aggregator) Rows() int64 {
+	return a.rows
+}
+
+// Groups 当前的分组数
+func (a *Aggregator) Groups() int {
+	return a.n
+}
+
+// Add 聚合一批记录，不持有 record
+func (a *Aggregator) Add(record arrow.Record) error {
+	keyColumns, columns, err := a.columns(record)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(record.NumRows()); i++ {
+		g := a.group(keyColumns, i)
+		for j, s := range a.states {
+			column := columns[j]
+			if column != nil && column.IsNull(i) {
+				continue
+			}
+			if err := s.add(g, column, i); err != nil {
+				return fmt.Errorf("%v: %v", a.specs[j], err)
+			}
+		}
+	}
+	a.rows += record.NumRows()
+	return nil
+}
+
+// columns 按名称取出分组列和聚合列，第一批时根据列的类型创建聚合状态
+func (a *Aggregator) columns(record arrow.Record) ([]arrow.Array, []arrow.Array, error) {
+	keyColumns := make([]arrow.Array, len(a.groupBy))
+	for i, name := range a.groupBy {
+		column, err := columnByName(record, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		keyColumns[i] = column
+	}
+	columns := make([]arrow.Array, len(a.specs))
+	for i, spec := range a.specs {
+		if spec.Column == "" {
+			continue
+		}
+		column, err := columnByName(record, spec.Column)
+		if err != nil {
+			return nil, nil, err
+		}
+		columns[i] = column
+	}
+
+	if !a.ready {
+		for _, column := range keyColumns {
+			a.keyTypes = append(a.keyTypes, column.DataType())
+		}
+		for i, spec := range a.specs {
+			var dt arrow.DataType
+			if columns[i] != nil {
+				dt = columns[i].DataType()
+			}
+			s, err := newState(spec.Func, dt)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%v: %v", spec, err)
+			}
+			for g := 0; g < a.n; g++ {
+				s.grow()
+			}
+			a.states = append(a.states, s)
+		}
+		a.ready = true
+		return keyColumns, columns, nil
+	}
+
+	for i, column := range keyColumns {
+		if !arrow.TypeEqual(column.DataType(), a.keyTypes[i]) {
+			return nil, nil, fmt.Errorf("column %s changed type from %v to %v", a.groupBy[i], a.keyTypes[i], column.DataType())
+		}
+	}
+	for i, column := range columns {
+		if column != nil && !arrow.TypeEqual(column.DataType(), a.states[i].inputType()) {
+			return nil, nil, fmt.Errorf("column %s changed type from %v to %v", a.specs[i].Column, a.states[i].inputType(), column.DataType())
+		}
+	}
+	return keyColumns, columns, nil
+}
+
+// group 返回第 i 行所在的分组，新分组时追加分组键并扩展聚合状态
+func (a *Aggregator) group(keyColumns []arrow.Array, i int) int {
+	if len(keyColumns) == 0 {
+		if a.n == 0 {
+			a.addGroup()
+		}
+		return 0
+	}
+	a.key.Reset()
+	for _, column := range keyColumns {
+		if column.IsNull(i) {
+			a.key.WriteByte('N')
+			continue
+		}
+		v := column.ValueStr(i)
+		a.key.WriteString(strconv.Itoa(len(v)))
+		a.key.WriteByte(':')
+		a.key.WriteString(v)
+	}
+	if g, ok := a.groups[a.key.String()]; ok {
+		return g
+	}
+	g := a.addGroup()
+	a.groups[a.key.String()] = g
+	for j, column := range keyColumns {
+		valid := column.IsValid(i)
+		value := ""
+		if valid {
+			value = strings.Clone(column.ValueStr(i))
+		}
+		a.keys[j] = append(a.keys[j], value)
+		a.keyValid[j] = append(a.keyValid[j], valid)
+	}
+	return g
+}
+
+func (a *Aggregator) addGroup() int {
+	for _, s := range a.states {
+		s.grow()
+	}
+	a.n++
+	return a.n - 1
+}
+
+// Result 返回当前的聚合结果，由调用方释放；之后仍可以继续 Add。
+// 一批记录都没有收到时无法确定列的类型，分组列和除 COUNT 外的聚合列类型为 null
+func (a *Aggregator) Result() (arrow.Record, error) {
+	if len(a.groupBy) == 0 && a.n == 0 {
+		a.addGroup()
+	}
+	fields := make([]arrow.Field, 0, len(a.groupBy)+len(a.specs))
+	columns := make([]arrow.Array, 0, cap(fields))
+	defer func() {
+		for _, column := range columns {
+			column.Release()
+		}
+	}()
+
+	for i, name := range a.groupBy {
+		dt := arrow.DataType(arrow.Null)
+		if a.ready {
+			dt = a.keyTypes[i]
+		}
+		column, err := a.keyColumn(i, dt)
+		if err != nil {
+			return nil, fmt.Errorf("group column %s: %v", name, err)
+		}
+		fields = append(fields, arrow.Field{Name: name, Type: dt, Nullable: true})
+		columns = append(columns, column)
+	}
+	for i, spec := range a.specs {
+		var column arrow.Array
+		var err error
+		if a.ready {
+			column, err = a.states[i].result(a.mem)
+		} else {
+			column, err = emptyResult(spec.Func, a.n, a.mem)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", spec, err)
+		}
+		fields = append(fields, arrow.Field{Name: spec.OutputName(), Type: column.DataType(), Nullable: true})
+		columns = append(columns, column)
+	}
+	return array.NewRecord(arrow.NewSchema(fields, nil), columns, int64(a.n)), nil
+}
+
+func (a *Aggregator) keyColumn(i int, dt arrow.DataType) (arrow.Array, error) {
+	b := array.NewBuilder(a.mem, dt)
+	defer b.Release()
+	for g := 0; g < a.n; g++ {
+		if !a.keyValid[i][g] {
+			b.AppendNull()
+			continue
+		}
+		if err := b.AppendValueFromString(a.keys[i][g]); err != nil {
+			return nil, err
+		}
+	}
+	return b.NewArray(), nil
+}
+
+// emptyResult 没有收到任何记录时的聚合列：COUNT 为 0，其余为 null
+func emptyResult(fn Func, n int, mem memory.Allocator) (arrow.Array, error) {
+	if fn == Count || fn == CountDistinct {
+		b := array.NewInt64Builder(mem)
+		defer b.Release()
+		for g := 0; g < n; g++ {
+			b.Append(0)
+		}
+		return b.NewArray(), nil
+	}
+	return array.NewNull(n), nil
+}
+
+// columnByName 按名称查找列，列不存在或重名时返回错误
+func columnByName(record arrow.Record, name string) (arrow.Array, error) {
+	indices := record.Schema().FieldIndices(name)
+	switch len(indices) {
+	case 0:
+		return nil, fmt.Errorf("column %s not found", name)
+	case 1:
+		return record.Column(indices[0]), nil
+	}
+	return nil, fmt.Errorf("column %s is ambiguous", name)
+}
+
+// Run 聚合 src 中的所有记录并返回结果，完成后释放 src
+func Run(src iterator.Records, groupBy []string, specs []Spec, mem memory.Allocator) (arrow.Record, error) {
+	defer src.Release()
+	agg, err := New(groupBy, specs, mem)
+	if err != nil {
+		return nil, err
+	}
+	for src.Next() {
+		if err := agg.Add(src.Record()); err != nil {
+			return nil, err
+		}
+	}
+	if err := src.Err(); err != nil {
+		return nil, err
+	}
+	return agg.Result()
+}
+
+// Reader 聚合 src 中的所有记录后输出一批结果，满足 iterator.Records，Release 时同时释放 src
+type Reader struct {
+	src    iterator.Records
+	agg    *Aggregator
+	schema *arrow.Schema
+	record arrow.Record
+	err    error
+	done   bool
+}
+
+// NewReader 创建 Reader，第一次调用 Next 时读完 src；参数有误时 Next 返回 false，错误由 Err 返回
+func NewReader(src iterator.Records, groupBy []string, specs []Spec, mem memory.Allocator) *Reader {
+	agg, err := New(groupBy, specs, mem)
+	return &Reader{src: src, agg: agg, err: err}
+}
+
+func (r *Reader) Next() bool {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	if r.done || r.err != nil {
+		return false
+	}
+	r.done = true
+	for r.src.Next() {
+		if err := r.agg.Add(r.src.Record()); err != nil {
+			r.err = err
+			return false
+		}
+	}
+	if err := r.src.Err(); err != nil {
+		r.err = err
+		return false
+	}
+	if !r.agg.ready {
+		// 所有批次都被过滤掉时按 src 的 schema 确定结果列的类型
+		if schema := iterator.SchemaOf(r.src); schema != nil {
+			empty := array.NewRecordBuilder(r.agg.mem, schema)
+			input := empty.NewRecord()
+			r.err = r.agg.Add(input)
+			input.Release()
+			empty.Release()
+			if r.err != nil {
+				return false
+			}
+		}
+	}
+	r.record, r.err = r.agg.Result()
+	if r.err != nil {
+		return false
+	}
+	r.schema = r.record.Schema()
+	return true
+}
+
+func (r *Reader) Record() arrow.Record {
+	return r.record
+}
+
+// Schema 返回聚合结果的 schema，在第一次调用 Next 之前为 nil
+func (r *Reader) Schema() *arrow.Schema {
+	return r.schema
+}
+
+func (r *Reader) Err() error {
+	return r.err
+}
+
+func (r *Reader) Release() {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	if r.src != nil {
+		r.src.Release()
+		r.src = nil
+	}
+	r.done = true
+}
@@ -0,0 +1,336 @@
+package aggregate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+)
+
+var scores = arrow.NewSchema([]arrow.Field{
+	{Name: "dept", Type: arrow.BinaryTypes.String, Nullable: true},
+	{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+	{Name: "score", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	{Name: "gpa", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	{Name: "fee", Type: &arrow.Decimal128Type{Precision: 10, Scale: 2}, Nullable: true},
+}, nil)
+
+// scoreBatches 两批测试数据，dept 为 null 的行单独成组
+var scoreBatches = []string{
+	`[{"dept": "math", "name": "Alice", "score": 90, "gpa": 3.5, "fee": "10.25"},
+	  {"dept": "math", "name": "Bob", "score": null, "gpa": 2.0, "fee": "0.10"},
+	  {"dept": "cs", "name": "Carol", "score": 70, "gpa": null, "fee": null},
+	  {"dept": null, "name": "Dave", "score": 60, "gpa": 3.0, "fee": "1.00"}]`,
+	`[{"dept": "cs", "name": "Carol", "score": 81, "gpa": 3.9, "fee": "2.50"},
+	  {"dept": "math", "name": null, "score": 50, "gpa": null, "fee": "5.00"}]`,
+}
+
+func newRecord(t *testing.T, mem memory.Allocator, schema *arrow.Schema, rows string) arrow.Record {
+	t.Helper()
+	record, _, err := array.RecordFromJSON(mem, schema, strings.NewReader(rows))
+	if err != nil {
+		t.Fatalf("RecordFromJSON: %v", err)
+	}
+	return record
+}
+
+// format 返回结果的列名以及以 | 分隔的各行，null 为 NULL，decimal 按 scale 输出
+func format(record arrow.Record) ([]string, []string) {
+	var columns, rows []string
+	for _, field := range record.Schema().Fields() {
+		columns = append(columns, field.Name)
+	}
+	for i := 0; i < int(record.NumRows()); i++ {
+		values := make([]string, record.NumCols())
+		for j, column := range record.Columns() {
+			// null 类型的列没有 validity bitmap，IsNull 总是返回 false
+			switch column := column.(type) {
+			case *array.Null:
+				values[j] = "NULL"
+			case *array.Decimal128:
+				if column.IsNull(i) {
+					values[j] = "NULL"
+				} else {
+					// ValueStr 经由浮点数格式化，不是精确值
+					values[j] = column.Value(i).ToString(column.DataType().(*arrow.Decimal128Type).Scale)
+				}
+			default:
+				if column.IsNull(i) {
+					values[j] = "NULL"
+				} else {
+					values[j] = column.ValueStr(i)
+				}
+			}
+		}
+		rows = append(rows, strings.Join(values, "|"))
+	}
+	return columns, rows
+}
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		text string
+		spec Spec
+		name string
+		err  bool
+	}{
+		{text: "count", spec: Spec{Func: Count}, name: "count(*)"},
+		{text: "COUNT:score", spec: Spec{Func: Count, Column: "score"}, name: "count(score)"},
+		{text: "count_distinct:name", spec: Spec{Func: CountDistinct, Column: "name"}, name: "count(distinct name)"},
+		{text: " sum : fee = total ", spec: Spec{Func: Sum, Column: "fee", Name: "total"}, name: "total"},
+		{text: "avg:gpa", spec: Spec{Func: Avg, Column: "gpa"}, name: "avg(gpa)"},
+		{text: "max:score=best", spec: Spec{Func: Max, Column: "score", Name: "best"}, name: "best"},
+		{text: "sum", err: true},
+		{text: "sum_distinct:score", err: true},
+		{text: "median:score", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			spec, err := ParseSpec(tt.text)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("ParseSpec = %+v, want error", spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if spec != tt.spec {
+				t.Errorf("ParseSpec = %+v, want %+v", spec, tt.spec)
+			}
+			if spec.OutputName() != tt.name {
+				t.Errorf("OutputName = %q, want %q", spec.OutputName(), tt.name)
+			}
+		})
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	tests := []struct {
+		name    string
+		groupBy []string
+		specs   string
+		columns []string
+		rows    []string
+	}{
+		{
+			name:    "count ignores nulls",
+			specs:   "count,count:score,count:name,count_distinct:dept",
+			columns: []string{"count(*)", "count(score)", "count(name)", "count(distinct dept)"},
+			rows:    []string{"6|5|5|2"},
+		},
+		{
+			name:    "numeric functions",
+			specs:   "sum:score,min:score,max:score,avg:score,sum:gpa,avg:gpa",
+			columns: []string{"sum(score)", "min(score)", "max(score)", "avg(score)", "sum(gpa)", "avg(gpa)"},
+			rows:    []string{"351|50|90|70.2|12.4|3.1"},
+		},
+		{
+			name:    "strings are ordered",
+			specs:   "min:name,max:name",
+			columns: []string{"min(name)", "max(name)"},
+			rows:    []string{"Alice|Dave"},
+		},
+		{
+			name:    "groups in order of first appearance with a null group",
+			groupBy: []string{"dept"},
+			specs:   "count=n,sum:score=total,max:gpa",
+			columns: []string{"dept", "n", "total", "max(gpa)"},
+			rows:    []string{"math|3|140|3.5", "cs|2|151|3.9", "NULL|1|60|3"},
+		},
+		{
+			name:    "group with only nulls",
+			groupBy: []string{"dept", "name"},
+			specs:   "sum:score,avg:gpa,count:score",
+			columns: []string{"dept", "name", "sum(score)", "avg(gpa)", "count(score)"},
+			rows:    []string{"math|Alice|90|3.5|1", "math|Bob|NULL|2|0", "cs|Carol|151|3.9|2", "NULL|Dave|60|3|1", "math|NULL|50|NULL|1"},
+		},
+		{
+			name:    "group without aggregates",
+			groupBy: []string{"dept"},
+			columns: []string{"dept"},
+			rows:    []string{"math", "cs", "NULL"},
+		},
+		{
+			name:    "decimal sum keeps the scale",
+			groupBy: []string{"dept"},
+			specs:   "sum:fee,avg:fee,min:fee",
+			columns: []string{"dept", "sum(fee)", "avg(fee)", "min(fee)"},
+			rows:    []string{"math|15.35|5.116667|0.10", "cs|2.50|2.500000|2.50", "NULL|1.00|1.000000|1.00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+			defer mem.AssertSize(t, 0)
+			var specs []Spec
+			if tt.specs != "" {
+				for _, text := range strings.Split(tt.specs, ",") {
+					spec, err := ParseSpec(text)
+					if err != nil {
+						t.Fatal(err)
+					}
+					specs = append(specs, spec)
+				}
+			}
+			agg, err := New(tt.groupBy, specs, mem)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, rows := range scoreBatches {
+				record := newRecord(t, mem, scores, rows)
+				err := agg.Add(record)
+				record.Release()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			result, err := agg.Result()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer result.Release()
+			columns, rows := format(result)
+			if !reflect.DeepEqual(columns, tt.columns) {
+				t.Errorf("columns = %q, want %q", columns, tt.columns)
+			}
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("rows = %q, want %q", rows, tt.rows)
+			}
+			if agg.Rows() != 6 {
+				t.Errorf("Rows = %d, want 6", agg.Rows())
+			}
+		})
+	}
+}
+
+func TestResultEmpty(t *testing.T) {
+	specs := []Spec{{Func: Count}, {Func: CountDistinct, Column: "name"}, {Func: Sum, Column: "score"}, {Func: Max, Column: "name"}}
+	tests := []struct {
+		name    string
+		groupBy []string
+		add     bool // 是否 Add 一批没有行的记录
+		columns []string
+		rows    []string
+		types   []arrow.DataType
+	}{
+		{
+			name:    "no input",
+			columns: []string{"count(*)", "count(distinct name)", "sum(score)", "max(name)"},
+			rows:    []string{"0|0|NULL|NULL"},
+			types:   []arrow.DataType{arrow.PrimitiveTypes.Int64, arrow.PrimitiveTypes.Int64, arrow.Null, arrow.Null},
+		},
+		{
+			name:    "no input with group by",
+			groupBy: []string{"dept"},
+			columns: []string{"dept", "count(*)", "count(distinct name)", "sum(score)", "max(name)"},
+			types:   []arrow.DataType{arrow.Null, arrow.PrimitiveTypes.Int64, arrow.PrimitiveTypes.Int64, arrow.Null, arrow.Null},
+		},
+		{
+			name:    "empty batch",
+			add:     true,
+			columns: []string{"count(*)", "count(distinct name)", "sum(score)", "max(name)"},
+			rows:    []string{"0|0|NULL|NULL"},
+			types:   []arrow.DataType{arrow.PrimitiveTypes.Int64, arrow.PrimitiveTypes.Int64, arrow.PrimitiveTypes.Int64, arrow.BinaryTypes.String},
+		},
+		{
+			name:    "empty batch with group by",
+			groupBy: []string{"dept"},
+			add:     true,
+			columns: []string{"dept", "count(*)", "count(distinct name)", "sum(score)", "max(name)"},
+			types:   []arrow.DataType{arrow.BinaryTypes.String, arrow.PrimitiveTypes.Int64, arrow.PrimitiveTypes.Int64, arrow.PrimitiveTypes.Int64, arrow.BinaryTypes.String},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+			defer mem.AssertSize(t, 0)
+			agg, err := New(tt.groupBy, specs, mem)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.add {
+				record := newRecord(t, mem, scores, "[]")
+				err := agg.Add(record)
+				record.Release()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			result, err := agg.Result()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer result.Release()
+			columns, rows := format(result)
+			if !reflect.DeepEqual(columns, tt.columns) {
+				t.Errorf("columns = %q, want %q", columns, tt.columns)
+			}
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("rows = %q, want %q", rows, tt.rows)
+			}
+			for i, field := range result.Schema().Fields() {
+				if !arrow.TypeEqual(field.Type, tt.types[i]) {
+					t.Errorf("column %s has type %v, want %v", field.Name, field.Type, tt.types[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAggregatorErrors(t *testing.T) {
+	if _, err := New(nil, nil, nil); err == nil {
+		t.Error("New without group columns or aggregates: want error")
+	}
+	if _, err := New(nil, []Spec{{Func: Sum}}, nil); err == nil {
+		t.Error("New with sum(*): want error")
+	}
+
+	tests := []struct {
+		name  string
+		specs []Spec
+		err   string
+	}{
+		{"missing column", []Spec{{Func: Sum, Column: "missing"}}, "column missing not found"},
+		{"sum of strings", []Spec{{Func: Sum, Column: "name"}}, "cannot sum"},
+		{"avg of strings", []Spec{{Func: Avg, Column: "dept"}}, "cannot avg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg, err := New(nil, tt.specs, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			record := newRecord(t, memory.DefaultAllocator, scores, scoreBatches[0])
+			defer record.Release()
+			if err := agg.Add(record); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Add error = %v, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+
+	t.Run("type change", func(t *testing.T) {
+		agg, err := New([]string{"dept"}, []Spec{{Func: Sum, Column: "score"}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first := newRecord(t, memory.DefaultAllocator, scores, scoreBatches[0])
+		defer first.Release()
+		if err := agg.Add(first); err != nil {
+			t.Fatal(err)
+		}
+		changed := arrow.NewSchema([]arrow.Field{
+			{Name: "dept", Type: arrow.BinaryTypes.String},
+			{Name: "score", Type: arrow.PrimitiveTypes.Float64},
+		}, nil)
+		second := newRecord(t, memory.DefaultAllocator, changed, `[{"dept": "cs", "score": 1.5}]`)
+		defer second.Release()
+		if err := agg.Add(second); err == nil || !strings.Contains(err.Error(), "changed type") {
+			t.Errorf("Add error = %v, want a type change error", err)
+		}
+	})
+}
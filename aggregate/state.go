@@ -0,0 +1,457 @@
+package aggregate
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/decimal128"
+	"github.com/apache/arrow/go/v15/arrow/decimal256"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/shopspring/decimal"
+	"math"
+	"strings"
+)
+
+// state 一个聚合列在各分组上的状态，分组编号从 0 开始连续分配
+type state interface {
+	inputType() arrow.DataType
+	grow()                                      // 追加一个分组
+	add(g int, column arrow.Array, i int) error // 累加第 i 行，null 已被调用方跳过；COUNT(*) 时 column 为 nil
+	result(mem memory.Allocator) (arrow.Array, error)
+}
+
+// newState 根据函数和列的类型创建聚合状态
+func newState(fn Func, dt arrow.DataType) (state, error) {
+	switch fn {
+	case Count:
+		return &countState{dt: dt}, nil
+	case CountDistinct:
+		return &distinctState{dt: dt}, nil
+	case Sum, Avg:
+		kind := numberKind(dt)
+		if kind == notNumber {
+			return nil, fmt.Errorf("cannot %s column of type %v", fn, dt)
+		}
+		return &numberState{dt: dt, kind: kind, avg: fn == Avg}, nil
+	case Min, Max:
+		if !ordered(dt) {
+			return nil, fmt.Errorf("cannot compute %s of type %v", fn, dt)
+		}
+		return &extremeState{dt: dt, max: fn == Max}, nil
+	}
+	return nil, fmt.Errorf("unknown aggregate function %v", fn)
+}
+
+// countState COUNT 和 COUNT(*)
+type countState struct {
+	dt     arrow.DataType
+	counts []int64
+}
+
+func (s *countState) inputType() arrow.DataType { return s.dt }
+
+func (s *countState) grow() { s.counts = append(s.counts, 0) }
+
+func (s *countState) add(g int, _ arrow.Array, _ int) error {
+	s.counts[g]++
+	return nil
+}
+
+func (s *countState) result(mem memory.Allocator) (arrow.Array, error) {
+	b := array.NewInt64Builder(mem)
+	defer b.Release()
+	b.AppendValues(s.counts, nil)
+	return b.NewArray(), nil
+}
+
+// distinctState COUNT(DISTINCT col)，按值的字符串形式去重
+type distinctState struct {
+	dt   arrow.DataType
+	seen []map[string]struct{}
+}
+
+func (s *distinctState) inputType() arrow.DataType { return s.dt }
+
+func (s *distinctState) grow() { s.seen = append(s.seen, make(map[string]struct{})) }
+
+func (s *distinctState) add(g int, column arrow.Array, i int) error {
+	v := column.ValueStr(i)
+	if _, ok := s.seen[g][v]; !ok {
+		// 字符串列的 ValueStr 引用记录的内存，记录释放后不能再使用
+		s.seen[g][strings.Clone(v)] = struct{}{}
+	}
+	return nil
+}
+
+func (s *distinctState) result(mem memory.Allocator) (arrow.Array, error) {
+	b := array.NewInt64Builder(mem)
+	defer b.Release()
+	for _, seen := range s.seen {
+		b.Append(int64(len(seen)))
+	}
+	return b.NewArray(), nil
+}
+
+type kind int
+
+const (
+	notNumber kind = iota
+	signedKind
+	unsignedKind
+	floatKind
+	decimalKind
+)
+
+func numberKind(dt arrow.DataType) kind {
+	switch dt.ID() {
+	case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64:
+		return signedKind
+	case arrow.UINT8, arrow.UINT16, arrow.UINT32, arrow.UINT64:
+		return unsignedKind
+	case arrow.FLOAT16, arrow.FLOAT32, arrow.FLOAT64:
+		return floatKind
+	case arrow.DECIMAL128, arrow.DECIMAL256:
+		return decimalKind
+	}
+	return notNumber
+}
+
+// numberState SUM 和 AVG：整数按 int64/uint64 累加并检查溢出，浮点数按 float64 累加，decimal 用 decimal.Decimal 精确累加
+type numberState struct {
+	dt     arrow.DataType
+	kind   kind
+	avg    bool
+	counts []int64
+	ints   []int64
+	uints  []uint64
+	floats []float64
+	decs   []decimal.Decimal
+}
+
+func (s *numberState) inputType() arrow.DataType { return s.dt }
+
+func (s *numberState) grow() {
+	s.counts = append(s.counts, 0)
+	switch s.kind {
+	case signedKind:
+		s.ints = append(s.ints, 0)
+	case unsignedKind:
+		s.uints = append(s.uints, 0)
+	case floatKind:
+		s.floats = append(s.floats, 0)
+	case decimalKind:
+		s.decs = append(s.decs, decimal.Zero)
+	}
+}
+
+func (s *numberState) add(g int, column arrow.Array, i int) error {
+	s.counts[g]++
+	switch s.kind {
+	case signedKind:
+		v := signedValue(column, i)
+		sum := s.ints[g] + v
+		if (v > 0 && sum < s.ints[g]) || (v < 0 && sum > s.ints[g]) {
+			return fmt.Errorf("sum overflows int64")
+		}
+		s.ints[g] = sum
+	case unsignedKind:
+		v := unsignedValue(column, i)
+		if s.uints[g] > math.MaxUint64-v {
+			return fmt.Errorf("sum overflows uint64")
+		}
+		s.uints[g] += v
+	case floatKind:
+		s.floats[g] += floatValue(column, i)
+	case decimalKind:
+		s.decs[g] = s.decs[g].Add(decimalValue(column, i))
+	}
+	return nil
+}
+
+func (s *numberState) result(mem memory.Allocator) (arrow.Array, error) {
+	if s.kind == decimalKind {
+		return s.decimalResult(mem)
+	}
+	if s.avg {
+		b := array.NewFloat64Builder(mem)
+		defer b.Release()
+		for g, n := range s.counts {
+			if n == 0 {
+				b.AppendNull()
+				continue
+			}
+			switch s.kind {
+			case signedKind:
+				b.Append(float64(s.ints[g]) / float64(n))
+			case unsignedKind:
+				b.Append(float64(s.uints[g]) / float64(n))
+			default:
+				b.Append(s.floats[g] / float64(n))
+			}
+		}
+		return b.NewArray(), nil
+	}
+
+	switch s.kind {
+	case signedKind:
+		b := array.NewInt64Builder(mem)
+		defer b.Release()
+		b.AppendValues(s.ints, s.valid())
+		return b.NewArray(), nil
+	case unsignedKind:
+		b := array.NewUint64Builder(mem)
+		defer b.Release()
+		b.AppendValues(s.uints, s.valid())
+		return b.NewArray(), nil
+	default:
+		b := array.NewFloat64Builder(mem)
+		defer b.Release()
+		b.AppendValues(s.floats, s.valid())
+		return b.NewArray(), nil
+	}
+}
+
+// decimalResult SUM 保留列的 scale，AVG 多保留 AvgExtraScale 位并四舍五入；
+// decimal128 列的结果为 decimal(38, scale)，decimal256 列为 decimal(76, scale)，超出精度时返回错误
+func (s *numberState) decimalResult(mem memory.Allocator) (arrow.Array, error) {
+	var scale int32
+	wide := false
+	switch t := s.dt.(type) {
+	case *arrow.Decimal128Type:
+		scale = t.Scale
+	case *arrow.Decimal256Type:
+		scale, wide = t.Scale, true
+	}
+	precision := int32(38)
+	if wide {
+		precision = 76
+	}
+	if s.avg {
+		scale = min(scale+AvgExtraScale, precision)
+	}
+
+	var b array.Builder
+	if wide {
+		b = array.NewDecimal256Builder(mem, &arrow.Decimal256Type{Precision: precision, Scale: scale})
+	} else {
+		b = array.NewDecimal128Builder(mem, &arrow.Decimal128Type{Precision: precision, Scale: scale})
+	}
+	defer b.Release()
+	for g, n := range s.counts {
+		if n == 0 {
+			b.AppendNull()
+			continue
+		}
+		v := s.decs[g]
+		if s.avg {
+			v = v.DivRound(decimal.NewFromInt(n), scale)
+		}
+		unscaled := v.Shift(scale).BigInt()
+		if unscaled.BitLen() >= 255 || (!wide && unscaled.BitLen() >= 127) {
+			return nil, fmt.Errorf("%v does not fit in decimal(%d, %d)", v, precision, scale)
+		}
+		switch b := b.(type) {
+		case *array.Decimal128Builder:
+			num := decimal128.FromBigInt(unscaled)
+			if !num.FitsInPrecision(precision) {
+				return nil, fmt.Errorf("%v does not fit in decimal(%d, %d)", v, precision, scale)
+			}
+			b.Append(num)
+		case *array.Decimal256Builder:
+			num := decimal256.FromBigInt(unscaled)
+			if !num.FitsInPrecision(precision) {
+				return nil, fmt.Errorf("%v does not fit in decimal(%d, %d)", v, precision, scale)
+			}
+			b.Append(num)
+		}
+	}
+	return b.NewArray(), nil
+}
+
+func (s *numberState) valid() []bool {
+	valid := make([]bool, len(s.counts))
+	for g, n := range s.counts {
+		valid[g] = n > 0
+	}
+	return valid
+}
+
+// extremeState MIN 和 MAX，结果通过 ValueStr/AppendValueFromString 还原为列的原始类型
+type extremeState struct {
+	dt    arrow.DataType
+	max   bool
+	best  []any
+	texts []string
+}
+
+func (s *extremeState) inputType() arrow.DataType { return s.dt }
+
+func (s *extremeState) grow() {
+	s.best = append(s.best, nil)
+	s.texts = append(s.texts, "")
+}
+
+func (s *extremeState) add(g int, column arrow.Array, i int) error {
+	v := orderedValue(column, i)
+	if s.best[g] != nil {
+		c := compare(v, s.best[g])
+		if (s.max && c <= 0) || (!s.max && c >= 0) {
+			return nil
+		}
+	}
+	s.best[g] = v
+	s.texts[g] = strings.Clone(column.ValueStr(i))
+	return nil
+}
+
+func (s *extremeState) result(mem memory.Allocator) (arrow.Array, error) {
+	b := array.NewBuilder(mem, s.dt)
+	defer b.Release()
+	for g, best := range s.best {
+		if best == nil {
+			b.AppendNull()
+			continue
+		}
+		if err := b.AppendValueFromString(s.texts[g]); err != nil {
+			return nil, err
+		}
+	}
+	return b.NewArray(), nil
+}
+
+// ordered 是否可以比较大小
+func ordered(dt arrow.DataType) bool {
+	if numberKind(dt) != notNumber {
+		return true
+	}
+	switch dt.ID() {
+	case arrow.BOOL, arrow.STRING, arrow.LARGE_STRING, arrow.BINARY, arrow.LARGE_BINARY, arrow.FIXED_SIZE_BINARY,
+		arrow.DATE32, arrow.DATE64, arrow.TIME32, arrow.TIME64, arrow.TIMESTAMP, arrow.DURATION:
+		return true
+	}
+	return false
+}
+
+// orderedValue 返回用于比较的值：int64、uint64、float64、decimal.Decimal、string、[]byte 或 bool，
+// 日期和时间使用底层的整数；字符串和二进制会复制一份，不引用记录的内存
+func orderedValue(column arrow.Array, i int) any {
+	switch numberKind(column.DataType()) {
+	case signedKind:
+		return signedValue(column, i)
+	case unsignedKind:
+		return unsignedValue(column, i)
+	case floatKind:
+		return floatValue(column, i)
+	case decimalKind:
+		return decimalValue(column, i)
+	}
+	switch c := column.(type) {
+	case *array.Boolean:
+		return c.Value(i)
+	case *array.String:
+		return strings.Clone(c.Value(i))
+	case *array.LargeString:
+		return strings.Clone(c.Value(i))
+	case *array.Binary:
+		return bytes.Clone(c.Value(i))
+	case *array.LargeBinary:
+		return bytes.Clone(c.Value(i))
+	case *array.FixedSizeBinary:
+		return bytes.Clone(c.Value(i))
+	case *array.Date32:
+		return int64(c.Value(i))
+	case *array.Date64:
+		return int64(c.Value(i))
+	case *array.Time32:
+		return int64(c.Value(i))
+	case *array.Time64:
+		return int64(c.Value(i))
+	case *array.Timestamp:
+		return int64(c.Value(i))
+	case *array.Duration:
+		return int64(c.Value(i))
+	}
+	return nil
+}
+
+// compare 比较两个 orderedValue，二者的类型相同
+func compare(a, b any) int {
+	switch a := a.(type) {
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case uint64:
+		return cmp.Compare(a, b.(uint64))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case decimal.Decimal:
+		return a.Cmp(b.(decimal.Decimal))
+	case string:
+		return cmp.Compare(a, b.(string))
+	case []byte:
+		return bytes.Compare(a, b.([]byte))
+	case bool:
+		switch {
+		case a == b.(bool):
+			return 0
+		case a:
+			return 1
+		}
+		return -1
+	}
+	return 0
+}
+
+func signedValue(column arrow.Array, i int) int64 {
+	switch c := column.(type) {
+	case *array.Int8:
+		return int64(c.Value(i))
+	case *array.Int16:
+		return int64(c.Value(i))
+	case *array.Int32:
+		return int64(c.Value(i))
+	case *array.Int64:
+		return c.Value(i)
+	}
+	return 0
+}
+
+func unsignedValue(column arrow.Array, i int) uint64 {
+	switch c := column.(type) {
+	case *array.Uint8:
+		return uint64(c.Value(i))
+	case *array.Uint16:
+		return uint64(c.Value(i))
+	case *array.Uint32:
+		return uint64(c.Value(i))
+	case *array.Uint64:
+		return c.Value(i)
+	}
+	return 0
+}
+
+func floatValue(column arrow.Array, i int) float64 {
+	switch c := column.(type) {
+	case *array.Float16:
+		return float64(c.Value(i).Float32())
+	case *array.Float32:
+		return float64(c.Value(i))
+	case *array.Float64:
+		return c.Value(i)
+	}
+	return 0
+}
+
+// decimalValue 与 utils.ValueAt 相同，按列的 scale 还原为 decimal.Decimal
+func decimalValue(column arrow.Array, i int) decimal.Decimal {
+	switch c := column.(type) {
+	case *array.Decimal128:
+		scale := c.DataType().(*arrow.Decimal128Type).Scale
+		return decimal.NewFromBigInt(c.Value(i).BigInt(), -scale)
+	case *array.Decimal256:
+		scale := c.DataType().(*arrow.Decimal256Type).Scale
+		return decimal.NewFromBigInt(c.Value(i).BigInt(), -scale)
+	}
+	return decimal.Zero
+}
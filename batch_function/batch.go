@@ -10,30 +10,41 @@
 package main
 
 import (
-	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
 	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"test/config"
+	"test/job"
 	"time"
 )
 
 func main() {
-	ctx := context.Background()
-
 	// 记录开始时间
 	startTime := time.Now()
 
-	// 创建一个ServerInfo实例
-	serverInfo := &pb.ServerInfo{
-		//Namespace:   "mira1", // 如果在Kubernetes集群中使用，可以指定命名空间
-		ServiceName: "192.168.40.243",
-		ServicePort: "30015",
+	// 解析连接参数，通过 --profile 切换环境
+	opts := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	if err := run(context.Background(), opts); err != nil {
+		log.Fatal(err)
 	}
 
-	dataServiceClient, err := client.NewDataServiceClient(ctx, serverInfo)
+	// 记录结束时间
+	endTime := time.Now()
+
+	// 计算总时间
+	totalTime := endTime.Sub(startTime)
+	log.Printf("Total execution time: %v", totalTime)
+}
+
+// run 提交并等待作业，出错时返回而不是直接退出，保证 guard.Stop 等延迟调用能够执行
+func run(ctx context.Context, opts *config.Options) error {
+	dataServiceClient, err := config.NewClient(ctx, opts)
 	if err != nil {
-		log.Fatalf("failed to initialize DataServiceClient: %v", err)
+		return fmt.Errorf("failed to initialize DataServiceClient: %v", err)
 	}
 
 	// 创建 SparkConfig 实例
@@ -71,33 +82,36 @@ func main() {
 		TargetTable:   "kjhrdwwf",
 	}
 
+	// 捕获 Ctrl-C/SIGTERM，中断时把 JobId 和请求记录到本地状态文件，避免作业无人管理
+	guard := job.NewGuard(ctx, job.NewStore(""))
+	defer guard.Stop()
+
 	// 1. 提交作业
 	resp, err := dataServiceClient.SubmitBatchJob(ctx, request)
 	if err != nil {
-		log.Fatalf("提交作业失败: %v", err)
+		return fmt.Errorf("提交作业失败: %v", err)
 	}
-	fmt.Println("作业已提交，初始状态：", resp.Status, "JobId:", request.RequestId)
-
-	// 2. 轮询查询作业状态
-	for {
-		time.Sleep(5 * time.Second)
-		statusResp, err := dataServiceClient.GetJobStatus(ctx, resp.JobId)
-		if err != nil {
-			log.Printf("查询作业状态失败: %v", err)
-			continue
-		}
-		fmt.Println("当前作业状态：", statusResp.Status)
-		if statusResp.Status == pb.JobStatus_JOB_STATUS_SUCCEEDED || statusResp.Status == pb.JobStatus_JOB_STATUS_FAILED {
-			fmt.Println("作业完成，最终状态：", statusResp.Status)
-			fmt.Println(statusResp)
-			break
-		}
+	if err := guard.Track(resp.JobId, request); err != nil {
+		log.Printf("Failed to save job state: %v", err)
 	}
-	// 记录结束时间
-	endTime := time.Now()
-
-	// 计算总时间
-	totalTime := endTime.Sub(startTime)
-	log.Printf("Total execution time: %v", totalTime)
+	fmt.Println("作业已提交，初始状态：", resp.Status, "JobId:", resp.JobId)
 
+	// 2. 轮询查询作业状态，状态不变时逐步拉长查询间隔
+	waitOpts := &job.WaitOptions{
+		InitialInterval: 5 * time.Second,
+		MaxInterval:     time.Minute,
+		Timeout:         2 * time.Hour,
+		OnTransition: func(t job.Transition) {
+			fmt.Println("当前作业状态：", t.To, "已等待：", t.Elapsed.Round(time.Second))
+		},
+	}
+	status, err := job.WaitForJob(guard.Context(), dataServiceClient, resp.JobId, waitOpts)
+	if err := guard.Done(status, err); err != nil {
+		log.Printf("Failed to update job state: %v", err)
+	}
+	if err != nil {
+		return fmt.Errorf("作业未成功完成: %v", err)
+	}
+	fmt.Println("作业完成，最终状态：", status)
+	return nil
 }
@@ -1,26 +1,44 @@
 package main
 
 import (
-	"bytes"
 	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
 	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
 	"context"
-	"github.com/apache/arrow/go/v15/arrow/ipc"
-	"io"
+	"flag"
+	"fmt"
 	"log"
+	"runtime"
+	"test/config"
+	"test/iterator"
+	"test/oss"
+	"test/pipeline"
+	"test/rebatch"
+	"test/utils"
 )
 
 func main() {
 	ctx := context.Background()
 
-	// 创建一个ServerInfo实例
-	serverInfo := &pb.ServerInfo{
-		//Namespace:   "mira1", // 如果在Kubernetes集群中使用，可以指定命名空间
-		ServiceName: "192.168.40.243",
-		ServicePort: "30015",
+	// 解析连接参数，通过 --profile 切换环境
+	opts := config.RegisterFlags(flag.CommandLine)
+	batchRows := flag.Int64("batch-rows", 0, "target rows per written batch, 0 for no row limit")
+	batchBytes := flag.Int64("batch-bytes", rebatch.DefaultTargetBytes, "target decoded bytes per written batch, 0 for no size limit")
+	workers := flag.Int("workers", runtime.GOMAXPROCS(0), "goroutines decoding and validating received batches")
+	config.RegisterStoreFlags(flag.CommandLine, opts)
+	onDrift := flag.String("on-schema-drift", "fail", "when a batch's schema differs from the first batch: fail, cast or record")
+	compressionName := flag.String("compression", "none", "compress IPC bodies with none, lz4 (LZ4_FRAME) or zstd; readers decompress transparently")
+	preview := flag.Int64("preview", 0, "print the first N rows and exit instead of copying the whole asset")
+	flag.Parse()
+	policy, err := iterator.ParseDriftPolicy(*onDrift)
+	if err != nil {
+		log.Fatal(err)
+	}
+	compression, err := utils.ParseCompression(*compressionName)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	dataServiceClient, err := client.NewDataServiceClient(ctx, serverInfo)
+	dataServiceClient, err := config.NewClient(ctx, opts)
 	if err != nil {
 		log.Fatalf("failed to initialize DataServiceClient: %v", err)
 	}
@@ -32,67 +50,82 @@ func main() {
 		PlatformId:  1,
 	}
 
-	// 调用 ReadStream 方法
-	readStream, err := dataServiceClient.ReadStream(ctx, request)
-	if err != nil {
-		log.Fatalf("Failed to read stream: %v", err)
+	// 只预览前几行：取够行数后取消数据流，服务端随即停止发送
+	if *preview > 0 {
+		previewRows(ctx, dataServiceClient, request, *preview)
+		return
 	}
 
 	bucketName := "data-service"
 	objectName := "bigdatatest123456.arrow"
 
-	// 创建OSS写入流
-	writeStream, err := dataServiceClient.WriteOSSData(ctx, bucketName, objectName)
+	// 指定 --local-store 时写入本地目录，便于离线调试
+	store, err := config.NewObjectStore(ctx, opts, dataServiceClient)
+	if err != nil {
+		log.Fatalf("Failed to open object store: %v", err)
+	}
+
+	// 创建OSS写入流，关闭时写入校验清单
+	writer, err := oss.NewObjectWriter(ctx, store.Put, bucketName, objectName, 0)
 	if err != nil {
 		log.Fatalf("Failed to create OSS write stream: %v", err)
 	}
 
-	// 循环边读边发
-	for {
-		response, err := readStream.Recv()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			log.Fatalf("Error receiving data: %v", err)
-		}
+	// 以第一批的 schema 为准，避免把不同 schema 的批次混在同一个对象里
+	guard := iterator.NewSchemaGuard(policy, nil)
+	guard.OnDrift = func(event iterator.DriftEvent) {
+		log.Printf("Schema drift in %v", event)
+	}
 
-		arrowBatch := response.GetArrowBatch()
-		if len(arrowBatch) == 0 {
-			log.Println("Received empty arrow batch.")
-			continue
-		}
-		// 验证 Arrow 数据的完整性
-		reader, err := ipc.NewReader(bytes.NewReader(arrowBatch))
-		if err != nil {
-			log.Fatalf("Invalid Arrow data: %v", err)
-		}
-		reader.Release()
+	// 流水线拷贝：接收、并行解码校验、按目标行数和字节数重新分批、按顺序发送同时进行
+	stats, err := pipeline.Copy(ctx, func(ctx context.Context) (iterator.Source, error) {
+		return iterator.StreamSource(ctx, dataServiceClient, request)
+	}, writer.WriteChunk, pipeline.Options{
+		Workers:     *workers,
+		Rebatch:     &rebatch.CopyOptions{Options: rebatch.Options{TargetRows: *batchRows, TargetBytes: *batchBytes}, Compression: compression},
+		SchemaGuard: guard,
+		OnProgress: func(s pipeline.Stats) {
+			log.Printf("Progress: %v", s)
+		},
+	})
+	if err != nil {
+		writer.Abort()
+		log.Fatalf("Failed to copy data: %v", err)
+	}
 
-		// 发送数据到写入流
-		chunkRequest := &pb.OSSWriteRequest{
-			BucketName: bucketName,
-			ObjectName: objectName,
-			Chunk:      arrowBatch, // 发送完整的 Arrow 批次
-		}
+	// 最后关闭写入流并写入校验清单
+	if err := writer.Close(); err != nil {
+		log.Fatalf("Failed to close OSS stream: %v", err)
+	}
+	log.Printf("Copied to %s/%s: %v, sha256 %s", bucketName, objectName, stats, writer.Manifest().SHA256)
+}
 
-		log.Printf("Sending chunk of size: %d bytes", len(chunkRequest.Chunk))
+// previewRows 打印前 n 行
+func previewRows(ctx context.Context, dataServiceClient *client.DataServiceClient, request *pb.StreamReadRequest, n int64) {
+	it, err := iterator.ReadStream(ctx, dataServiceClient, request, iterator.WithLimit(n))
+	if err != nil {
+		log.Fatalf("Failed to read stream: %v", err)
+	}
+	defer it.Release()
 
-		if err := writeStream.Send(chunkRequest); err != nil {
-			if err == io.EOF {
-				log.Fatalf("Write stream closed unexpectedly: %v", err)
+	rowIndex := 0
+	for it.Next() {
+		record := it.Record()
+		rows, err := utils.ExtractRowData(record)
+		if err != nil {
+			log.Fatalf("Error extracting row data: %v", err)
+		}
+		for _, row := range rows {
+			rowIndex++
+			fmt.Printf("Row %d: ", rowIndex)
+			for colIndex, value := range row {
+				fmt.Printf("%s=%v ", record.ColumnName(colIndex), value)
 			}
-			log.Fatalf("Failed to send data chunk: %v", err)
+			fmt.Println()
 		}
-
-		log.Println("Successfully sent chunk")
 	}
-
-	// 最后关闭写入流并获取响应
-	finalResponse, err := writeStream.CloseAndRecv()
-	if err != nil {
-		log.Fatalf("Failed to close OSS stream: %v", err)
+	if err := it.Err(); err != nil {
+		log.Fatalf("Failed to read stream: %v", err)
 	}
-	log.Printf("Final OSS write response: %v", finalResponse)
-
+	log.Printf("Previewed %d rows (%d chunks, %d bytes received)", rowIndex, it.Chunks(), it.Bytes())
 }
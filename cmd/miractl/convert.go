@@ -0,0 +1,203 @@
+package main
+
+import (
+	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dustin/go-humanize"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"test/config"
+	"test/convert"
+	"test/iterator"
+	"test/oss"
+	"test/utils"
+)
+
+func init() {
+	register(&command{name: "convert", usage: "convert between Arrow IPC stream and file formats", run: runConvert})
+}
+
+// location 本地路径或 oss://bucket/object
+type location struct {
+	path   string
+	bucket string
+	object string
+}
+
+func parseLocation(s string) (location, error) {
+	rest, ok := strings.CutPrefix(s, "oss://")
+	if !ok {
+		return location{path: s}, nil
+	}
+	bucket, object, _ := strings.Cut(rest, "/")
+	if bucket == "" || object == "" {
+		return location{}, fmt.Errorf("invalid OSS location %q, expected oss://bucket/object", s)
+	}
+	return location{bucket: bucket, object: object}, nil
+}
+
+func (l location) isOSS() bool {
+	return l.bucket != ""
+}
+
+func (l location) String() string {
+	if l.isOSS() {
+		return "oss://" + l.bucket + "/" + l.object
+	}
+	return l.path
+}
+
+func parseIPCFormat(name string) (oss.Format, error) {
+	switch strings.ToLower(name) {
+	case "file", "arrow-file":
+		return oss.FormatArrowFile, nil
+	case "stream", "arrow-stream":
+		return oss.FormatArrowStream, nil
+	}
+	return 0, fmt.Errorf("unknown IPC format %q, expected file or stream", name)
+}
+
+func runConvert(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("convert", "Rewrite Arrow IPC data between stream and file formats. The input is a local file, an\n"+
+		"oss://bucket/object or an external asset read via ReadStream (--asset); concatenated streams are\n"+
+		"merged into a single output with one schema.")
+	in := fs.String("in", "", "input file or oss://bucket/object")
+	out := fs.String("out", "", "output file or oss://bucket/object")
+	to := fs.String("to", "", "output format file|stream (default: the opposite of the input, file for --asset)")
+	assetName := fs.String("asset", "", "read the input from this external asset via ReadStream instead of --in")
+	chainInfoID := fs.Int("chain-info-id", 1, "chain info id")
+	platformID := fs.Int("platform-id", 1, "platform id")
+	var fields stringList
+	fs.Var(&fields, "fields", "columns to read with --asset, comma separated (default all)")
+	chunkSize := byteSize(oss.DefaultChunkSize)
+	fs.Var(&chunkSize, "chunk-size", "bytes per WriteOSSData message when writing to OSS")
+	compression := registerCompressionFlag(fs)
+	drift := registerDriftFlag(fs)
+	config.RegisterStoreFlags(fs, opts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "out"); err != nil {
+		return err
+	}
+	if (*in == "") == (*assetName == "") {
+		return errors.New("exactly one of --in and --asset is required")
+	}
+
+	src, err := parseLocation(*in)
+	if err != nil {
+		return err
+	}
+	dst, err := parseLocation(*out)
+	if err != nil {
+		return err
+	}
+
+	// 只有读取 --asset 时必须连接服务，OSS 位置在指定 --local-store 时读写本地目录
+	var dataServiceClient *client.DataServiceClient
+	var records convert.RecordReader
+	if *assetName != "" {
+		if dataServiceClient, err = config.NewClient(ctx, opts); err != nil {
+			return err
+		}
+		request := &pb.StreamReadRequest{
+			AssetName:   *assetName,
+			ChainInfoId: int32(*chainInfoID),
+			PlatformId:  int32(*platformID),
+			DbFields:    fields,
+		}
+		it, err := iterator.ReadStream(ctx, dataServiceClient, request, iterator.WithSchemaGuard(drift.guard()))
+		if err != nil {
+			return err
+		}
+		defer it.Release()
+		records = it
+	}
+	var store oss.ObjectStore
+	if src.isOSS() || dst.isOSS() {
+		if store, err = config.NewObjectStore(ctx, opts, dataServiceClient); err != nil {
+			return err
+		}
+	}
+
+	inputFormat := oss.FormatArrowStream
+	if records == nil {
+		var reader *convert.Reader
+		if src.isOSS() {
+			reader, err = convert.OpenObject(ctx, store.Get, src.bucket, src.object, nil)
+		} else {
+			reader, err = convert.OpenFile(src.path, nil)
+		}
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		records = reader
+		inputFormat = reader.Format()
+	}
+
+	outputFormat := oss.FormatArrowFile
+	if *to != "" {
+		if outputFormat, err = parseIPCFormat(*to); err != nil {
+			return err
+		}
+	} else if inputFormat == oss.FormatArrowFile {
+		outputFormat = oss.FormatArrowStream
+	}
+
+	var w io.WriteCloser
+	if dst.isOSS() {
+		w, err = oss.NewObjectWriter(ctx, store.Put, dst.bucket, dst.object, int(chunkSize))
+	} else {
+		w, err = os.Create(dst.path)
+	}
+	if err != nil {
+		return err
+	}
+
+	counter := &countingWriter{w: w}
+	result, err := convert.Write(counter, records, outputFormat, utils.Compression(*compression))
+	if objectWriter, ok := w.(*oss.ObjectWriter); ok && err != nil {
+		// 转换失败时放弃写入，避免留下不完整的对象
+		objectWriter.Abort()
+	}
+	if cerr := w.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("failed to close %s: %v", dst, cerr)
+	}
+	if err != nil {
+		if !dst.isOSS() {
+			os.Remove(dst.path)
+		}
+		return err
+	}
+	log.Printf("Converted %s (%s) to %s (%s): %d batches, %d rows, %s",
+		describeInput(src, *assetName), inputFormat, dst, outputFormat, result.Batches, result.Rows, humanize.IBytes(uint64(counter.n)))
+	if result.Compression.Batches > 0 {
+		log.Printf("Compression %s: %v", utils.Compression(*compression), result.Compression)
+	}
+	return nil
+}
+
+func describeInput(src location, assetName string) string {
+	if assetName != "" {
+		return "asset " + assetName
+	}
+	return src.String()
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
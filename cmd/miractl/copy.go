@@ -0,0 +1,106 @@
+package main
+
+import (
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"context"
+	"fmt"
+	"log"
+	"runtime"
+	"test/config"
+	"test/iterator"
+	"test/oss"
+	"test/pipeline"
+	"test/rebatch"
+	"test/utils"
+)
+
+func init() {
+	register(&command{name: "copy", usage: "copy an external asset into an OSS object", run: runCopy})
+}
+
+func runCopy(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("copy", "Stream an external asset via ReadStream and write it into an OSS object via WriteOSSData.\n"+
+		"Receiving, decoding and writing run concurrently. Received batches are validated by --workers\n"+
+		"goroutines, coalesced or split to --batch-rows / --batch-bytes, re-encoded and written in order.")
+	assetName := fs.String("asset", "", "asset name")
+	chainInfoID := fs.Int("chain-info-id", 1, "chain info id")
+	platformID := fs.Int("platform-id", 1, "platform id")
+	bucketName := fs.String("bucket", "data-service", "target bucket name")
+	objectName := fs.String("object", "", "target object name")
+	var fields stringList
+	fs.Var(&fields, "fields", "columns to read, comma separated (default all)")
+	batchRows := fs.Int64("batch-rows", 0, "target rows per written batch, 0 for no row limit")
+	batchBytes := byteSize(rebatch.DefaultTargetBytes)
+	fs.Var(&batchBytes, "batch-bytes", "target decoded bytes per written batch, 0 for no size limit")
+	maxChunkBytes := byteSize(rebatch.DefaultMaxChunkBytes)
+	fs.Var(&maxChunkBytes, "max-chunk-bytes", "hard limit of an encoded batch, batches above it are split")
+	noRebatch := fs.Bool("no-rebatch", false, "write the received batches unchanged after validating them")
+	compression := registerCompressionFlag(fs)
+	drift := registerDriftFlag(fs)
+	config.RegisterStoreFlags(fs, opts)
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "goroutines decoding and validating received batches")
+	buffer := fs.Int("buffer", pipeline.DefaultBuffer, "batches buffered between pipeline stages")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "asset", "bucket", "object"); err != nil {
+		return err
+	}
+	if *noRebatch && utils.Compression(*compression) != utils.CompressionNone {
+		return fmt.Errorf("--compression re-encodes batches and cannot be combined with --no-rebatch")
+	}
+	if *noRebatch && iterator.DriftPolicy(*drift) == iterator.DriftCast {
+		return fmt.Errorf("--on-schema-drift=cast re-encodes batches and cannot be combined with --no-rebatch")
+	}
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	request := &pb.StreamReadRequest{
+		AssetName:   *assetName,
+		ChainInfoId: int32(*chainInfoID),
+		PlatformId:  int32(*platformID),
+		DbFields:    fields,
+	}
+	store, err := config.NewObjectStore(ctx, opts, dataServiceClient)
+	if err != nil {
+		return err
+	}
+
+	// 每个批次作为单独的消息写入，Close 后写入校验清单
+	writer, err := oss.NewObjectWriter(ctx, store.Put, *bucketName, *objectName, 0)
+	if err != nil {
+		return err
+	}
+
+	// 接收、并行解码校验、按目标大小重新分批和写入以流水线方式进行
+	copyOpts := pipeline.Options{
+		Workers:     *workers,
+		Buffer:      *buffer,
+		SchemaGuard: drift.guard(),
+		OnProgress: func(s pipeline.Stats) {
+			log.Printf("Progress: %v", s)
+		},
+	}
+	if !*noRebatch {
+		copyOpts.Rebatch = &rebatch.CopyOptions{
+			Options:       rebatch.Options{TargetRows: *batchRows, TargetBytes: int64(batchBytes)},
+			MaxChunkBytes: int(maxChunkBytes),
+			Compression:   utils.Compression(*compression),
+		}
+	}
+	stats, err := pipeline.Copy(ctx, func(ctx context.Context) (iterator.Source, error) {
+		return iterator.StreamSource(ctx, dataServiceClient, request)
+	}, writer.WriteChunk, copyOpts)
+	if err != nil {
+		writer.Abort()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close OSS stream: %v", err)
+	}
+	log.Printf("Copied to %s/%s: %v, sha256 %s", *bucketName, *objectName, stats, writer.Manifest().SHA256)
+	return nil
+}
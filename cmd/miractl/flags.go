@@ -0,0 +1,301 @@
+package main
+
+import (
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"flag"
+	"fmt"
+	"github.com/dustin/go-humanize"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"test/aggregate"
+	"test/config"
+	"test/filter"
+	"test/iterator"
+	"test/query"
+	"test/utils"
+)
+
+// newFlagSet 创建子命令的 FlagSet，并注册连接相关的公共参数
+func newFlagSet(name, usage string) (*flag.FlagSet, *config.Options) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: miractl %s [flags]\n\n%s\n\nFlags:\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs, config.RegisterFlags(fs)
+}
+
+// stringList 可重复指定的参数，也支持逗号分隔
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// repeated 可重复指定的参数，值中的逗号不做拆分
+type repeated []string
+
+func (r *repeated) String() string {
+	return strings.Join(*r, " ")
+}
+
+func (r *repeated) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
+
+// requireFlags 检查必填参数
+func requireFlags(fs *flag.FlagSet, names ...string) error {
+	for _, name := range names {
+		f := fs.Lookup(name)
+		if f == nil || f.Value.String() == "" {
+			return fmt.Errorf("flag --%s is required", name)
+		}
+	}
+	return nil
+}
+
+// parseSortRules 解析 field[:asc|desc] 形式的排序规则
+func parseSortRules(specs []string) ([]*pb.SortRule, error) {
+	var rules []*pb.SortRule
+	for _, spec := range specs {
+		field, order, _ := strings.Cut(spec, ":")
+		rule := &pb.SortRule{FieldName: field, SortOrder: pb.SortOrder_ASC}
+		switch strings.ToLower(order) {
+		case "", "asc":
+		case "desc":
+			rule.SortOrder = pb.SortOrder_DESC
+		default:
+			return nil, fmt.Errorf("invalid sort order %q in %q", order, spec)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseFilters 解析 field:OP:v1,v2 形式的过滤条件，strs 中的值为字符串，nums 中的值为数字；
+// 服务端目前只支持 IN，其余运算符在解析时报错，需要改用 --query 在客户端计算
+func parseFilters(strs, nums []string) (*filter.Builder, error) {
+	b := filter.New()
+	add := func(spec string, numeric bool) error {
+		parts := strings.SplitN(spec, ":", 3)
+		if len(parts) < 2 || parts[0] == "" {
+			return fmt.Errorf("invalid filter %q, expected field:OP:v1,v2", spec)
+		}
+		op, err := filter.ParseOp(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid filter %q: %v", spec, err)
+		}
+		var values []any
+		if len(parts) == 3 && parts[2] != "" {
+			for _, v := range strings.Split(parts[2], ",") {
+				if !numeric {
+					values = append(values, v)
+					continue
+				}
+				f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+				if err != nil {
+					return fmt.Errorf("invalid number %q in filter %q", v, spec)
+				}
+				values = append(values, f)
+			}
+		}
+		if err := b.Add(parts[0], op, values...).Err(); err != nil {
+			return fmt.Errorf("invalid filter %q: %v", spec, err)
+		}
+		return nil
+	}
+	for _, spec := range strs {
+		if err := add(spec, false); err != nil {
+			return nil, err
+		}
+	}
+	for _, spec := range nums {
+		if err := add(spec, true); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// byteSize 字节数参数，支持 64MiB、1GB 等写法
+type byteSize int64
+
+func (b *byteSize) String() string {
+	return humanize.IBytes(uint64(*b))
+}
+
+func (b *byteSize) Set(value string) error {
+	n, err := humanize.ParseBytes(value)
+	if err != nil {
+		return err
+	}
+	*b = byteSize(n)
+	return nil
+}
+
+// compressionFlag IPC 消息体压缩方式参数：none、lz4 或 zstd
+type compressionFlag utils.Compression
+
+func (c *compressionFlag) String() string {
+	return utils.Compression(*c).String()
+}
+
+func (c *compressionFlag) Set(value string) error {
+	compression, err := utils.ParseCompression(value)
+	if err != nil {
+		return err
+	}
+	*c = compressionFlag(compression)
+	return nil
+}
+
+// registerCompressionFlag 注册 --compression 参数
+func registerCompressionFlag(fs *flag.FlagSet) *compressionFlag {
+	c := new(compressionFlag)
+	fs.Var(c, "compression", "compress IPC bodies with none, lz4 (LZ4_FRAME) or zstd; readers decompress transparently")
+	return c
+}
+
+// driftFlag 后续批次的 schema 与第一批不同时的处理方式：fail、cast 或 record
+type driftFlag iterator.DriftPolicy
+
+func (d *driftFlag) String() string {
+	return iterator.DriftPolicy(*d).String()
+}
+
+func (d *driftFlag) Set(value string) error {
+	policy, err := iterator.ParseDriftPolicy(value)
+	if err != nil {
+		return err
+	}
+	*d = driftFlag(policy)
+	return nil
+}
+
+// registerDriftFlag 注册 --on-schema-drift 参数，默认遇到变化即报错
+func registerDriftFlag(fs *flag.FlagSet) *driftFlag {
+	d := new(driftFlag)
+	fs.Var(d, "on-schema-drift", "when a batch's schema differs from the first batch: fail, cast compatible changes, or record and keep it")
+	return d
+}
+
+// guard 创建 schema 检查器，放行或转换的变化输出到日志
+func (d *driftFlag) guard() *iterator.SchemaGuard {
+	guard := iterator.NewSchemaGuard(iterator.DriftPolicy(*d), nil)
+	guard.OnDrift = func(event iterator.DriftEvent) {
+		log.Printf("Schema drift in %v", event)
+	}
+	return guard
+}
+
+// queryFlag 类 SQL 查询参数，解析参数时即检查语法
+type queryFlag struct {
+	text  string
+	query *query.Query
+}
+
+func (f *queryFlag) String() string {
+	return f.text
+}
+
+func (f *queryFlag) Set(value string) error {
+	q, err := query.Parse(value)
+	if err != nil {
+		return err
+	}
+	f.text, f.query = value, q
+	return nil
+}
+
+// plan 拆分查询，无法下推的过滤条件和投影在客户端计算，未指定 --query 时返回 nil
+func (f *queryFlag) plan() (*query.Plan, error) {
+	if f.query == nil {
+		return nil, nil
+	}
+	plan, err := f.query.Plan()
+	if err != nil {
+		return nil, err
+	}
+	if plan.Local() {
+		log.Printf("Query plan:\n%v", plan)
+	}
+	return plan, nil
+}
+
+// registerQueryFlag 注册 --query 参数，查询中的列、过滤条件和排序下推到读取请求，其余部分在客户端计算
+func registerQueryFlag(fs *flag.FlagSet) *queryFlag {
+	f := new(queryFlag)
+	fs.Var(f, "query", "SQL-like query, e.g. \"SELECT name, gpa * 10 AS g WHERE name IN ('Alice') AND score >= 60 ORDER BY gpa DESC\"; conditions the service cannot run, GROUP BY and COUNT/SUM/MIN/MAX/AVG are evaluated locally; FROM may replace --asset or --db/--table")
+	return f
+}
+
+// limitFlags --limit 和 --offset 参数
+type limitFlags struct {
+	limit  *int64
+	offset *int64
+}
+
+// registerLimitFlags 注册 --limit 和 --offset 参数，取够行数后立即取消数据流
+func registerLimitFlags(fs *flag.FlagSet) *limitFlags {
+	return &limitFlags{
+		limit:  fs.Int64("limit", -1, "stop after this many rows and cancel the stream, negative means all rows"),
+		offset: fs.Int64("offset", 0, "skip this many rows first (they are still received from the service)"),
+	}
+}
+
+// options 直接作用于读取迭代器的选项，不经过客户端计算时使用
+func (l *limitFlags) options() []iterator.Option {
+	var opts []iterator.Option
+	if *l.limit >= 0 {
+		opts = append(opts, iterator.WithLimit(*l.limit))
+	}
+	if *l.offset > 0 {
+		opts = append(opts, iterator.WithOffset(*l.offset))
+	}
+	return opts
+}
+
+// wrap 在客户端计算之后截取行，未指定时原样返回 it
+func (l *limitFlags) wrap(it iterator.Records) iterator.Records {
+	if *l.limit < 0 && *l.offset <= 0 {
+		return it
+	}
+	return iterator.Limit(it, *l.offset, *l.limit)
+}
+
+// aggFlag 聚合列参数 func[:column][=name]，可重复指定，也支持逗号分隔
+type aggFlag []aggregate.Spec
+
+func (a *aggFlag) String() string {
+	names := make([]string, len(*a))
+	for i, spec := range *a {
+		names[i] = spec.OutputName()
+	}
+	return strings.Join(names, ",")
+}
+
+func (a *aggFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		spec, err := aggregate.ParseSpec(v)
+		if err != nil {
+			return err
+		}
+		*a = append(*a, spec)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"test/config"
+	"test/importer"
+	"test/sink"
+	"test/utils"
+	"unicode/utf8"
+)
+
+func init() {
+	register(&command{name: "import", usage: "import a CSV/JSONL/Parquet file into an external or internal table", run: group("import", map[string]*command{
+		"external": {name: "external", usage: "import into an external asset table via WriteExternalDBData", run: runImportExternal},
+		"internal": {name: "internal", usage: "import into an internal table via WriteInternalDBData", run: runImportInternal},
+	})})
+}
+
+// importFlags 导入命令的公共参数
+type importFlags struct {
+	file            *string
+	format          *string
+	schema          *string
+	delimiter       *string
+	noHeader        *bool
+	nullValues      stringList
+	batchRows       *int
+	maxChunkBytes   *int
+	continueOnError *bool
+	verbose         *bool
+	compression     *compressionFlag
+}
+
+func registerImportFlags(fs *flag.FlagSet) *importFlags {
+	f := &importFlags{
+		file:            fs.String("file", "", "local CSV, JSON Lines or Parquet file"),
+		format:          fs.String("format", "", "input format csv|jsonl|parquet (default from --file extension)"),
+		schema:          fs.String("schema", "", "column types, e.g. id:int32,name:string,amount:decimal(10,2) (default inferred)"),
+		delimiter:       fs.String("delimiter", "", "CSV delimiter (default comma, tab for .tsv)"),
+		noHeader:        fs.Bool("no-header", false, "CSV file has no header row"),
+		batchRows:       fs.Int("batch-rows", importer.DefaultBatchRows, "rows read from the file per batch"),
+		maxChunkBytes:   fs.Int("max-chunk-bytes", importer.DefaultMaxChunkBytes, "maximum size of each IPC chunk sent to the server"),
+		continueOnError: fs.Bool("continue-on-error", false, "keep sending chunks after a chunk fails"),
+		verbose:         fs.Bool("v", false, "log every chunk"),
+	}
+	fs.Var(&f.nullValues, "null", "CSV values treated as null, comma separated (empty is always null)")
+	f.compression = registerCompressionFlag(fs)
+	return f
+}
+
+// run 打开文件并通过 write 导入全部记录
+func (f *importFlags) run(ctx context.Context, write importer.WriteFunc) error {
+	var format sink.Format
+	var err error
+	if *f.format != "" {
+		format, err = sink.ParseFormat(*f.format)
+	} else {
+		format, err = sink.FormatFromPath(*f.file)
+	}
+	if err != nil {
+		return err
+	}
+
+	readOpts := importer.ReadOptions{
+		BatchRows:  *f.batchRows,
+		NoHeader:   *f.noHeader,
+		NullValues: f.nullValues,
+	}
+	if *f.schema != "" {
+		if readOpts.Schema, err = importer.ParseSchema(*f.schema); err != nil {
+			return err
+		}
+	}
+	switch {
+	case *f.delimiter != "":
+		r, size := utf8.DecodeRuneInString(*f.delimiter)
+		if size != len(*f.delimiter) {
+			return fmt.Errorf("delimiter must be a single character, got %q", *f.delimiter)
+		}
+		readOpts.Delimiter = r
+	case strings.EqualFold(*f.format, "tsv"):
+		readOpts.Delimiter = '\t'
+	}
+
+	reader, err := importer.Open(ctx, *f.file, format, readOpts)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	log.Printf("Importing %s with schema: %v", *f.file, reader.Schema())
+
+	result, err := importer.Import(ctx, reader, write, importer.Options{
+		MaxChunkBytes:   *f.maxChunkBytes,
+		ContinueOnError: *f.continueOnError,
+		Verbose:         *f.verbose,
+		Compression:     utils.Compression(*f.compression),
+	})
+	log.Printf("Imported %d rows in %d chunks (%d bytes), %d rows failed in %d chunks",
+		result.Rows, result.Chunks, result.Bytes, result.FailedRows, len(result.Failures))
+	if result.Compression.Batches > 0 {
+		log.Printf("Compression %s: %v", utils.Compression(*f.compression), result.Compression)
+	}
+	if len(result.Failures) > 0 {
+		log.Printf("Failed chunks:\n%v", result.FailureError())
+	}
+	return err
+}
+
+func runImportExternal(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("import external", "Import a local CSV, JSON Lines or Parquet file into an external asset table via WriteExternalDBData.")
+	assetName := fs.String("asset", "", "asset name")
+	tableName := fs.String("table", "", "target table name")
+	chainInfoID := fs.Int("chain-info-id", 1, "chain info id")
+	platformID := fs.Int("platform-id", 1, "platform id")
+	importArgs := registerImportFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "asset", "table", "file"); err != nil {
+		return err
+	}
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	template := &pb.WriterExternalDataRequest{
+		PlatformId:  int32(*platformID),
+		AssetName:   *assetName,
+		TableName:   *tableName,
+		ChainInfoId: int32(*chainInfoID),
+	}
+	return importArgs.run(ctx, importer.ExternalWriter(dataServiceClient, template))
+}
+
+func runImportInternal(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("import internal", "Import a local CSV, JSON Lines or Parquet file into an internal table via WriteInternalDBData.")
+	dbName := fs.String("db", "", "internal database name")
+	tableName := fs.String("table", "", "target table name")
+	importArgs := registerImportFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "db", "table", "file"); err != nil {
+		return err
+	}
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return importArgs.run(ctx, importer.InternalWriter(dataServiceClient, *dbName, *tableName))
+}
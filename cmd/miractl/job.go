@@ -0,0 +1,259 @@
+package main
+
+import (
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"test/config"
+	"test/job"
+	"time"
+)
+
+func init() {
+	register(&command{name: "job", usage: "submit and track batch jobs", run: group("job", map[string]*command{
+		"submit": {name: "submit", usage: "submit a batch job", run: runJobSubmit},
+		"status": {name: "status", usage: "show the status of a batch job", run: runJobStatus},
+		"wait":   {name: "wait", usage: "wait until a batch job finishes", run: runJobWait},
+		"list":   {name: "list", usage: "list locally tracked jobs that were interrupted", run: runJobList},
+		"resume": {name: "resume", usage: "resume waiting for a locally tracked job", run: runJobResume},
+	})})
+}
+
+// parseOperationMode 按名称查找 OperationMode，PSI_JOIN 和 OPERATION_MODE_PSI_JOIN 均可
+func parseOperationMode(name string) (pb.OperationMode, error) {
+	name = strings.ToUpper(name)
+	for _, candidate := range []string{name, "OPERATION_MODE_" + name} {
+		if v, ok := pb.OperationMode_value[candidate]; ok {
+			return pb.OperationMode(v), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown operation mode %q", name)
+}
+
+func runJobSubmit(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("job submit", "Submit a batch job via SubmitBatchJob.")
+	assetName := fs.String("asset", "", "asset name")
+	chainInfoID := fs.Int("chain-info-id", 1, "chain info id")
+	platformID := fs.Int("platform-id", 1, "platform id")
+	bucketName := fs.String("bucket", "data-service", "bucket of the output object")
+	dataObject := fs.String("object", "output.arrow", "output object name")
+	mode := fs.String("mode", "", "operation mode, e.g. PSI_JOIN (default: plain export)")
+	tempTable := fs.String("temp-table", "", "temp table name")
+	targetTable := fs.String("target-table", "", "target table name")
+	orderBy := fs.String("order-by", "", "order by column")
+	var fields, joinColumns stringList
+	fs.Var(&fields, "fields", "columns to read, comma separated (default all)")
+	fs.Var(&joinColumns, "join-columns", "join columns, comma separated")
+
+	// SparkConfig
+	dynamicAllocation := fs.Bool("dynamic-allocation", true, "enable spark dynamic allocation")
+	minExecutors := fs.Int("min-executors", 2, "dynamic allocation min executors")
+	maxExecutors := fs.Int("max-executors", 10, "dynamic allocation max executors")
+	executorMemory := fs.Int("executor-memory-mb", 6072, "executor memory in MB")
+	executorCores := fs.Int("executor-cores", 4, "executor cores")
+	driverMemory := fs.Int("driver-memory-mb", 6536, "driver memory in MB")
+	driverCores := fs.Int("driver-cores", 2, "driver cores")
+	parallelism := fs.Int("parallelism", 200, "spark parallelism")
+	numPartitions := fs.Int("partitions", 20, "number of partitions")
+
+	wait := fs.Bool("wait", false, "wait until the job finishes")
+	wf := registerWaitFlags(fs)
+	stateDir := fs.String("state-dir", "", "directory of local job state files (default ~/.mira/jobs)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "asset"); err != nil {
+		return err
+	}
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	sparkConfig := &pb.SparkConfig{
+		DynamicAllocationEnabled:      *dynamicAllocation,
+		DynamicAllocationMinExecutors: int32(*minExecutors),
+		DynamicAllocationMaxExecutors: int32(*maxExecutors),
+		ExecutorMemoryMB:              int32(*executorMemory),
+		ExecutorCores:                 int32(*executorCores),
+		DriverMemoryMB:                int32(*driverMemory),
+		DriverCores:                   int32(*driverCores),
+		Parallelism:                   int32(*parallelism),
+		NumPartitions:                 int32(*numPartitions),
+	}
+	request := &pb.BatchReadRequest{
+		AssetName:     *assetName,
+		ChainInfoId:   int32(*chainInfoID),
+		PlatformId:    int32(*platformID),
+		DbFields:      fields,
+		SparkConfig:   sparkConfig,
+		BucketName:    *bucketName,
+		DataObject:    *dataObject,
+		JoinColumns:   joinColumns,
+		TempTable:     *tempTable,
+		OrderByColumn: *orderBy,
+		TargetTable:   *targetTable,
+	}
+	if *mode != "" {
+		if request.Mode, err = parseOperationMode(*mode); err != nil {
+			return err
+		}
+	}
+
+	// 提交后立即记录 JobId，等待过程中被中断也可以通过 job resume 继续
+	guard := job.NewGuard(ctx, job.NewStore(*stateDir))
+	defer guard.Stop()
+
+	resp, err := dataServiceClient.SubmitBatchJob(ctx, request)
+	if err != nil {
+		return fmt.Errorf("提交作业失败: %v", err)
+	}
+	fmt.Println("作业已提交，初始状态：", resp.Status, "JobId:", resp.JobId)
+	if err := guard.Track(resp.JobId, request); err != nil {
+		log.Printf("Failed to save job state: %v", err)
+	}
+	if !*wait {
+		return nil
+	}
+	status, err := waitJob(guard.Context(), job.ClientStatus(dataServiceClient), resp.JobId, wf)
+	if err := guard.Done(status, err); err != nil {
+		log.Printf("Failed to update job state: %v", err)
+	}
+	return err
+}
+
+func runJobStatus(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("job status", "Show the status of a batch job via GetJobStatus.")
+	jobID := fs.String("id", "", "job id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "id"); err != nil {
+		return err
+	}
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	statusResp, err := dataServiceClient.GetJobStatus(ctx, *jobID)
+	if err != nil {
+		return fmt.Errorf("查询作业状态失败: %v", err)
+	}
+	fmt.Println(statusResp)
+	return nil
+}
+
+func runJobWait(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("job wait", "Poll GetJobStatus with exponential backoff until the batch job finishes.")
+	jobID := fs.String("id", "", "job id")
+	wf := registerWaitFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "id"); err != nil {
+		return err
+	}
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	_, err = waitJob(ctx, job.ClientStatus(dataServiceClient), *jobID, wf)
+	return err
+}
+
+func runJobList(ctx context.Context, args []string) error {
+	fs, _ := newFlagSet("job list", "List jobs recorded in the local state dir, e.g. after an interrupted job submit.")
+	stateDir := fs.String("state-dir", "", "directory of local job state files (default ~/.mira/jobs)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	states, err := job.NewStore(*stateDir).List()
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		fmt.Printf("%s\t%s\t%s\tsubmitted %s\t%s\n", state.JobID, state.State, state.Status,
+			state.SubmittedAt.Format(time.DateTime), state.Note)
+	}
+	return nil
+}
+
+func runJobResume(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("job resume", "Resume waiting for a job recorded in the local state dir.")
+	jobID := fs.String("id", "", "job id")
+	stateDir := fs.String("state-dir", "", "directory of local job state files (default ~/.mira/jobs)")
+	wf := registerWaitFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "id"); err != nil {
+		return err
+	}
+
+	store := job.NewStore(*stateDir)
+	state, err := store.Load(*jobID)
+	if err != nil {
+		return err
+	}
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	guard := job.NewGuard(ctx, store)
+	defer guard.Stop()
+	if err := guard.Resume(state); err != nil {
+		return err
+	}
+	status, err := waitJob(guard.Context(), job.ClientStatus(dataServiceClient), state.JobID, wf)
+	if err := guard.Done(status, err); err != nil {
+		log.Printf("Failed to update job state: %v", err)
+	}
+	return err
+}
+
+// waitFlags 等待作业相关的参数
+type waitFlags struct {
+	interval    *time.Duration
+	maxInterval *time.Duration
+	timeout     *time.Duration
+	maxErrors   *int
+}
+
+func registerWaitFlags(fs *flag.FlagSet) *waitFlags {
+	return &waitFlags{
+		interval:    fs.Duration("interval", 5*time.Second, "initial poll interval"),
+		maxInterval: fs.Duration("max-interval", time.Minute, "max poll interval of the exponential backoff"),
+		timeout:     fs.Duration("timeout", 0, "total time to wait, 0 means no limit"),
+		maxErrors:   fs.Int("max-errors", job.DefaultMaxConsecutiveErrors, "max consecutive status errors, negative means no limit"),
+	}
+}
+
+// waitJob 等待作业结束并打印每次状态变化
+func waitJob(ctx context.Context, getStatus job.StatusFunc, jobID string, wf *waitFlags) (pb.JobStatus, error) {
+	opts := &job.WaitOptions{
+		InitialInterval:      *wf.interval,
+		MaxInterval:          *wf.maxInterval,
+		Timeout:              *wf.timeout,
+		MaxConsecutiveErrors: *wf.maxErrors,
+		OnTransition: func(t job.Transition) {
+			fmt.Println("当前作业状态：", t.To, "已等待：", t.Elapsed.Round(time.Second))
+		},
+	}
+	startTime := time.Now()
+	status, err := job.Wait(ctx, getStatus, jobID, opts)
+	if err != nil {
+		return status, err
+	}
+	fmt.Println("作业完成，最终状态：", status)
+	log.Printf("Total execution time: %v", time.Since(startTime))
+	return status, nil
+}
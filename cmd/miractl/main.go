@@ -0,0 +1,97 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/3
+	@note: miractl 数据服务命令行工具，整合各个示例的功能
+
+*
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command 一个子命令，args 不包含子命令名称本身
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]*command{}
+
+func register(cmd *command) {
+	commands[cmd.name] = cmd
+}
+
+func main() {
+	log.SetFlags(log.LstdFlags)
+
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		printUsage()
+		return
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		printUsage()
+		os.Exit(2)
+	}
+
+	err := cmd.run(context.Background(), os.Args[2:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", cmd.name, err)
+	}
+}
+
+func printUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage: miractl <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'miractl <command> -h' for the flags of a command.")
+}
+
+// group 将带二级子命令的命令（如 oss get/put）分发到具体实现
+func group(name string, subs map[string]*command) func(ctx context.Context, args []string) error {
+	return func(ctx context.Context, args []string) error {
+		if len(args) == 0 || subs[args[0]] == nil {
+			keys := make([]string, 0, len(subs))
+			for k := range subs {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			if len(args) > 0 && args[0] != "-h" && args[0] != "--help" {
+				return fmt.Errorf("unknown subcommand %q, expected one of: %s", args[0], strings.Join(keys, ", "))
+			}
+			fmt.Fprintf(os.Stderr, "Usage: miractl %s <%s> [flags]\n", name, strings.Join(keys, "|"))
+			for _, k := range keys {
+				fmt.Fprintf(os.Stderr, "  %-10s %s\n", k, subs[k].usage)
+			}
+			return nil
+		}
+		return subs[args[0]].run(ctx, args[1:])
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/dustin/go-humanize"
+	"log"
+	"path"
+	"test/config"
+	"test/iterator"
+	"test/oss"
+	"test/partition"
+	"time"
+)
+
+func init() {
+	register(&command{name: "oss", usage: "read, download and upload OSS objects", run: group("oss", map[string]*command{
+		"get":        {name: "get", usage: "print or export the rows of an Arrow object in OSS", run: runOSSGet},
+		"put":        {name: "put", usage: "upload a local file to OSS in resumable chunks", run: runOSSPut},
+		"download":   {name: "download", usage: "download an OSS object to a local file", run: runOSSDownload},
+		"uploads":    {name: "uploads", usage: "list interrupted uploads", run: runOSSUploads},
+		"stat":       {name: "stat", usage: "show the size and checksum of an OSS object", run: runOSSStat},
+		"partitions": {name: "partitions", usage: "read all partitions of a batch job output as one stream", run: runOSSPartitions},
+	})})
+}
+
+func runOSSGet(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("oss get", "Read an Arrow object from OSS via ReadOSSData and print or export its rows.")
+	bucketName := fs.String("bucket", "data-service", "bucket name")
+	objectName := fs.String("object", "", "object name")
+	noVerify := fs.Bool("no-verify", false, "do not verify the data against the object's checksum manifest")
+	requireManifest := fs.Bool("require-manifest", false, "fail if the object has no checksum manifest")
+	drift := registerDriftFlag(fs)
+	config.RegisterStoreFlags(fs, opts)
+	output := registerOutputFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "bucket", "object"); err != nil {
+		return err
+	}
+
+	store, err := config.NewObjectStore(ctx, opts, nil)
+	if err != nil {
+		return err
+	}
+
+	it, err := oss.ReadRecords(ctx, store.Get, *bucketName, *objectName,
+		oss.ReadOptions{SkipVerify: *noVerify, RequireManifest: *requireManifest}, iterator.WithSchemaGuard(drift.guard()))
+	if err != nil {
+		return err
+	}
+	return output.write(it)
+}
+
+func runOSSPartitions(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("oss partitions", "Read the partition objects <object>_partition_<id>.arrow written by a batch job and\n"+
+		"print or export them as one stream. Partitions are listed by --partitions or by a local --manifest;\n"+
+		"without either, <object>_partition_0.arrow, _partition_1.arrow... are read until one is missing.\n"+
+		"With --merge or --order-by the partitions, each already sorted, are k-way merged so that the output\n"+
+		"is globally ordered.")
+	bucketName := fs.String("bucket", "data-service", "bucket name")
+	dataObject := fs.String("object", "", "DataObject of the batch job, e.g. data/<uuid>")
+	manifestPath := fs.String("manifest", "", "local partition manifest (default: probe <object>_partition_0.arrow, _partition_1.arrow...)")
+	var partitions stringList
+	fs.Var(&partitions, "partitions", "partition ids or object names, comma separated")
+	merge := fs.Bool("merge", false, "merge by the OrderByColumn recorded in the manifest")
+	orderBy := fs.String("order-by", "", "merge by this column")
+	desc := fs.Bool("desc", false, "partitions are sorted in descending order")
+	batchRows := fs.Int("batch-rows", partition.DefaultBatchRows, "rows per merged record batch")
+	noVerify := fs.Bool("no-verify", false, "do not verify partitions against their checksum manifests")
+	config.RegisterStoreFlags(fs, opts)
+	output := registerOutputFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(partitions) == 0 && *manifestPath == "" {
+		if err := requireFlags(fs, "bucket", "object"); err != nil {
+			return err
+		}
+	}
+
+	store, err := config.NewObjectStore(ctx, opts, nil)
+	if err != nil {
+		return err
+	}
+	open := store.Get
+
+	var manifest *partition.Manifest
+	switch {
+	case len(partitions) > 0:
+		manifest = &partition.Manifest{BucketName: *bucketName, DataObject: *dataObject, Partitions: partitions}
+	case *manifestPath != "":
+		if manifest, err = partition.LoadManifest(*manifestPath); err != nil {
+			return err
+		}
+		if manifest.BucketName == "" {
+			manifest.BucketName = *bucketName
+		}
+		if manifest.DataObject == "" {
+			manifest.DataObject = *dataObject
+		}
+	default:
+		if manifest, err = partition.Probe(ctx, store.Stat, *bucketName, *dataObject); err != nil {
+			return err
+		}
+	}
+
+	readOpts := partition.Options{
+		OrderBy:    *orderBy,
+		Descending: *desc,
+		BatchRows:  *batchRows,
+		Verify:     oss.ReadOptions{SkipVerify: *noVerify},
+	}
+	if *merge && readOpts.OrderBy == "" {
+		if manifest.OrderByColumn == "" {
+			return fmt.Errorf("--merge requires an orderByColumn in the manifest or --order-by")
+		}
+		readOpts.OrderBy = manifest.OrderByColumn
+	}
+	log.Printf("Reading %d partitions of %s/%s", len(manifest.Partitions), manifest.BucketName, manifest.DataObject)
+	reader, err := partition.OpenManifest(ctx, open, manifest, readOpts)
+	if err != nil {
+		return err
+	}
+	return output.write(reader)
+}
+
+func runOSSDownload(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("oss download", "Download an OSS object via ReadOSSData into a local file and detect whether it is\n"+
+		"an Arrow IPC file, an Arrow IPC stream or opaque bytes. Objects uploaded in parts are reassembled\n"+
+		"and the data is verified against the <object>.manifest.json SHA-256 manifest when present.")
+	bucketName := fs.String("bucket", "data-service", "bucket name")
+	objectName := fs.String("object", "", "object name")
+	out := fs.String("out", "", "local file to write (default: base name of the object)")
+	validate := fs.Bool("validate", false, "read all record batches of Arrow data after downloading")
+	noVerify := fs.Bool("no-verify", false, "do not verify the data against the object's checksum manifest")
+	requireManifest := fs.Bool("require-manifest", false, "fail if the object has no checksum manifest")
+	config.RegisterStoreFlags(fs, opts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "bucket", "object"); err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = path.Base(*objectName)
+	}
+
+	store, err := config.NewObjectStore(ctx, opts, nil)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	result, err := oss.Download(ctx, store.Get, *bucketName, *objectName, *out, oss.DownloadOptions{
+		Validate:        *validate,
+		SkipVerify:      *noVerify,
+		RequireManifest: *requireManifest,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Downloaded %s/%s to %s: %s in %d chunks, %d parts, format %s, %v",
+		*bucketName, *objectName, *out, humanize.IBytes(uint64(result.Size)), result.Chunks, result.Parts, result.Format, time.Since(start).Round(time.Millisecond))
+	if result.Verified {
+		log.Printf("Verified sha256 %s against the manifest", result.SHA256)
+	} else {
+		log.Printf("sha256 %s (not verified)", result.SHA256)
+	}
+	if *validate && result.Format != oss.FormatOpaque {
+		log.Printf("Validated %d record batches, %d rows", result.Batches, result.Rows)
+	}
+	return nil
+}
+
+func runOSSPut(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("oss put", "Upload a local file to OSS in chunks via WriteOSSData. By default the file is written as a\n"+
+		"single object under --object, and an interrupted upload restarts from the beginning. With --part-size,\n"+
+		"larger files are uploaded as <object>.part-NNNNN objects plus an <object>.parts index that only miractl\n"+
+		"and the oss package can read back; progress is recorded per part in --state-dir so that running the\n"+
+		"same command again resumes after the last uploaded part.")
+	bucketName := fs.String("bucket", "data-service", "bucket name")
+	objectName := fs.String("object", "", "object name")
+	filePath := fs.String("file", "", "local file to upload")
+	chunkSize := byteSize(oss.DefaultChunkSize)
+	fs.Var(&chunkSize, "chunk-size", "bytes per WriteOSSData message")
+	var partSize byteSize
+	fs.Var(&partSize, "part-size", "split files larger than this into part objects, e.g. 256MiB (default: single object)")
+	retries := fs.Int("retries", oss.DefaultRetries, "retries per part, -1 to disable")
+	stateDir := fs.String("state-dir", oss.DefaultStateDir(), "directory of local upload progress files")
+	restart := fs.Bool("restart", false, "ignore recorded progress and upload from the beginning")
+	config.RegisterStoreFlags(fs, opts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "bucket", "object", "file"); err != nil {
+		return err
+	}
+
+	store, err := config.NewObjectStore(ctx, opts, nil)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	result, err := oss.Upload(ctx, store.Put, *bucketName, *objectName, *filePath, oss.UploadOptions{
+		ChunkSize: int(chunkSize),
+		PartSize:  int64(partSize),
+		Retries:   *retries,
+		Restart:   *restart,
+		Store:     oss.NewStateStore(*stateDir),
+		OnProgress: func(p oss.Progress) {
+			log.Printf("Part %d/%d uploaded, %s/%s", p.Part, p.Parts, humanize.IBytes(uint64(p.Offset)), humanize.IBytes(uint64(p.Size)))
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("%v; run the same command again to resume", err)
+	}
+	log.Printf("Uploaded %s to %s/%s in %d parts (%d resumed) in %v, sha256 %s",
+		humanize.IBytes(uint64(result.Size)), *bucketName, *objectName, len(result.Parts), result.ResumedParts, time.Since(start).Round(time.Millisecond), result.Manifest.SHA256)
+	return nil
+}
+
+func runOSSStat(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("oss stat", "Show the size, checksum and modification time of an OSS object. The data service has no\n"+
+		"stat call, so the size comes from the checksum manifest or the part index, or from reading the object.")
+	bucketName := fs.String("bucket", "data-service", "bucket name")
+	objectName := fs.String("object", "", "object name")
+	config.RegisterStoreFlags(fs, opts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "bucket", "object"); err != nil {
+		return err
+	}
+
+	store, err := config.NewObjectStore(ctx, opts, nil)
+	if err != nil {
+		return err
+	}
+	info, err := store.Stat(ctx, *bucketName, *objectName)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s/%s\t%s\t%d bytes\n", info.Bucket, info.Object, humanize.IBytes(uint64(info.Size)), info.Size)
+	if info.SHA256 != "" {
+		fmt.Printf("sha256\t%s\n", info.SHA256)
+	}
+	if !info.ModTime.IsZero() {
+		fmt.Printf("modified\t%s\n", info.ModTime.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func runOSSUploads(ctx context.Context, args []string) error {
+	fs, _ := newFlagSet("oss uploads", "List interrupted uploads recorded in --state-dir.")
+	stateDir := fs.String("state-dir", oss.DefaultStateDir(), "directory of local upload progress files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	states, err := oss.NewStateStore(*stateDir).List()
+	if err != nil {
+		return err
+	}
+	if len(states) == 0 {
+		fmt.Println("No interrupted uploads.")
+		return nil
+	}
+	for _, s := range states {
+		fmt.Printf("%s/%s\t%s\t%s/%s\tparts=%d\tupdated=%s\n", s.Bucket, s.Object, s.File,
+			humanize.IBytes(uint64(s.Offset)), humanize.IBytes(uint64(s.Size)), s.Parts, s.UpdatedAt.Format(time.RFC3339))
+	}
+	return nil
+}
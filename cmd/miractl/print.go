@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"test/aggregate"
+	"test/iterator"
+	"test/sink"
+	"test/utils"
+	"unicode/utf8"
+)
+
+// outputFlags 读取类命令的输出参数，未指定 --out 时打印到标准输出
+type outputFlags struct {
+	out       *string
+	format    *string
+	delimiter *string
+	nullValue *string
+	groupBy   stringList
+	aggs      aggFlag
+}
+
+func registerOutputFlags(fs *flag.FlagSet) *outputFlags {
+	o := &outputFlags{
+		out:       fs.String("out", "", "write rows to this file instead of printing them"),
+		format:    fs.String("format", "", "output format csv|jsonl|parquet (default from --out extension)"),
+		delimiter: fs.String("delimiter", "", "CSV delimiter (default comma, tab for .tsv)"),
+		nullValue: fs.String("null", "", "CSV representation of null values"),
+	}
+	fs.Var(&o.groupBy, "group-by", "aggregate the rows locally grouped by these columns, comma separated")
+	fs.Var(&o.aggs, "agg", "aggregate func[:column][=name] computed locally, func is count, count_distinct, sum, min, max or avg, e.g. count,sum:amount=total, repeatable")
+	return o
+}
+
+// write 将迭代器中的记录打印或导出到文件，并释放迭代器；指定了 --group-by 或 --agg 时输出聚合结果
+func (o *outputFlags) write(it iterator.Records) error {
+	if len(o.groupBy) > 0 || len(o.aggs) > 0 {
+		it = aggregate.NewReader(it, o.groupBy, o.aggs, nil)
+	}
+	defer it.Release()
+	if *o.out == "" {
+		return printRecords(it)
+	}
+
+	var format sink.Format
+	var err error
+	if *o.format != "" {
+		format, err = sink.ParseFormat(*o.format)
+	} else {
+		format, err = sink.FormatFromPath(*o.out)
+	}
+	if err != nil {
+		return err
+	}
+
+	opts := sink.Options{CSV: sink.CSVOptions{NullValue: *o.nullValue}}
+	if *o.delimiter != "" {
+		r, size := utf8.DecodeRuneInString(*o.delimiter)
+		if size != len(*o.delimiter) {
+			return fmt.Errorf("delimiter must be a single character, got %q", *o.delimiter)
+		}
+		opts.CSV.Delimiter = r
+	}
+
+	s, err := sink.Create(*o.out, format, opts)
+	if err != nil {
+		return err
+	}
+	rows, err := sink.Drain(it, s)
+	if cerr := s.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if stats, ok := it.(interface {
+		Chunks() int
+		Bytes() int64
+	}); ok {
+		log.Printf("Wrote %d rows (%d chunks, %d bytes received) to %s", rows, stats.Chunks(), stats.Bytes(), *o.out)
+	} else {
+		log.Printf("Wrote %d rows to %s", rows, *o.out)
+	}
+	return nil
+}
+
+// printRecords 打印迭代器中的每一行数据，不释放迭代器
+func printRecords(it iterator.Records) error {
+	rowIndex := 0
+	for it.Next() {
+		record := it.Record()
+		rows, err := utils.ExtractRowData(record)
+		if err != nil {
+			return fmt.Errorf("error extracting row data: %v", err)
+		}
+		for _, row := range rows {
+			rowIndex++
+			fmt.Printf("Row %d: ", rowIndex)
+			for colIndex, value := range row {
+				fmt.Printf("%s=%v ", record.ColumnName(colIndex), value)
+			}
+			fmt.Println()
+		}
+	}
+	return it.Err()
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"context"
+	"log"
+	"strings"
+	"test/config"
+	"test/iterator"
+)
+
+func init() {
+	register(&command{name: "table-info", usage: "show table info of an asset", run: runTableInfo})
+	register(&command{name: "read", usage: "stream rows of an external asset", run: runRead})
+	register(&command{name: "read-internal", usage: "stream rows of an internal table", run: runReadInternal})
+}
+
+func runTableInfo(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("table-info", "Show the table info (size, columns) of an asset.")
+	assetName := fs.String("asset", "", "asset name")
+	chainInfoID := fs.Int("chain-info-id", 1, "chain info id")
+	platformID := fs.Int("platform-id", 1, "platform id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "asset"); err != nil {
+		return err
+	}
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	request := &pb.TableInfoRequest{
+		AssetName:   *assetName,
+		ChainInfoId: int32(*chainInfoID),
+		PlatformId:  int32(*platformID),
+	}
+	response, err := dataServiceClient.GetTableInfo(ctx, request)
+	if err != nil {
+		return err
+	}
+	log.Printf("Response: %v", response)
+	return nil
+}
+
+func runRead(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("read", "Stream rows of an external asset via ReadStream.")
+	assetName := fs.String("asset", "", "asset name")
+	chainInfoID := fs.Int("chain-info-id", 1, "chain info id")
+	platformID := fs.Int("platform-id", 1, "platform id")
+	var fields, sorts stringList
+	fs.Var(&fields, "fields", "columns to read, comma separated (default all)")
+	fs.Var(&sorts, "sort", "sort rule field[:asc|desc], repeatable")
+	var strFilters, floatFilters repeated
+	fs.Var(&strFilters, "filter", "string filter field:IN:v1,v2, e.g. name:IN:Alice,Bob, repeatable; the service only supports IN, use --query for other operators")
+	fs.Var(&floatFilters, "filter-num", "numeric filter field:IN:v1,v2, e.g. score:IN:60,90, repeatable; the service only supports IN, use --query for other operators")
+	sql := registerQueryFlag(fs)
+	drift := registerDriftFlag(fs)
+	window := registerLimitFlags(fs)
+	output := registerOutputFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	q := sql.query
+	if q == nil || q.From == "" {
+		if err := requireFlags(fs, "asset"); err != nil {
+			return err
+		}
+	}
+
+	sortRules, err := parseSortRules(sorts)
+	if err != nil {
+		return err
+	}
+	filters, err := parseFilters(strFilters, floatFilters)
+	if err != nil {
+		return err
+	}
+
+	request := &pb.StreamReadRequest{
+		AssetName:   *assetName,
+		ChainInfoId: int32(*chainInfoID),
+		PlatformId:  int32(*platformID),
+		DbFields:    fields,
+		SortRules:   sortRules,
+	}
+	if err := filters.ApplyStream(request); err != nil {
+		return err
+	}
+	plan, err := sql.plan()
+	if err != nil {
+		return err
+	}
+	if plan != nil {
+		if err := plan.Pushdown.ApplyStream(request); err != nil {
+			return err
+		}
+	}
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	// 客户端过滤之后再截取行，否则直接在读取迭代器上截取
+	iterOpts := []iterator.Option{iterator.WithSchemaGuard(drift.guard())}
+	if plan == nil {
+		iterOpts = append(iterOpts, window.options()...)
+	}
+	it, err := iterator.ReadStream(ctx, dataServiceClient, request, iterOpts...)
+	if err != nil {
+		return err
+	}
+	if plan != nil {
+		return output.write(window.wrap(plan.Wrap(it, nil)))
+	}
+	return output.write(it)
+}
+
+func runReadInternal(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("read-internal", "Stream rows of an internal table via ReadInternalDBData.")
+	dbName := fs.String("db", "", "internal database name")
+	tableName := fs.String("table", "", "internal table name")
+	var fields, sorts stringList
+	fs.Var(&fields, "fields", "columns to read, comma separated (default all)")
+	fs.Var(&sorts, "sort", "sort rule field[:asc|desc], repeatable")
+	var strFilters, floatFilters repeated
+	fs.Var(&strFilters, "filter", "string filter field:IN:v1,v2, e.g. data:IN:58950,65960, repeatable; the service only supports IN, use --query for other operators")
+	fs.Var(&floatFilters, "filter-num", "numeric filter field:IN:v1,v2, e.g. score:IN:60,90, repeatable; the service only supports IN, use --query for other operators")
+	sql := registerQueryFlag(fs)
+	drift := registerDriftFlag(fs)
+	window := registerLimitFlags(fs)
+	output := registerOutputFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	q := sql.query
+	if q == nil || q.From == "" {
+		if err := requireFlags(fs, "table"); err != nil {
+			return err
+		}
+	}
+	if q == nil || !strings.Contains(q.From, ".") {
+		if err := requireFlags(fs, "db"); err != nil {
+			return err
+		}
+	}
+
+	sortRules, err := parseSortRules(sorts)
+	if err != nil {
+		return err
+	}
+	filters, err := parseFilters(strFilters, floatFilters)
+	if err != nil {
+		return err
+	}
+
+	request := &pb.InternalReadRequest{
+		DbName:    *dbName,
+		TableName: *tableName,
+		DbFields:  fields,
+		SortRules: sortRules,
+	}
+	if err := filters.ApplyInternal(request); err != nil {
+		return err
+	}
+	plan, err := sql.plan()
+	if err != nil {
+		return err
+	}
+	if plan != nil {
+		if err := plan.Pushdown.ApplyInternal(request); err != nil {
+			return err
+		}
+	}
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	// 客户端过滤之后再截取行，否则直接在读取迭代器上截取
+	iterOpts := []iterator.Option{iterator.WithSchemaGuard(drift.guard())}
+	if plan == nil {
+		iterOpts = append(iterOpts, window.options()...)
+	}
+	it, err := iterator.ReadInternalDBData(ctx, dataServiceClient, request, iterOpts...)
+	if err != nil {
+		return err
+	}
+	if plan != nil {
+		return output.write(window.wrap(plan.Wrap(it, nil)))
+	}
+	return output.write(it)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"context"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"log"
+	"os"
+	"test/config"
+	"test/convert"
+	"test/oss"
+	"test/utils"
+)
+
+func init() {
+	register(&command{name: "write-external", usage: "write an Arrow file into an external asset table", run: runWriteExternal})
+	register(&command{name: "write-internal", usage: "write an Arrow file into an internal table", run: runWriteInternal})
+}
+
+func runWriteExternal(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("write-external", "Write the records of a local Arrow IPC file into an external asset table via WriteExternalDBData.")
+	assetName := fs.String("asset", "", "asset name")
+	tableName := fs.String("table", "", "target table name")
+	filePath := fs.String("file", "", "local Arrow IPC file (stream or file format)")
+	compression := registerCompressionFlag(fs)
+	chainInfoID := fs.Int("chain-info-id", 1, "chain info id")
+	platformID := fs.Int("platform-id", 1, "platform id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "asset", "table", "file"); err != nil {
+		return err
+	}
+
+	arrowBatch, err := readArrowStream(*filePath, utils.Compression(*compression))
+	if err != nil {
+		return err
+	}
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	request := &pb.WriterExternalDataRequest{
+		ArrowBatch:  arrowBatch,
+		PlatformId:  int32(*platformID),
+		AssetName:   *assetName,
+		TableName:   *tableName,
+		ChainInfoId: int32(*chainInfoID),
+	}
+	response, err := dataServiceClient.WriteExternalDBData(ctx, request)
+	if err != nil {
+		return fmt.Errorf("failed to write external data: %v", err)
+	}
+	log.Printf("Response: %v", response)
+	return nil
+}
+
+func runWriteInternal(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("write-internal", "Write the records of a local Arrow IPC file into an internal table via WriteInternalDBData.")
+	dbName := fs.String("db", "", "internal database name")
+	tableName := fs.String("table", "", "target table name")
+	filePath := fs.String("file", "", "local Arrow IPC file (stream or file format)")
+	compression := registerCompressionFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "db", "table", "file"); err != nil {
+		return err
+	}
+
+	arrowBatch, err := readArrowStream(*filePath, utils.Compression(*compression))
+	if err != nil {
+		return err
+	}
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	request := &pb.WriterInternalDataRequest{
+		ArrowBatch: arrowBatch,
+		DbName:     *dbName,
+		TableName:  *tableName,
+	}
+	response := dataServiceClient.WriteInternalDBData(ctx, []*pb.WriterInternalDataRequest{request})
+	log.Printf("Response: %v", response)
+	return nil
+}
+
+// readArrowStream 读取本地 Arrow 文件并返回 IPC stream 格式的字节，file 格式或指定了压缩方式时重新编码
+func readArrowStream(path string, compression utils.Compression) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	if oss.DetectFormat(data) != oss.FormatArrowFile && compression == utils.CompressionNone {
+		return data, nil
+	}
+
+	reader, err := convert.OpenFile(path, memory.NewGoAllocator())
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var buf bytes.Buffer
+	result, err := convert.Write(&buf, reader, oss.FormatArrowStream, compression)
+	if err != nil {
+		return nil, err
+	}
+	if compression != utils.CompressionNone {
+		log.Printf("Compression %s: %v", compression, result.Compression)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"context"
+	"fmt"
+)
+
+// ServerInfo 转换为数据服务客户端使用的 ServerInfo
+func (p *Profile) ServerInfo() *pb.ServerInfo {
+	return &pb.ServerInfo{
+		Namespace:   p.Namespace,
+		ServiceName: p.ServiceName,
+		ServicePort: p.ServicePort,
+	}
+}
+
+// NewClient 根据参数解析 profile 并创建数据服务客户端
+func NewClient(ctx context.Context, opts *Options) (*client.DataServiceClient, error) {
+	profile, err := Load(opts)
+	if err != nil {
+		return nil, err
+	}
+	return profile.NewClient(ctx)
+}
+
+// NewClient 使用当前 profile 创建数据服务客户端
+func (p *Profile) NewClient(ctx context.Context) (*client.DataServiceClient, error) {
+	dataServiceClient, err := client.NewDataServiceClient(ctx, p.ServerInfo())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize DataServiceClient with profile %q: %v", p.Name, err)
+	}
+	return dataServiceClient, nil
+}
@@ -0,0 +1,189 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/2
+	@note: 数据服务连接配置，支持按 profile 加载
+
+*
+*/
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultProfile 未指定 profile 时使用的名称
+	DefaultProfile = "dev"
+	// DefaultConfigFile 未指定配置文件时尝试读取的文件
+	DefaultConfigFile = "mira.yaml"
+)
+
+// 环境变量名称，优先级高于配置文件、低于命令行参数
+const (
+	EnvConfigFile  = "MIRA_CONFIG"
+	EnvProfile     = "MIRA_PROFILE"
+	EnvNamespace   = "MIRA_NAMESPACE"
+	EnvServiceName = "MIRA_SERVICE_NAME"
+	EnvServicePort = "MIRA_SERVICE_PORT"
+	EnvLocalStore  = "MIRA_LOCAL_STORE"
+)
+
+// Profile 一组数据服务的连接参数
+type Profile struct {
+	Name        string `yaml:"-"`
+	Namespace   string `yaml:"namespace"` // 如果在Kubernetes集群中使用，可以指定命名空间
+	ServiceName string `yaml:"serviceName"`
+	ServicePort string `yaml:"servicePort"`
+}
+
+// File 配置文件的结构
+type File struct {
+	Default  string              `yaml:"default"`
+	Profiles map[string]*Profile `yaml:"profiles"`
+}
+
+// builtinProfiles 内置的 profile，没有配置文件时也可以直接连接开发环境
+var builtinProfiles = map[string]*Profile{
+	DefaultProfile: {
+		ServiceName: "192.168.40.243",
+		ServicePort: "30015",
+	},
+}
+
+// Options 命令行参数，空字符串表示未指定
+type Options struct {
+	ConfigFile  string
+	Profile     string
+	Namespace   string
+	ServiceName string
+	ServicePort string
+	LocalStore  string // 对象存储使用的本地目录，由 RegisterStoreFlags 注册
+}
+
+// RegisterFlags 在 FlagSet 上注册连接相关的参数
+func RegisterFlags(fs *flag.FlagSet) *Options {
+	opts := &Options{}
+	fs.StringVar(&opts.ConfigFile, "config", "", "path of the profile file (env "+EnvConfigFile+", default "+DefaultConfigFile+")")
+	fs.StringVar(&opts.Profile, "profile", "", "profile name, e.g. dev/test/prod (env "+EnvProfile+")")
+	fs.StringVar(&opts.Namespace, "namespace", "", "kubernetes namespace of the data service (env "+EnvNamespace+")")
+	fs.StringVar(&opts.ServiceName, "service-name", "", "host or service name of the data service (env "+EnvServiceName+")")
+	fs.StringVar(&opts.ServicePort, "service-port", "", "port of the data service (env "+EnvServicePort+")")
+	return opts
+}
+
+// LoadFile 读取配置文件
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+	file := &File{}
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	return file, nil
+}
+
+// Load 按 内置默认值 < 配置文件 < 环境变量 < 命令行参数 的顺序解析出最终的 profile
+func Load(opts *Options) (*Profile, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	// 确定配置文件，显式指定的文件必须存在，默认文件可以不存在
+	path := firstNonEmpty(opts.ConfigFile, os.Getenv(EnvConfigFile))
+	explicit := path != ""
+	if !explicit {
+		path = DefaultConfigFile
+	}
+	var file *File
+	if _, err := os.Stat(path); err == nil || explicit {
+		file, err = LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to stat config file %s: %v", path, err)
+	}
+
+	// 确定 profile 名称
+	name := firstNonEmpty(opts.Profile, os.Getenv(EnvProfile))
+	if name == "" && file != nil {
+		name = file.Default
+	}
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	profile := &Profile{Name: name}
+	found := false
+	if p, ok := builtinProfiles[name]; ok {
+		profile.merge(p)
+		found = true
+	}
+	if file != nil {
+		if p, ok := file.Profiles[name]; ok && p != nil {
+			profile.merge(p)
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("profile %q not found", name)
+	}
+
+	profile.merge(&Profile{
+		Namespace:   os.Getenv(EnvNamespace),
+		ServiceName: os.Getenv(EnvServiceName),
+		ServicePort: os.Getenv(EnvServicePort),
+	})
+	profile.merge(&Profile{
+		Namespace:   opts.Namespace,
+		ServiceName: opts.ServiceName,
+		ServicePort: opts.ServicePort,
+	})
+
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// Validate 检查 profile 是否可以用来建立连接
+func (p *Profile) Validate() error {
+	if p.ServiceName == "" {
+		return fmt.Errorf("profile %q: service name is empty", p.Name)
+	}
+	if p.ServicePort == "" {
+		return fmt.Errorf("profile %q: service port is empty", p.Name)
+	}
+	return nil
+}
+
+// merge 用 other 中非空的字段覆盖 p
+func (p *Profile) merge(other *Profile) {
+	if other.Namespace != "" {
+		p.Namespace = other.Namespace
+	}
+	if other.ServiceName != "" {
+		p.ServiceName = other.ServiceName
+	}
+	if other.ServicePort != "" {
+		p.ServicePort = other.ServicePort
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
+	"context"
+	"flag"
+	"os"
+	"test/oss"
+)
+
+// RegisterStoreFlags 为读写 OSS 的命令注册 --local-store 参数
+func RegisterStoreFlags(fs *flag.FlagSet, opts *Options) {
+	fs.StringVar(&opts.LocalStore, "local-store", "", "read and write OSS objects in this local directory instead of the data service (env "+EnvLocalStore+")")
+}
+
+// LocalStoreDir 返回对象存储使用的本地目录，命令行参数优先于环境变量，都未指定时为空
+func (o *Options) LocalStoreDir() string {
+	if o == nil {
+		return os.Getenv(EnvLocalStore)
+	}
+	return firstNonEmpty(o.LocalStore, os.Getenv(EnvLocalStore))
+}
+
+// NewObjectStore 指定了本地目录时返回 oss.LocalStore，不连接数据服务；
+// 否则返回基于数据服务的实现，dataServiceClient 为空时按 profile 创建客户端
+func NewObjectStore(ctx context.Context, opts *Options, dataServiceClient *client.DataServiceClient) (oss.ObjectStore, error) {
+	if dir := opts.LocalStoreDir(); dir != "" {
+		return oss.NewLocalStore(dir), nil
+	}
+	if dataServiceClient == nil {
+		var err error
+		if dataServiceClient, err = NewClient(ctx, opts); err != nil {
+			return nil, err
+		}
+	}
+	return oss.NewClientStore(dataServiceClient), nil
+}
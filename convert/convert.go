@@ -0,0 +1,279 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/17
+	@note: Arrow IPC stream 格式与 file 格式互相转换
+
+*
+*/
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"io"
+	"os"
+	"test/oss"
+	"test/utils"
+)
+
+// RecordReader 逐批读取记录，Record 在下一次 Next 后失效；
+// iterator.RecordIterator、importer.Reader 和本包的 Reader 均满足该接口
+type RecordReader interface {
+	Next() bool
+	Record() arrow.Record
+	Err() error
+}
+
+// Result 转换结果
+type Result struct {
+	Schema      *arrow.Schema
+	Batches     int
+	Rows        int64
+	Compression utils.CompressionStats // 仅在指定压缩方式时统计
+}
+
+// Write 将 reader 中的所有记录写为一个 format 格式（FormatArrowFile 或 FormatArrowStream）的 IPC 数据，
+// 消息体按 compression 压缩；所有记录必须具有相同的 schema，reader 中没有记录时返回错误
+func Write(w io.Writer, reader RecordReader, format oss.Format, compression utils.Compression) (*Result, error) {
+	if format != oss.FormatArrowFile && format != oss.FormatArrowStream {
+		return nil, fmt.Errorf("cannot write %s data", format)
+	}
+
+	result := &Result{}
+	out := &offsetWriter{w: w}
+	var writer interface {
+		Write(arrow.Record) error
+		Close() error
+	}
+	for reader.Next() {
+		record := reader.Record()
+		if writer == nil {
+			result.Schema = record.Schema()
+			writerOpts := append([]ipc.Option{ipc.WithSchema(result.Schema)}, compression.IPCOptions()...)
+			if format == oss.FormatArrowFile {
+				fileWriter, err := ipc.NewFileWriter(out, writerOpts...)
+				if err != nil {
+					return result, fmt.Errorf("failed to create Arrow IPC file writer: %v", err)
+				}
+				writer = fileWriter
+			} else {
+				writer = ipc.NewWriter(out, writerOpts...)
+			}
+		} else if !result.Schema.Equal(record.Schema()) {
+			writer.Close()
+			return result, fmt.Errorf("record batch %d has a different schema:\n%v\nexpected:\n%v", result.Batches, record.Schema(), result.Schema)
+		}
+		offset := out.pos
+		if err := writer.Write(record); err != nil {
+			writer.Close()
+			return result, fmt.Errorf("failed to write record batch %d: %v", result.Batches, err)
+		}
+		if compression != utils.CompressionNone {
+			if err := result.Compression.AddRecord(record, out.pos-offset); err != nil {
+				writer.Close()
+				return result, err
+			}
+		}
+		result.Batches++
+		result.Rows += record.NumRows()
+	}
+	if err := reader.Err(); err != nil {
+		if writer != nil {
+			writer.Close()
+		}
+		return result, err
+	}
+	if writer == nil {
+		return result, errors.New("no record batches to write")
+	}
+	if err := writer.Close(); err != nil {
+		return result, fmt.Errorf("failed to close IPC writer: %v", err)
+	}
+	return result, nil
+}
+
+// offsetWriter ipc.NewFileWriter 只通过 Seek(0, io.SeekCurrent) 获取当前偏移量，
+// 记录已写入的字节数即可支持不可 Seek 的输出（如 OSS 写入流）
+type offsetWriter struct {
+	w   io.Writer
+	pos int64
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.w.Write(p)
+	o.pos += int64(n)
+	return n, err
+}
+
+func (o *offsetWriter) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekCurrent {
+		return o.pos, errors.New("offsetWriter only supports Seek(0, io.SeekCurrent)")
+	}
+	return o.pos, nil
+}
+
+// Reader 读取本地 Arrow 数据，支持 file 格式以及一个或多个首尾相接的 stream
+type Reader struct {
+	format oss.Format
+	mem    memory.Allocator
+	closer io.Closer
+
+	// stream 格式
+	r      io.Reader
+	stream *ipc.Reader
+
+	// file 格式
+	file  *ipc.FileReader
+	index int
+
+	record arrow.Record
+	err    error
+}
+
+// OpenFile 打开本地 Arrow 文件并根据文件头识别格式
+func OpenFile(path string, mem memory.Allocator) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	header := make([]byte, 8)
+	n, _ := io.ReadFull(f, header)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to seek file: %v", err)
+	}
+
+	format := oss.DetectFormat(header[:n])
+	if format == oss.FormatArrowFile {
+		reader, err := NewFileFormatReader(f, mem)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		reader.closer = f
+		return reader, nil
+	}
+	if format == oss.FormatOpaque && n > 0 {
+		f.Close()
+		return nil, fmt.Errorf("%s is not Arrow IPC data", path)
+	}
+	reader := NewStreamReader(f, mem)
+	reader.closer = f
+	return reader, nil
+}
+
+// NewFileFormatReader 读取 IPC file 格式的数据
+func NewFileFormatReader(r ipc.ReadAtSeeker, mem memory.Allocator) (*Reader, error) {
+	if mem == nil {
+		mem = memory.NewGoAllocator()
+	}
+	file, err := ipc.NewFileReader(r, ipc.WithAllocator(mem))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Arrow IPC file reader: %v", err)
+	}
+	return &Reader{format: oss.FormatArrowFile, mem: mem, file: file}, nil
+}
+
+// NewStreamReader 读取一个或多个首尾相接的 IPC stream，例如 OSS 拷贝时逐个写入的 ReadStream 响应
+func NewStreamReader(r io.Reader, mem memory.Allocator) *Reader {
+	if mem == nil {
+		mem = memory.NewGoAllocator()
+	}
+	return &Reader{format: oss.FormatArrowStream, mem: mem, r: r}
+}
+
+// Format 输入数据的格式
+func (r *Reader) Format() oss.Format {
+	return r.format
+}
+
+// Next 读取下一批记录
+func (r *Reader) Next() bool {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	if r.err != nil {
+		return false
+	}
+
+	if r.file != nil {
+		if r.index >= r.file.NumRecords() {
+			return false
+		}
+		record, err := r.file.Record(r.index)
+		if err != nil {
+			r.err = fmt.Errorf("failed to read record batch %d: %v", r.index, err)
+			return false
+		}
+		r.index++
+		record.Retain()
+		r.record = record
+		return true
+	}
+
+	for {
+		if r.stream == nil {
+			stream, err := ipc.NewReader(r.r, ipc.WithAllocator(r.mem))
+			if errors.Is(err, io.EOF) {
+				return false
+			}
+			if err != nil {
+				r.err = fmt.Errorf("failed to create Arrow IPC reader: %v", err)
+				return false
+			}
+			r.stream = stream
+		}
+		if r.stream.Next() {
+			r.record = r.stream.Record()
+			r.record.Retain()
+			return true
+		}
+		if err := r.stream.Err(); err != nil {
+			r.err = fmt.Errorf("failed to read record batch: %v", err)
+			return false
+		}
+		// 当前 stream 已结束，继续读取后面拼接的 stream
+		r.stream.Release()
+		r.stream = nil
+	}
+}
+
+// Record 返回当前批次
+func (r *Reader) Record() arrow.Record {
+	return r.record
+}
+
+// Err 返回读取过程中的错误
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Close 释放读取器，由 OpenFile 打开时同时关闭文件
+func (r *Reader) Close() error {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	if r.stream != nil {
+		r.stream.Release()
+		r.stream = nil
+	}
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	if r.closer != nil {
+		if cerr := r.closer.Close(); err == nil {
+			err = cerr
+		}
+		r.closer = nil
+	}
+	return err
+}
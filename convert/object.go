@@ -0,0 +1,45 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"os"
+	"test/oss"
+)
+
+// OpenObject 将 OSS 对象下载到临时文件后打开，Close 时删除临时文件；
+// file 格式需要随机读取，因此不能直接在数据流上解析
+func OpenObject(ctx context.Context, open oss.SourceFunc, bucket, object string, mem memory.Allocator) (*Reader, error) {
+	tmp, err := os.CreateTemp("", "mira-convert-*.arrow")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %v", err)
+	}
+	tmp.Close()
+
+	if _, err := oss.Download(ctx, open, bucket, object, tmp.Name(), oss.DownloadOptions{}); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	reader, err := OpenFile(tmp.Name(), mem)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	reader.closer = &removeOnClose{closer: reader.closer, path: tmp.Name()}
+	return reader, nil
+}
+
+// removeOnClose 关闭文件后删除它
+type removeOnClose struct {
+	closer interface{ Close() error }
+	path   string
+}
+
+func (c *removeOnClose) Close() error {
+	err := c.closer.Close()
+	if rerr := os.Remove(c.path); err == nil && rerr != nil {
+		err = rerr
+	}
+	return err
+}
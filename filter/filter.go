@@ -0,0 +1,447 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/23
+	@note: 过滤条件构造器，生成请求中一一对应的 FilterNames/FilterOperators/FilterValues
+
+*
+*/
+package filter
+
+import (
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Op 过滤运算符，发送请求时通过 protoOps 映射为客户端定义的 pb.FilterOperator
+type Op int
+
+const (
+	OpIn Op = iota
+	OpNotIn
+	OpEq
+	OpNe
+	OpGt
+	OpGe
+	OpLt
+	OpLe
+	OpBetween
+	OpLike
+	OpIsNull
+	OpIsNotNull
+)
+
+// opInfo 运算符的写法、别名和值的个数，maxValues 为 -1 表示不限
+type opInfo struct {
+	symbol    string
+	aliases   []string
+	minValues int
+	maxValues int
+}
+
+var ops = map[Op]opInfo{
+	OpIn:        {"IN", nil, 1, -1},
+	OpNotIn:     {"NOT IN", []string{"NOT_IN", "NIN"}, 1, -1},
+	OpEq:        {"=", []string{"==", "EQ", "EQUAL", "EQUALS"}, 1, 1},
+	OpNe:        {"!=", []string{"<>", "NE", "NOT_EQUAL", "NOT_EQUALS"}, 1, 1},
+	OpGt:        {">", []string{"GT", "GREATER_THAN"}, 1, 1},
+	OpGe:        {">=", []string{"GE", "GTE", "GREATER_THAN_OR_EQUAL", "GREATER_EQUAL"}, 1, 1},
+	OpLt:        {"<", []string{"LT", "LESS_THAN"}, 1, 1},
+	OpLe:        {"<=", []string{"LE", "LTE", "LESS_THAN_OR_EQUAL", "LESS_EQUAL"}, 1, 1},
+	OpBetween:   {"BETWEEN", nil, 2, 2},
+	OpLike:      {"LIKE", nil, 1, 1},
+	OpIsNull:    {"IS NULL", []string{"IS_NULL", "NULL"}, 0, 0},
+	OpIsNotNull: {"IS NOT NULL", []string{"IS_NOT_NULL", "NOT_NULL"}, 0, 0},
+}
+
+// protoOps 运算符对应的 pb.FilterOperator，客户端新增枚举后在这里补充；
+// 不在表中的运算符无法发送给服务端，由 query 等调用方在客户端计算
+var protoOps = map[Op]pb.FilterOperator{
+	OpIn: pb.FilterOperator_IN_OPERATOR,
+}
+
+func (op Op) String() string {
+	if info, ok := ops[op]; ok {
+		return info.symbol
+	}
+	return fmt.Sprintf("Op(%d)", int(op))
+}
+
+// ParseOp 解析运算符，支持 >、>= 等符号，GT、NOT_IN 等简写以及 pb.FilterOperator 的枚举名（可带 _OPERATOR 后缀）
+func ParseOp(name string) (Op, error) {
+	name = strings.Join(strings.Fields(strings.ToUpper(name)), "_")
+	name = strings.TrimSuffix(name, "_OPERATOR")
+	for op, info := range ops {
+		if name == strings.ReplaceAll(info.symbol, " ", "_") {
+			return op, nil
+		}
+		for _, alias := range info.aliases {
+			if name == alias {
+				return op, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown filter operator %q", name)
+}
+
+// Proto 返回客户端中对应的 pb.FilterOperator；客户端未定义该运算符时说明服务端无法执行这一过滤
+func (op Op) Proto() (pb.FilterOperator, error) {
+	if _, ok := ops[op]; !ok {
+		return 0, fmt.Errorf("unknown filter operator %v", op)
+	}
+	if v, ok := protoOps[op]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("filter operator %v is not supported by the data service client (supported: %s)", op, supportedOperators())
+}
+
+func supportedOperators() string {
+	var names []string
+	for op := OpIn; op <= OpIsNotNull; op++ {
+		if _, ok := protoOps[op]; ok {
+			names = append(names, op.String())
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// Condition 一个过滤条件，Values 中的值已统一为 string 或 float64
+type Condition struct {
+	Field  string
+	Op     Op
+	Values []any
+}
+
+// NewCondition 校验并创建过滤条件，values 中的切片会被展开
+func NewCondition(field string, op Op, values ...any) (Condition, error) {
+	c := Condition{Field: field, Op: op}
+	info, ok := ops[op]
+	if !ok {
+		return c, fmt.Errorf("unknown filter operator %v", op)
+	}
+	if field == "" {
+		return c, fmt.Errorf("filter %v: field name is required", op)
+	}
+	var err error
+	if c.Values, err = normalizeValues(values); err != nil {
+		return c, fmt.Errorf("filter on %s: %v", field, err)
+	}
+	n := len(c.Values)
+	switch {
+	case info.minValues == info.maxValues && n != info.minValues:
+		return c, fmt.Errorf("filter %s %v takes %d values, got %d", field, op, info.minValues, n)
+	case n < info.minValues:
+		return c, fmt.Errorf("filter %s %v takes at least %d value, got %d", field, op, info.minValues, n)
+	}
+	if n > 0 {
+		_, numeric := c.Values[0].(float64)
+		for _, v := range c.Values[1:] {
+			if _, ok := v.(float64); ok != numeric {
+				return c, fmt.Errorf("filter %s %v mixes string and numeric values", field, op)
+			}
+		}
+		if op == OpLike && numeric {
+			return c, fmt.Errorf("filter %s LIKE takes a string pattern", field)
+		}
+		if op == OpBetween && compareValues(c.Values[0], c.Values[1]) > 0 {
+			return c, fmt.Errorf("filter %s BETWEEN: lower bound %v is greater than upper bound %v", field, c.Values[0], c.Values[1])
+		}
+	}
+	return c, nil
+}
+
+// Numeric 条件的值是否为数字
+func (c Condition) Numeric() bool {
+	if len(c.Values) == 0 {
+		return false
+	}
+	_, ok := c.Values[0].(float64)
+	return ok
+}
+
+// FilterValue 按值的类型填充 StrValues 或 FloatValues
+func (c Condition) FilterValue() *pb.FilterValue {
+	value := &pb.FilterValue{}
+	for _, v := range c.Values {
+		switch v := v.(type) {
+		case string:
+			value.StrValues = append(value.StrValues, v)
+		case float64:
+			value.FloatValues = append(value.FloatValues, v)
+		}
+	}
+	return value
+}
+
+func (c Condition) String() string {
+	values := make([]string, len(c.Values))
+	for i, v := range c.Values {
+		values[i] = formatValue(v)
+	}
+	switch c.Op {
+	case OpIsNull, OpIsNotNull:
+		return fmt.Sprintf("%s %v", c.Field, c.Op)
+	case OpIn, OpNotIn:
+		return fmt.Sprintf("%s %v (%s)", c.Field, c.Op, strings.Join(values, ", "))
+	case OpBetween:
+		return fmt.Sprintf("%s BETWEEN %s AND %s", c.Field, values[0], values[1])
+	}
+	return fmt.Sprintf("%s %v %s", c.Field, c.Op, strings.Join(values, ", "))
+}
+
+// Filters 请求中一一对应的三个过滤字段
+type Filters struct {
+	Names     []string
+	Operators []pb.FilterOperator
+	Values    []*pb.FilterValue
+}
+
+// Check 校验三个字段长度一致、每个值都非空且只使用一种值类型
+func Check(names []string, operators []pb.FilterOperator, values []*pb.FilterValue) error {
+	if len(names) != len(operators) || len(names) != len(values) {
+		return fmt.Errorf("misaligned filters: %d names, %d operators, %d values", len(names), len(operators), len(values))
+	}
+	for i, value := range values {
+		switch {
+		case names[i] == "":
+			return fmt.Errorf("filter %d has no field name", i)
+		case value == nil:
+			return fmt.Errorf("filter %d on %s has no value", i, names[i])
+		case len(value.StrValues) > 0 && len(value.FloatValues) > 0:
+			return fmt.Errorf("filter %d on %s sets both StrValues and FloatValues", i, names[i])
+		}
+	}
+	return nil
+}
+
+// Builder 链式构造发送给服务端的过滤条件，出错后忽略后续调用，由 Err/Build/Apply 返回第一个错误
+//
+//	filter.Where("name").In("Alice", "Bob").In("dept", "math").ApplyStream(request)
+//
+// 客户端目前只定义了 pb.FilterOperator_IN_OPERATOR，只有 In 能发送给服务端。NotIn、Eq、Gt、Between 等
+// 运算符仅在客户端计算：在 Builder 上调用时立即记录错误，需要时通过 query 包在客户端过滤，
+// 或用 NewCondition 构造条件自行计算。
+type Builder struct {
+	conditions []Condition
+	err        error
+}
+
+// New 创建空的 Builder
+func New() *Builder {
+	return &Builder{}
+}
+
+// Where 以 field 上的条件开始构造
+func Where(field string) *FieldBuilder {
+	return New().Where(field)
+}
+
+// Where 在 field 上追加条件
+func (b *Builder) Where(field string) *FieldBuilder {
+	return &FieldBuilder{b: b, field: field}
+}
+
+// Add 追加一个条件，运算符没有对应的 pb.FilterOperator 时记录错误
+func (b *Builder) Add(field string, op Op, values ...any) *Builder {
+	if b.err != nil {
+		return b
+	}
+	c, err := NewCondition(field, op, values...)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	if _, err := op.Proto(); err != nil {
+		b.err = fmt.Errorf("filter %v: %v", c, err)
+		return b
+	}
+	b.conditions = append(b.conditions, c)
+	return b
+}
+
+// In field 的值在 values 中
+func (b *Builder) In(field string, values ...any) *Builder {
+	return b.Add(field, OpIn, values...)
+}
+
+// NotIn field 的值不在 values 中，仅客户端计算，服务端不支持，调用即记录错误
+func (b *Builder) NotIn(field string, values ...any) *Builder {
+	return b.Add(field, OpNotIn, values...)
+}
+
+// Eq field 等于 value，仅客户端计算，服务端不支持，调用即记录错误
+func (b *Builder) Eq(field string, value any) *Builder {
+	return b.Add(field, OpEq, value)
+}
+
+// Ne field 不等于 value，仅客户端计算，服务端不支持，调用即记录错误
+func (b *Builder) Ne(field string, value any) *Builder {
+	return b.Add(field, OpNe, value)
+}
+
+// Gt field 大于 value，仅客户端计算，服务端不支持，调用即记录错误
+func (b *Builder) Gt(field string, value any) *Builder {
+	return b.Add(field, OpGt, value)
+}
+
+// Ge field 大于等于 value，仅客户端计算，服务端不支持，调用即记录错误
+func (b *Builder) Ge(field string, value any) *Builder {
+	return b.Add(field, OpGe, value)
+}
+
+// Lt field 小于 value，仅客户端计算，服务端不支持，调用即记录错误
+func (b *Builder) Lt(field string, value any) *Builder {
+	return b.Add(field, OpLt, value)
+}
+
+// Le field 小于等于 value，仅客户端计算，服务端不支持，调用即记录错误
+func (b *Builder) Le(field string, value any) *Builder {
+	return b.Add(field, OpLe, value)
+}
+
+// Between field 在 [lo, hi] 之间，仅客户端计算，服务端不支持，调用即记录错误
+func (b *Builder) Between(field string, lo, hi any) *Builder {
+	return b.Add(field, OpBetween, lo, hi)
+}
+
+// Like field 匹配 pattern，仅客户端计算，服务端不支持，调用即记录错误
+func (b *Builder) Like(field string, pattern string) *Builder {
+	return b.Add(field, OpLike, pattern)
+}
+
+// IsNull field 为空，仅客户端计算，服务端不支持，调用即记录错误
+func (b *Builder) IsNull(field string) *Builder {
+	return b.Add(field, OpIsNull)
+}
+
+// IsNotNull field 不为空，仅客户端计算，服务端不支持，调用即记录错误
+func (b *Builder) IsNotNull(field string) *Builder {
+	return b.Add(field, OpIsNotNull)
+}
+
+// Conditions 返回已添加的条件
+func (b *Builder) Conditions() []Condition {
+	return b.conditions
+}
+
+// Err 返回构造过程中的第一个错误
+func (b *Builder) Err() error {
+	return b.err
+}
+
+// Build 将条件转换为请求字段
+func (b *Builder) Build() (*Filters, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	filters := &Filters{}
+	for _, c := range b.conditions {
+		op, err := c.Op.Proto()
+		if err != nil {
+			return nil, fmt.Errorf("filter %v: %v", c, err)
+		}
+		filters.Names = append(filters.Names, c.Field)
+		filters.Operators = append(filters.Operators, op)
+		filters.Values = append(filters.Values, c.FilterValue())
+	}
+	return filters, nil
+}
+
+// ApplyStream 将条件追加到 StreamReadRequest，已有的过滤字段必须对齐
+func (b *Builder) ApplyStream(request *pb.StreamReadRequest) error {
+	if request == nil {
+		return errors.New("nil StreamReadRequest")
+	}
+	filters, err := b.build(request.FilterNames, request.FilterOperators, request.FilterValues)
+	if err != nil {
+		return err
+	}
+	request.FilterNames, request.FilterOperators, request.FilterValues = filters.Names, filters.Operators, filters.Values
+	return nil
+}
+
+// ApplyInternal 将条件追加到 InternalReadRequest，已有的过滤字段必须对齐
+func (b *Builder) ApplyInternal(request *pb.InternalReadRequest) error {
+	if request == nil {
+		return errors.New("nil InternalReadRequest")
+	}
+	filters, err := b.build(request.FilterNames, request.FilterOperators, request.FilterValues)
+	if err != nil {
+		return err
+	}
+	request.FilterNames, request.FilterOperators, request.FilterValues = filters.Names, filters.Operators, filters.Values
+	return nil
+}
+
+// build 校验请求中已有的过滤字段并在其后追加新条件
+func (b *Builder) build(names []string, operators []pb.FilterOperator, values []*pb.FilterValue) (*Filters, error) {
+	if err := Check(names, operators, values); err != nil {
+		return nil, fmt.Errorf("existing request filters: %v", err)
+	}
+	filters, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return &Filters{
+		Names:     append(names[:len(names):len(names)], filters.Names...),
+		Operators: append(operators[:len(operators):len(operators)], filters.Operators...),
+		Values:    append(values[:len(values):len(values)], filters.Values...),
+	}, nil
+}
+
+// FieldBuilder 某个字段上的条件，调用运算符方法后回到 Builder
+type FieldBuilder struct {
+	b     *Builder
+	field string
+}
+
+func (f *FieldBuilder) In(values ...any) *Builder {
+	return f.b.In(f.field, values...)
+}
+
+func (f *FieldBuilder) NotIn(values ...any) *Builder {
+	return f.b.NotIn(f.field, values...)
+}
+
+func (f *FieldBuilder) Eq(value any) *Builder {
+	return f.b.Eq(f.field, value)
+}
+
+func (f *FieldBuilder) Ne(value any) *Builder {
+	return f.b.Ne(f.field, value)
+}
+
+func (f *FieldBuilder) Gt(value any) *Builder {
+	return f.b.Gt(f.field, value)
+}
+
+func (f *FieldBuilder) Ge(value any) *Builder {
+	return f.b.Ge(f.field, value)
+}
+
+func (f *FieldBuilder) Lt(value any) *Builder {
+	return f.b.Lt(f.field, value)
+}
+
+func (f *FieldBuilder) Le(value any) *Builder {
+	return f.b.Le(f.field, value)
+}
+
+func (f *FieldBuilder) Between(lo, hi any) *Builder {
+	return f.b.Between(f.field, lo, hi)
+}
+
+func (f *FieldBuilder) Like(pattern string) *Builder {
+	return f.b.Like(f.field, pattern)
+}
+
+func (f *FieldBuilder) IsNull() *Builder {
+	return f.b.IsNull(f.field)
+}
+
+func (f *FieldBuilder) IsNotNull() *Builder {
+	return f.b.IsNotNull(f.field)
+}
@@ -0,0 +1,174 @@
+package filter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"github.com/shopspring/decimal"
+)
+
+func TestParseOp(t *testing.T) {
+	tests := []struct {
+		name string
+		want Op
+	}{
+		{"IN", OpIn},
+		{"in_operator", OpIn},
+		{"IN_OPERATOR", OpIn},
+		{"not in", OpNotIn},
+		{"NOT_IN", OpNotIn},
+		{">=", OpGe},
+		{"gte", OpGe},
+		{"<>", OpNe},
+		{"==", OpEq},
+		{"BETWEEN", OpBetween},
+		{"is  not null", OpIsNotNull},
+		{"IS_NULL", OpIsNull},
+	}
+	for _, tt := range tests {
+		got, err := ParseOp(tt.name)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseOp(%q) = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := ParseOp("CONTAINS"); err == nil {
+		t.Error("ParseOp(CONTAINS) succeeded")
+	}
+}
+
+func TestProto(t *testing.T) {
+	if got, err := OpIn.Proto(); err != nil || got != pb.FilterOperator_IN_OPERATOR {
+		t.Errorf("OpIn.Proto() = %v, %v, want IN_OPERATOR", got, err)
+	}
+	// 客户端没有定义的运算符不能发送给服务端
+	if _, err := OpGe.Proto(); err == nil {
+		t.Error("OpGe.Proto() succeeded")
+	}
+	if _, err := Op(100).Proto(); err == nil {
+		t.Error("Op(100).Proto() succeeded")
+	}
+}
+
+func TestNewCondition(t *testing.T) {
+	tests := []struct {
+		name   string
+		field  string
+		op     Op
+		values []any
+		want   []any
+		err    string
+	}{
+		{name: "strings", field: "name", op: OpIn, values: []any{"Alice", "Bob"}, want: []any{"Alice", "Bob"}},
+		{name: "slice expanded", field: "id", op: OpIn, values: []any{[]int{1, 2}, int64(3)}, want: []any{1.0, 2.0, 3.0}},
+		{name: "decimal", field: "gpa", op: OpGe, values: []any{decimal.RequireFromString("3.5")}, want: []any{3.5}},
+		{name: "no values", field: "x", op: OpIsNull},
+		{name: "missing field", op: OpEq, values: []any{1}, err: "field name is required"},
+		{name: "too many values", field: "x", op: OpEq, values: []any{1, 2}, err: "takes 1 values"},
+		{name: "too few values", field: "x", op: OpIn, err: "at least 1"},
+		{name: "mixed types", field: "x", op: OpIn, values: []any{1, "a"}, err: "mixes string and numeric"},
+		{name: "null value", field: "x", op: OpEq, values: []any{nil}, err: "use IsNull"},
+		{name: "inexact integer", field: "x", op: OpEq, values: []any{int64(1) << 60}, err: "cannot be represented exactly"},
+		{name: "inexact decimal", field: "x", op: OpEq, values: []any{decimal.RequireFromString("0.12345678901234567890123")}, err: "cannot be represented exactly"},
+		{name: "numeric like", field: "x", op: OpLike, values: []any{1}, err: "string pattern"},
+		{name: "reversed between", field: "x", op: OpBetween, values: []any{5, 1}, err: "greater than upper bound"},
+		{name: "unsupported type", field: "x", op: OpEq, values: []any{true}, err: "unsupported filter value type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCondition(tt.field, tt.op, tt.values...)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(c.Values) != 0 || len(tt.want) != 0 {
+				if !reflect.DeepEqual(c.Values, tt.want) {
+					t.Errorf("Values = %v, want %v", c.Values, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuilderApply(t *testing.T) {
+	request := &pb.StreamReadRequest{
+		FilterNames:     []string{"dept"},
+		FilterOperators: []pb.FilterOperator{pb.FilterOperator_IN_OPERATOR},
+		FilterValues:    []*pb.FilterValue{{StrValues: []string{"math"}}},
+	}
+	if err := Where("id").In(1, 2).In("name", "Alice").ApplyStream(request); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(request.FilterNames, []string{"dept", "id", "name"}) {
+		t.Errorf("FilterNames = %q", request.FilterNames)
+	}
+	if len(request.FilterOperators) != 3 || len(request.FilterValues) != 3 {
+		t.Fatalf("misaligned filters: %d operators, %d values", len(request.FilterOperators), len(request.FilterValues))
+	}
+	if got := request.FilterValues[1].FloatValues; !reflect.DeepEqual(got, []float64{1, 2}) {
+		t.Errorf("FilterValues[1].FloatValues = %v", got)
+	}
+	if got := request.FilterValues[2].StrValues; !reflect.DeepEqual(got, []string{"Alice"}) {
+		t.Errorf("FilterValues[2].StrValues = %v", got)
+	}
+
+	// 第一个错误之后的调用被忽略，请求保持不变
+	internal := &pb.InternalReadRequest{}
+	b := Where("x").Eq(nil).In("y", 1)
+	if b.Err() == nil || len(b.Conditions()) != 0 {
+		t.Fatalf("Err = %v, Conditions = %v", b.Err(), b.Conditions())
+	}
+	if err := b.ApplyInternal(internal); err == nil || len(internal.FilterNames) != 0 {
+		t.Errorf("ApplyInternal = %v, FilterNames = %q", err, internal.FilterNames)
+	}
+
+	// 服务端不支持的运算符在调用时即记录错误
+	for _, unsupported := range []*Builder{
+		Where("score").Ge(60), Where("score").Gt(60), Where("score").Lt(60), Where("score").Le(60),
+		Where("name").Eq("Alice"), Where("name").Ne("Alice"), Where("name").NotIn("Alice"),
+		Where("score").Between(60, 90), Where("name").Like("A%"), Where("name").IsNull(), Where("name").IsNotNull(),
+	} {
+		if err := unsupported.Err(); err == nil || !strings.Contains(err.Error(), "not supported by the data service client") {
+			t.Errorf("Err = %v, want an unsupported operator error", err)
+		}
+		if len(unsupported.Conditions()) != 0 {
+			t.Errorf("unsupported condition was added: %v", unsupported.Conditions())
+		}
+		if err := unsupported.ApplyInternal(internal); err == nil || len(internal.FilterNames) != 0 {
+			t.Errorf("ApplyInternal = %v, FilterNames = %q", err, internal.FilterNames)
+		}
+	}
+
+	misaligned := &pb.StreamReadRequest{FilterNames: []string{"a"}}
+	if err := Where("id").In(1).ApplyStream(misaligned); err == nil {
+		t.Error("ApplyStream on misaligned filters succeeded")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		names  []string
+		ops    []pb.FilterOperator
+		values []*pb.FilterValue
+		ok     bool
+	}{
+		{name: "empty", ok: true},
+		{name: "aligned", names: []string{"a"}, ops: []pb.FilterOperator{pb.FilterOperator_IN_OPERATOR}, values: []*pb.FilterValue{{StrValues: []string{"x"}}}, ok: true},
+		{name: "misaligned", names: []string{"a"}},
+		{name: "nil value", names: []string{"a"}, ops: []pb.FilterOperator{pb.FilterOperator_IN_OPERATOR}, values: []*pb.FilterValue{nil}},
+		{name: "empty name", names: []string{""}, ops: []pb.FilterOperator{pb.FilterOperator_IN_OPERATOR}, values: []*pb.FilterValue{{}}},
+		{name: "both value types", names: []string{"a"}, ops: []pb.FilterOperator{pb.FilterOperator_IN_OPERATOR}, values: []*pb.FilterValue{{StrValues: []string{"x"}, FloatValues: []float64{1}}}},
+	}
+	for _, tt := range tests {
+		if err := Check(tt.names, tt.ops, tt.values); (err == nil) != tt.ok {
+			t.Errorf("%s: Check = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package filter
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// maxExactInt float64 能精确表示的最大整数
+const maxExactInt = 1 << 53
+
+// normalizeValues 展开切片参数，并将每个值转换为 string 或 float64：
+// 字符串类型放入 StrValues，整数、浮点数和 decimal.Decimal 放入 FloatValues，无法精确表示的数字报错
+func normalizeValues(values []any) ([]any, error) {
+	var out []any
+	for _, v := range values {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < rv.Len(); i++ {
+				n, err := normalizeValue(rv.Index(i).Interface())
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, n)
+			}
+			continue
+		}
+		n, err := normalizeValue(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func normalizeValue(v any) (any, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, fmt.Errorf("null values are not allowed, use IsNull/IsNotNull")
+	case decimal.Decimal:
+		// 按最短十进制表示往返比较，1.46 这类值可以使用，超出 float64 精度的值报错
+		f := v.InexactFloat64()
+		if !decimal.NewFromFloat(f).Equal(v) {
+			return nil, fmt.Errorf("decimal %s cannot be represented exactly as a float filter value", v)
+		}
+		return f, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := rv.Int()
+		if i > maxExactInt || i < -maxExactInt {
+			return nil, fmt.Errorf("integer %d cannot be represented exactly as a float filter value", i)
+		}
+		return float64(i), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := rv.Uint()
+		if u > maxExactInt {
+			return nil, fmt.Errorf("integer %d cannot be represented exactly as a float filter value", u)
+		}
+		return float64(u), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("invalid float filter value %v", f)
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("unsupported filter value type %T", v)
+}
+
+// compareValues 比较两个已统一类型的值
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case string:
+		return cmp.Compare(a, b.(string))
+	}
+	return 0
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return strconv.Quote(v)
+	}
+	return fmt.Sprint(v)
+}
@@ -14,7 +14,8 @@ import (
 	"github.com/apache/arrow/go/v15/arrow/memory"
 )
 
-func generateArrowFile(filePath string) error {
+// generateArrowFile 生成示例 Arrow 文件，compression 指定消息体的压缩方式
+func generateArrowFile(filePath string, compression utils.Compression) error {
 	pool := memory.NewGoAllocator()
 	schema := arrow.NewSchema([]arrow.Field{
 		{Name: "id", Type: arrow.PrimitiveTypes.Int32},
@@ -43,7 +44,8 @@ func generateArrowFile(filePath string) error {
 	}
 	defer file.Close()
 
-	writer, err := ipc.NewFileWriter(file, ipc.WithSchema(schema), ipc.WithAllocator(pool))
+	opts := append([]ipc.Option{ipc.WithSchema(schema), ipc.WithAllocator(pool)}, compression.IPCOptions()...)
+	writer, err := ipc.NewFileWriter(file, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create Arrow IPC writer: %v", err)
 	}
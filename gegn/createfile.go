@@ -1,15 +1,18 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/apache/arrow/go/v15/arrow"
 	"github.com/apache/arrow/go/v15/arrow/array"
 	"github.com/apache/arrow/go/v15/arrow/ipc"
 	"github.com/apache/arrow/go/v15/arrow/memory"
 	"os"
+	"test/utils"
 )
 
-func generateArrowFile(filePath string) error {
+// generateArrowFile 生成示例 Arrow 文件，compression 指定消息体的压缩方式
+func generateArrowFile(filePath string, compression utils.Compression) error {
 	// 创建内存池
 	pool := memory.NewGoAllocator()
 
@@ -53,7 +56,8 @@ func generateArrowFile(filePath string) error {
 	}
 	defer file.Close()
 
-	writer, err := ipc.NewFileWriter(file, ipc.WithSchema(schema), ipc.WithAllocator(pool))
+	opts := append([]ipc.Option{ipc.WithSchema(schema), ipc.WithAllocator(pool)}, compression.IPCOptions()...)
+	writer, err := ipc.NewFileWriter(file, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create Arrow IPC writer: %v", err)
 	}
@@ -74,8 +78,16 @@ func generateArrowFile(filePath string) error {
 }
 
 func main() {
+	compressionName := flag.String("compression", "none", "compress IPC bodies with none, lz4 (LZ4_FRAME) or zstd")
+	flag.Parse()
+	compression, err := utils.ParseCompression(*compressionName)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
 	filePath := "sample.arrow"
-	if err := generateArrowFile(filePath); err != nil {
+	if err := generateArrowFile(filePath, compression); err != nil {
 		fmt.Printf("Failed to generate Arrow file: %v\n", err)
 	}
 }
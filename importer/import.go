@@ -0,0 +1,113 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"test/rebatch"
+	"test/utils"
+)
+
+// DefaultMaxChunkBytes 单个 IPC 批次的默认上限，低于 gRPC 默认的 4MB 消息大小
+const DefaultMaxChunkBytes = rebatch.DefaultMaxChunkBytes
+
+// WriteFunc 写入一个 IPC stream 格式的批次
+type WriteFunc func(ctx context.Context, chunk []byte) error
+
+// Options 导入参数
+type Options struct {
+	MaxChunkBytes   int               // 单个批次的字节上限，默认 DefaultMaxChunkBytes
+	ContinueOnError bool              // 某个批次写入失败后继续写入后续批次
+	Verbose         bool              // 打印每个批次的写入结果
+	Compression     utils.Compression // IPC 消息体的压缩方式
+}
+
+// ChunkFailure 写入失败的批次，Offset 为该批次第一行在文件中的行号（从 0 开始）
+type ChunkFailure struct {
+	Index  int
+	Offset int64
+	Rows   int64
+	Err    error
+}
+
+// Result 导入结果
+type Result struct {
+	Rows       int64 // 成功写入的行数
+	FailedRows int64
+	Chunks     int // 成功写入的批次数
+	Bytes      int64
+	Failures   []ChunkFailure
+	// Compression 指定压缩方式时所有批次（包括失败的批次）压缩前后的字节数
+	Compression utils.CompressionStats
+}
+
+// Import 读取 reader 中的全部记录，切分为不超过 MaxChunkBytes 的 IPC 批次后依次调用 write；
+// 未开启 ContinueOnError 时遇到第一个失败的批次即返回，Result 中包含已写入的统计
+func Import(ctx context.Context, reader *Reader, write WriteFunc, opts Options) (*Result, error) {
+	if opts.MaxChunkBytes <= 0 {
+		opts.MaxChunkBytes = DefaultMaxChunkBytes
+	}
+
+	result := &Result{}
+	var offset int64
+	index := 0
+	emit := func(rows int64, chunk []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		index++
+		err := write(ctx, chunk)
+		if err != nil {
+			result.FailedRows += rows
+			result.Failures = append(result.Failures, ChunkFailure{Index: index, Offset: offset, Rows: rows, Err: err})
+			log.Printf("Chunk %d (rows %d-%d) failed: %v", index, offset, offset+rows-1, err)
+		} else {
+			result.Rows += rows
+			result.Chunks++
+			result.Bytes += int64(len(chunk))
+			if opts.Verbose {
+				log.Printf("Chunk %d: wrote %d rows, %d bytes", index, rows, len(chunk))
+			}
+		}
+		offset += rows
+		if err != nil && !opts.ContinueOnError {
+			return fmt.Errorf("chunk %d failed: %w", index, err)
+		}
+		return nil
+	}
+
+	ipcOpts := opts.Compression.IPCOptions()
+	for reader.Next() {
+		record := reader.Record()
+		var encoded int64
+		err := rebatch.Split(record, opts.MaxChunkBytes, func(rows int64, chunk []byte) error {
+			encoded += int64(len(chunk))
+			return emit(rows, chunk)
+		}, ipcOpts...)
+		if err != nil {
+			return result, err
+		}
+		if opts.Compression != utils.CompressionNone {
+			if err := result.Compression.AddRecord(record, encoded); err != nil {
+				return result, err
+			}
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return result, fmt.Errorf("failed to read records at row %d: %v", offset, err)
+	}
+	if len(result.Failures) > 0 {
+		return result, fmt.Errorf("%d of %d chunks failed", len(result.Failures), index)
+	}
+	return result, nil
+}
+
+// FailureError 汇总所有失败批次，便于打印
+func (r *Result) FailureError() error {
+	errs := make([]error, len(r.Failures))
+	for i, f := range r.Failures {
+		errs[i] = fmt.Errorf("chunk %d (rows %d-%d): %w", f.Index, f.Offset, f.Offset+f.Rows-1, f.Err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,187 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/13
+	@note: 读取本地 CSV、JSON Lines 或 Parquet 文件，切分为大小受限的 IPC 批次写入外部库或内部库
+
+*
+*/
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/csv"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/apache/arrow/go/v15/parquet/file"
+	"github.com/apache/arrow/go/v15/parquet/pqarrow"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"test/sink"
+)
+
+const (
+	// DefaultBatchRows 每次从文件读取的行数
+	DefaultBatchRows = 4096
+	// DefaultInferRows 推断 schema 时采样的行数
+	DefaultInferRows = 1000
+)
+
+// ReadOptions 读取本地文件的参数
+type ReadOptions struct {
+	Schema     *arrow.Schema // 为空时根据文件内容推断，Parquet 使用文件自带的 schema
+	BatchRows  int           // 每批读取的行数，默认 DefaultBatchRows
+	InferRows  int           // 推断 schema 时采样的行数，默认 DefaultInferRows
+	Allocator  memory.Allocator
+	Delimiter  rune     // CSV 分隔符，默认逗号（.tsv 文件为制表符）
+	NoHeader   bool     // CSV 没有表头，列名为 f0, f1, ...
+	NullValues []string // CSV 中视为 null 的内容，空字符串始终视为 null
+}
+
+func (o *ReadOptions) setDefaults() {
+	if o.BatchRows <= 0 {
+		o.BatchRows = DefaultBatchRows
+	}
+	if o.InferRows <= 0 {
+		o.InferRows = DefaultInferRows
+	}
+	if o.Allocator == nil {
+		o.Allocator = memory.NewGoAllocator()
+	}
+	if o.Delimiter == 0 {
+		o.Delimiter = ','
+	}
+}
+
+// Reader 逐批读取本地文件中的记录
+//
+//	reader, err := importer.Open(ctx, "students.csv", sink.FormatCSV, importer.ReadOptions{})
+//	defer reader.Close()
+//	for reader.Next() {
+//		record := reader.Record() // 下一次 Next 后失效
+//	}
+type Reader struct {
+	rr    array.RecordReader
+	close func() error
+}
+
+// Open 打开本地文件，format 为空时根据扩展名推断；.tsv 文件默认使用制表符分隔
+func Open(ctx context.Context, path string, format sink.Format, opts ReadOptions) (*Reader, error) {
+	if opts.Delimiter == 0 && strings.EqualFold(filepath.Ext(path), ".tsv") {
+		opts.Delimiter = '\t'
+	}
+	opts.setDefaults()
+	if format == "" {
+		var err error
+		if format, err = sink.FormatFromPath(path); err != nil {
+			return nil, err
+		}
+	}
+
+	switch format {
+	case sink.FormatCSV:
+		return openCSV(path, opts)
+	case sink.FormatJSONL:
+		return openJSONL(path, opts)
+	case sink.FormatParquet:
+		return openParquet(ctx, path, opts)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func openCSV(path string, opts ReadOptions) (*Reader, error) {
+	schema := opts.Schema
+	if schema == nil {
+		var err error
+		if schema, err = inferCSVSchema(path, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	rr := csv.NewReader(f, schema,
+		csv.WithHeader(!opts.NoHeader),
+		csv.WithComma(opts.Delimiter),
+		csv.WithChunk(opts.BatchRows),
+		csv.WithNullReader(true, append([]string{""}, opts.NullValues...)...),
+		csv.WithAllocator(opts.Allocator),
+	)
+	return &Reader{rr: rr, close: f.Close}, nil
+}
+
+func openJSONL(path string, opts ReadOptions) (*Reader, error) {
+	schema := opts.Schema
+	if schema == nil {
+		var err error
+		if schema, err = inferJSONSchema(path, opts.InferRows); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	rr := array.NewJSONReader(f, schema, array.WithChunk(opts.BatchRows), array.WithAllocator(opts.Allocator))
+	return &Reader{rr: rr, close: f.Close}, nil
+}
+
+func openParquet(ctx context.Context, path string, opts ReadOptions) (*Reader, error) {
+	if opts.Schema != nil {
+		return nil, errors.New("schema cannot be specified for Parquet files")
+	}
+
+	pf, err := file.OpenParquetFile(path, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Parquet file: %v", err)
+	}
+	fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{BatchSize: int64(opts.BatchRows)}, opts.Allocator)
+	if err != nil {
+		pf.Close()
+		return nil, fmt.Errorf("failed to create Parquet reader: %v", err)
+	}
+	rr, err := fr.GetRecordReader(ctx, nil, nil)
+	if err != nil {
+		pf.Close()
+		return nil, fmt.Errorf("failed to create Parquet record reader: %v", err)
+	}
+	return &Reader{rr: rr, close: pf.Close}, nil
+}
+
+// Schema 返回读取记录的 schema
+func (r *Reader) Schema() *arrow.Schema {
+	return r.rr.Schema()
+}
+
+// Next 读取下一批记录
+func (r *Reader) Next() bool {
+	return r.rr.Next()
+}
+
+// Record 返回当前批次，调用方需要长期持有时应自行 Retain
+func (r *Reader) Record() arrow.Record {
+	return r.rr.Record()
+}
+
+// Err 返回读取过程中的错误，正常读完时为 nil
+func (r *Reader) Err() error {
+	if err := r.rr.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// Close 释放读取器并关闭文件
+func (r *Reader) Close() error {
+	r.rr.Release()
+	return r.close()
+}
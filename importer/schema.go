@@ -0,0 +1,362 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseSchema 解析 name:type 形式、逗号分隔的 schema，所有列均可为 null，例如
+//
+//	id:int32,name:string,gpa:float64,amount:decimal(10,2),created:timestamp[ms]
+//
+// 支持 bool、int8~int64、uint8~uint64、float32、float64、string、binary、date32、
+// timestamp[s|ms|us|ns]（默认 us）和 decimal(precision,scale)
+func ParseSchema(spec string) (*arrow.Schema, error) {
+	var fields []arrow.Field
+	for _, item := range splitTopLevel(spec) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, typeName, ok := strings.Cut(item, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid column %q, expected name:type", item)
+		}
+		dt, err := parseType(strings.TrimSpace(typeName))
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", name, err)
+		}
+		fields = append(fields, arrow.Field{Name: strings.TrimSpace(name), Type: dt, Nullable: true})
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("empty schema")
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+// splitTopLevel 按逗号拆分，忽略括号内的逗号
+func splitTopLevel(spec string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range spec {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, spec[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, spec[start:])
+}
+
+func parseType(name string) (arrow.DataType, error) {
+	lower := strings.ToLower(name)
+	switch lower {
+	case "bool", "boolean":
+		return arrow.FixedWidthTypes.Boolean, nil
+	case "int8":
+		return arrow.PrimitiveTypes.Int8, nil
+	case "int16":
+		return arrow.PrimitiveTypes.Int16, nil
+	case "int32", "int":
+		return arrow.PrimitiveTypes.Int32, nil
+	case "int64", "bigint":
+		return arrow.PrimitiveTypes.Int64, nil
+	case "uint8":
+		return arrow.PrimitiveTypes.Uint8, nil
+	case "uint16":
+		return arrow.PrimitiveTypes.Uint16, nil
+	case "uint32":
+		return arrow.PrimitiveTypes.Uint32, nil
+	case "uint64":
+		return arrow.PrimitiveTypes.Uint64, nil
+	case "float32", "float":
+		return arrow.PrimitiveTypes.Float32, nil
+	case "float64", "double":
+		return arrow.PrimitiveTypes.Float64, nil
+	case "string", "utf8":
+		return arrow.BinaryTypes.String, nil
+	case "binary":
+		return arrow.BinaryTypes.Binary, nil
+	case "date32", "date":
+		return arrow.FixedWidthTypes.Date32, nil
+	case "timestamp":
+		return &arrow.TimestampType{Unit: arrow.Microsecond}, nil
+	}
+
+	if unit, ok := strings.CutPrefix(lower, "timestamp["); ok && strings.HasSuffix(unit, "]") {
+		switch strings.TrimSuffix(unit, "]") {
+		case "s":
+			return &arrow.TimestampType{Unit: arrow.Second}, nil
+		case "ms":
+			return &arrow.TimestampType{Unit: arrow.Millisecond}, nil
+		case "us":
+			return &arrow.TimestampType{Unit: arrow.Microsecond}, nil
+		case "ns":
+			return &arrow.TimestampType{Unit: arrow.Nanosecond}, nil
+		}
+	}
+	if args, ok := strings.CutPrefix(lower, "decimal("); ok && strings.HasSuffix(args, ")") {
+		p, s, _ := strings.Cut(strings.TrimSuffix(args, ")"), ",")
+		precision, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return nil, fmt.Errorf("invalid decimal precision in %q", name)
+		}
+		scale := 0
+		if s != "" {
+			if scale, err = strconv.Atoi(strings.TrimSpace(s)); err != nil {
+				return nil, fmt.Errorf("invalid decimal scale in %q", name)
+			}
+		}
+		if precision < 1 || precision > 38 || scale < 0 || scale > precision {
+			return nil, fmt.Errorf("invalid decimal precision/scale in %q", name)
+		}
+		return &arrow.Decimal128Type{Precision: int32(precision), Scale: int32(scale)}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %q", name)
+}
+
+// inferCSVSchema 采样文件开头的若干行推断每列的类型
+func inferCSVSchema(path string, opts ReadOptions) (*arrow.Schema, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = opts.Delimiter
+	r.ReuseRecord = true
+
+	nulls := map[string]bool{"": true}
+	for _, v := range opts.NullValues {
+		nulls[v] = true
+	}
+
+	var names []string
+	var types []arrow.DataType
+	for rows := 0; rows <= opts.InferRows; rows++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %v", err)
+		}
+		if names == nil {
+			names = make([]string, len(record))
+			types = make([]arrow.DataType, len(record))
+			for i := range names {
+				names[i] = fmt.Sprintf("f%d", i)
+			}
+			if !opts.NoHeader {
+				copy(names, record)
+				continue
+			}
+		}
+		for i, value := range record {
+			if !nulls[value] {
+				types[i] = mergeType(types[i], inferStringType(value))
+			}
+		}
+	}
+	if names == nil {
+		return nil, errors.New("empty CSV file, cannot infer schema")
+	}
+
+	fields := make([]arrow.Field, len(names))
+	for i, name := range names {
+		fields[i] = arrow.Field{Name: name, Type: resolveType(types[i]), Nullable: true}
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+// inferStringType 推断 CSV 单元格的类型
+func inferStringType(value string) arrow.DataType {
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return arrow.PrimitiveTypes.Int64
+	}
+	if strings.EqualFold(value, "true") || strings.EqualFold(value, "false") {
+		return arrow.FixedWidthTypes.Boolean
+	}
+	if _, err := time.Parse("2006-01-02", value); err == nil {
+		return arrow.FixedWidthTypes.Date32
+	}
+	if _, err := arrow.TimestampFromString(value, arrow.Microsecond); err == nil {
+		return &arrow.TimestampType{Unit: arrow.Microsecond}
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return arrow.PrimitiveTypes.Float64
+	}
+	return arrow.BinaryTypes.String
+}
+
+// inferJSONSchema 采样文件开头的若干行推断 schema，列顺序为首次出现的顺序
+func inferJSONSchema(path string, inferRows int) (*arrow.Schema, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	var root *arrow.StructType
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line, rows := 0, 0; rows < inferRows && scanner.Scan(); {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		rows++
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		dt, err := inferJSONValue(dec)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		st, ok := dt.(*arrow.StructType)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a JSON object", line)
+		}
+		if root == nil {
+			root = st
+		} else {
+			root = mergeType(root, st).(*arrow.StructType)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read JSON lines: %v", err)
+	}
+	if root == nil {
+		return nil, errors.New("empty JSON lines file, cannot infer schema")
+	}
+
+	fields := root.Fields()
+	for i := range fields {
+		fields[i].Type = resolveType(fields[i].Type)
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+// inferJSONValue 读取一个 JSON 值并推断类型，null 返回 arrow.Null
+func inferJSONValue(dec *json.Decoder) (arrow.DataType, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := tok.(type) {
+	case nil:
+		return arrow.Null, nil
+	case bool:
+		return arrow.FixedWidthTypes.Boolean, nil
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return arrow.PrimitiveTypes.Int64, nil
+		}
+		return arrow.PrimitiveTypes.Float64, nil
+	case string:
+		return arrow.BinaryTypes.String, nil
+	case json.Delim:
+		if v == '[' {
+			var elem arrow.DataType = arrow.Null
+			for dec.More() {
+				dt, err := inferJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				elem = mergeType(elem, dt)
+			}
+			_, err := dec.Token()
+			return arrow.ListOf(elem), err
+		}
+		var fields []arrow.Field
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			dt, err := inferJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, arrow.Field{Name: key.(string), Type: dt, Nullable: true})
+		}
+		_, err := dec.Token()
+		return arrow.StructOf(fields...), err
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", tok)
+}
+
+// mergeType 合并同一列中观察到的两种类型，nil 和 arrow.Null 表示尚未确定；
+// 整数与浮点数合并为浮点数，日期与时间戳合并为时间戳，其他冲突退化为字符串
+func mergeType(a, b arrow.DataType) arrow.DataType {
+	switch {
+	case a == nil || a.ID() == arrow.NULL:
+		return b
+	case b == nil || b.ID() == arrow.NULL:
+		return a
+	case arrow.TypeEqual(a, b):
+		return a
+	}
+
+	switch ids := [2]arrow.Type{a.ID(), b.ID()}; ids {
+	case [2]arrow.Type{arrow.INT64, arrow.FLOAT64}, [2]arrow.Type{arrow.FLOAT64, arrow.INT64}:
+		return arrow.PrimitiveTypes.Float64
+	case [2]arrow.Type{arrow.DATE32, arrow.TIMESTAMP}:
+		return b
+	case [2]arrow.Type{arrow.TIMESTAMP, arrow.DATE32}:
+		return a
+	case [2]arrow.Type{arrow.LIST, arrow.LIST}:
+		return arrow.ListOf(mergeType(a.(*arrow.ListType).Elem(), b.(*arrow.ListType).Elem()))
+	case [2]arrow.Type{arrow.STRUCT, arrow.STRUCT}:
+		fields := a.(*arrow.StructType).Fields()
+		index := make(map[string]int, len(fields))
+		for i, f := range fields {
+			index[f.Name] = i
+		}
+		for _, f := range b.(*arrow.StructType).Fields() {
+			if i, ok := index[f.Name]; ok {
+				fields[i].Type = mergeType(fields[i].Type, f.Type)
+			} else {
+				index[f.Name] = len(fields)
+				fields = append(fields, f)
+			}
+		}
+		return arrow.StructOf(fields...)
+	}
+	return arrow.BinaryTypes.String
+}
+
+// resolveType 将仍未确定（全为 null）的类型替换为字符串
+func resolveType(dt arrow.DataType) arrow.DataType {
+	if dt == nil || dt.ID() == arrow.NULL {
+		return arrow.BinaryTypes.String
+	}
+	switch t := dt.(type) {
+	case *arrow.ListType:
+		return arrow.ListOf(resolveType(t.Elem()))
+	case *arrow.StructType:
+		fields := t.Fields()
+		for i := range fields {
+			fields[i].Type = resolveType(fields[i].Type)
+		}
+		return arrow.StructOf(fields...)
+	}
+	return dt
+}
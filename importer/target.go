@@ -0,0 +1,49 @@
+package importer
+
+import (
+	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ExternalWriter 通过 WriteExternalDBData 写入外部库，template 提供 AssetName、TableName 等字段，
+// 每个批次复制一份请求并填入 ArrowBatch
+func ExternalWriter(dataServiceClient *client.DataServiceClient, template *pb.WriterExternalDataRequest) WriteFunc {
+	return func(ctx context.Context, chunk []byte) error {
+		request := &pb.WriterExternalDataRequest{
+			ArrowBatch:  chunk,
+			PlatformId:  template.PlatformId,
+			AssetName:   template.AssetName,
+			TableName:   template.TableName,
+			ChainInfoId: template.ChainInfoId,
+		}
+		_, err := dataServiceClient.WriteExternalDBData(ctx, request)
+		return err
+	}
+}
+
+// InternalWriter 通过 WriteInternalDBData 写入内部库
+func InternalWriter(dataServiceClient *client.DataServiceClient, dbName, tableName string) WriteFunc {
+	return func(ctx context.Context, chunk []byte) error {
+		request := &pb.WriterInternalDataRequest{
+			ArrowBatch: chunk,
+			DbName:     dbName,
+			TableName:  tableName,
+		}
+		response := dataServiceClient.WriteInternalDBData(ctx, []*pb.WriterInternalDataRequest{request})
+		return responseError(response)
+	}
+}
+
+// responseError WriteInternalDBData 不返回 error，从响应中提取失败信息
+func responseError(response *pb.Response) error {
+	if response == nil {
+		return errors.New("empty response")
+	}
+	if !response.Success {
+		return fmt.Errorf("write failed: %s", response.Message)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package iterator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+)
+
+// WithByteStream 将 Source 返回的数据块视为同一段字节流，而不是每块一个完整的 IPC stream。
+// 用于读取客户端上传的 Arrow IPC stream 文件，这类对象按固定大小切分，块边界与消息边界无关；
+// 字节流中首尾相接的多个 stream 依次读取
+func WithByteStream() Option {
+	return func(it *RecordIterator) {
+		it.stream = &sourceReader{it: it}
+	}
+}
+
+// sourceReader 把 Source 适配为 io.Reader
+type sourceReader struct {
+	it  *RecordIterator
+	buf []byte
+	err error // Source 返回的非 io.EOF 错误
+	eof bool
+}
+
+func (r *sourceReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		chunk, err := r.it.src()
+		if err == io.EOF || (err == nil && string(chunk) == EOFMarker) {
+			r.eof = true
+			continue
+		}
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+		if len(chunk) > 0 {
+			r.it.chunks++
+			r.it.bytes += int64(len(chunk))
+		}
+		r.buf = chunk
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// nextStream 在字节流模式下创建下一个 IPC 读取器，字节流结束时返回 false
+func (it *RecordIterator) nextStream() bool {
+	reader, err := ipc.NewReader(it.stream, ipc.WithAllocator(it.allocator))
+	switch {
+	case it.stream.err != nil:
+		it.fail(fmt.Errorf("error receiving data: %w", it.stream.err))
+		return false
+	case errors.Is(err, io.EOF) && len(it.stream.buf) == 0:
+		it.finish()
+		return false
+	case err != nil:
+		it.fail(fmt.Errorf("failed to create Arrow reader: %v", err))
+		return false
+	}
+	it.reader = reader
+	it.schema = reader.Schema()
+	return true
+}
@@ -0,0 +1,274 @@
+package iterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/compute"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"strings"
+)
+
+// DriftPolicy 后续批次的 schema 与第一批不同时的处理方式
+type DriftPolicy int
+
+const (
+	DriftFail   DriftPolicy = iota // 返回 *SchemaDriftError 并结束读取
+	DriftCast                      // 可兼容的变化转换为第一批的 schema，无法转换时返回 *SchemaDriftError
+	DriftRecord                    // 原样返回并记录 DriftEvent
+)
+
+// maxDriftEvents Events 最多保留的变化记录数
+const maxDriftEvents = 100
+
+// ParseDriftPolicy 解析 fail、cast、record
+func ParseDriftPolicy(name string) (DriftPolicy, error) {
+	switch strings.ToLower(name) {
+	case "", "fail":
+		return DriftFail, nil
+	case "cast":
+		return DriftCast, nil
+	case "record":
+		return DriftRecord, nil
+	}
+	return DriftFail, fmt.Errorf("unknown schema drift policy %q, expected fail, cast or record", name)
+}
+
+func (p DriftPolicy) String() string {
+	switch p {
+	case DriftCast:
+		return "cast"
+	case DriftRecord:
+		return "record"
+	}
+	return "fail"
+}
+
+// DriftEvent 一个批次相对第一批的 schema 变化
+type DriftEvent struct {
+	Batch   int // 从 1 开始的批次序号
+	Schema  *arrow.Schema
+	Changes []string
+	Cast    bool // 是否已转换为第一批的 schema
+}
+
+func (e DriftEvent) String() string {
+	s := fmt.Sprintf("batch %d: %s", e.Batch, strings.Join(e.Changes, "; "))
+	if e.Cast {
+		s += " (cast to the pinned schema)"
+	}
+	return s
+}
+
+// SchemaDriftError 批次的 schema 与第一批不同且无法按策略处理
+type SchemaDriftError struct {
+	DriftEvent
+	Err error // 转换失败的原因，策略为 DriftFail 时为 nil
+}
+
+func (e *SchemaDriftError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("schema drift in batch %d cannot be cast: %v (%s)", e.Batch, e.Err, strings.Join(e.Changes, "; "))
+	}
+	return fmt.Sprintf("schema drift in batch %d: %s", e.Batch, strings.Join(e.Changes, "; "))
+}
+
+func (e *SchemaDriftError) Unwrap() error {
+	return e.Err
+}
+
+// SchemaGuard 以第一批的 schema 为准检查后续批次，非并发安全
+//
+// 每段 ReadStream/ReadOSSData 响应都是带有自己 schema 的独立 IPC stream，
+// 服务端改表或多个数据源拼接时后续批次的列和类型可能与第一批不同。
+type SchemaGuard struct {
+	// OnDrift 检测到变化并按策略放行或转换时回调
+	OnDrift func(DriftEvent)
+
+	policy  DriftPolicy
+	mem     memory.Allocator
+	pinned  *arrow.Schema
+	batches int
+	drifts  int
+	events  []DriftEvent
+}
+
+// NewSchemaGuard 创建检查器，mem 为空时使用 GoAllocator
+func NewSchemaGuard(policy DriftPolicy, mem memory.Allocator) *SchemaGuard {
+	if mem == nil {
+		mem = memory.NewGoAllocator()
+	}
+	return &SchemaGuard{policy: policy, mem: mem}
+}
+
+// Policy 返回处理策略
+func (g *SchemaGuard) Policy() DriftPolicy {
+	return g.policy
+}
+
+// Schema 返回第一批的 schema，尚未检查任何批次时为 nil
+func (g *SchemaGuard) Schema() *arrow.Schema {
+	return g.pinned
+}
+
+// Drifts 返回放行或转换的变化批次数
+func (g *SchemaGuard) Drifts() int {
+	return g.drifts
+}
+
+// Events 返回放行或转换的变化，最多保留前 100 条
+func (g *SchemaGuard) Events() []DriftEvent {
+	return g.events
+}
+
+// Check 检查记录的 schema，返回由调用方释放的记录：schema 一致或按 DriftRecord 放行时为原记录（增加引用计数），
+// 按 DriftCast 转换时为新记录
+func (g *SchemaGuard) Check(record arrow.Record) (arrow.Record, error) {
+	event, drifted := g.compare(record.Schema())
+	if !drifted {
+		record.Retain()
+		return record, nil
+	}
+	switch g.policy {
+	case DriftRecord:
+		g.record(event)
+		record.Retain()
+		return record, nil
+	case DriftCast:
+		cast, err := g.cast(record)
+		if err != nil {
+			return nil, &SchemaDriftError{DriftEvent: event, Err: err}
+		}
+		event.Cast = true
+		g.record(event)
+		return cast, nil
+	}
+	return nil, &SchemaDriftError{DriftEvent: event}
+}
+
+// CheckSchema 只检查 schema，用于不解码记录、原样转发数据的场景，DriftCast 时任何变化都视为无法转换
+func (g *SchemaGuard) CheckSchema(schema *arrow.Schema) error {
+	event, drifted := g.compare(schema)
+	if !drifted {
+		return nil
+	}
+	switch g.policy {
+	case DriftRecord:
+		g.record(event)
+		return nil
+	case DriftCast:
+		return &SchemaDriftError{DriftEvent: event, Err: errors.New("batches forwarded without decoding cannot be cast")}
+	}
+	return &SchemaDriftError{DriftEvent: event}
+}
+
+// compare 计数并与第一批比较，第一批直接固定为基准
+func (g *SchemaGuard) compare(schema *arrow.Schema) (DriftEvent, bool) {
+	g.batches++
+	if g.pinned == nil {
+		g.pinned = schema
+		return DriftEvent{}, false
+	}
+	if g.pinned.Equal(schema) {
+		return DriftEvent{}, false
+	}
+	changes := diffSchemas(g.pinned, schema)
+	if len(changes) == 0 {
+		return DriftEvent{}, false
+	}
+	return DriftEvent{Batch: g.batches, Schema: schema, Changes: changes}, true
+}
+
+func (g *SchemaGuard) record(event DriftEvent) {
+	g.drifts++
+	if len(g.events) < maxDriftEvents {
+		g.events = append(g.events, event)
+	}
+	if g.OnDrift != nil {
+		g.OnDrift(event)
+	}
+}
+
+// cast 按列名对齐到第一批的 schema：缺少的可空列补空值，类型不同的列做安全转换（溢出、截断时报错），
+// 多出的列以及向不可空列写入空值视为不兼容
+func (g *SchemaGuard) cast(record arrow.Record) (arrow.Record, error) {
+	schema := record.Schema()
+	rows := record.NumRows()
+	for _, field := range schema.Fields() {
+		if !g.pinned.HasField(field.Name) {
+			return nil, fmt.Errorf("unexpected column %s", field.Name)
+		}
+	}
+
+	ctx := compute.WithAllocator(context.Background(), g.mem)
+	columns := make([]arrow.Array, 0, g.pinned.NumFields())
+	defer func() {
+		for _, column := range columns {
+			column.Release()
+		}
+	}()
+	for _, field := range g.pinned.Fields() {
+		indices := schema.FieldIndices(field.Name)
+		switch {
+		case len(indices) > 1:
+			return nil, fmt.Errorf("column %s appears %d times", field.Name, len(indices))
+		case len(indices) == 0 && !field.Nullable:
+			return nil, fmt.Errorf("missing non-nullable column %s", field.Name)
+		case len(indices) == 0:
+			columns = append(columns, array.MakeArrayOfNull(g.mem, field.Type, int(rows)))
+			continue
+		}
+		column := record.Column(indices[0])
+		if !field.Nullable && column.NullN() > 0 {
+			return nil, fmt.Errorf("column %s has %d nulls but is not nullable", field.Name, column.NullN())
+		}
+		if arrow.TypeEqual(column.DataType(), field.Type) {
+			column.Retain()
+			columns = append(columns, column)
+			continue
+		}
+		if !compute.CanCast(column.DataType(), field.Type) {
+			return nil, fmt.Errorf("column %s: cannot cast %s to %s", field.Name, column.DataType(), field.Type)
+		}
+		cast, err := compute.CastArray(ctx, column, compute.SafeCastOptions(field.Type))
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", field.Name, err)
+		}
+		columns = append(columns, cast)
+	}
+	return array.NewRecord(g.pinned, columns, rows), nil
+}
+
+// diffSchemas 列出 got 相对 pinned 的变化，忽略元数据
+func diffSchemas(pinned, got *arrow.Schema) []string {
+	var changes []string
+	reordered := false
+	for i, field := range pinned.Fields() {
+		indices := got.FieldIndices(field.Name)
+		if len(indices) == 0 {
+			changes = append(changes, fmt.Sprintf("missing column %s", field.Name))
+			continue
+		}
+		other := got.Field(indices[0])
+		if !arrow.TypeEqual(field.Type, other.Type) {
+			changes = append(changes, fmt.Sprintf("column %s: %s -> %s", field.Name, field.Type, other.Type))
+		}
+		if field.Nullable != other.Nullable {
+			changes = append(changes, fmt.Sprintf("column %s: nullable %t -> %t", field.Name, field.Nullable, other.Nullable))
+		}
+		if indices[0] != i {
+			reordered = true
+		}
+	}
+	for _, field := range got.Fields() {
+		if !pinned.HasField(field.Name) {
+			changes = append(changes, fmt.Sprintf("new column %s %s", field.Name, field.Type))
+		}
+	}
+	if reordered && len(changes) == 0 {
+		changes = append(changes, "column order changed")
+	}
+	return changes
+}
@@ -0,0 +1,218 @@
+package iterator_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"test/iterator"
+	"test/iterator/iteratortest"
+)
+
+var (
+	pinned = arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+	}, nil)
+	narrowID = arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int32},
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+	}, nil)
+	idOnly = arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+	}, nil)
+	nameOnly = arrow.NewSchema([]arrow.Field{
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+	}, nil)
+	extra = arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "age", Type: arrow.PrimitiveTypes.Int32},
+	}, nil)
+)
+
+// batchOf 一批 schema 和 JSON 数据
+type batchOf struct {
+	schema *arrow.Schema
+	rows   string
+}
+
+// guardedRows 经过 guard 读取各批数据，返回格式化的行、每批的 schema 和错误
+func guardedRows(t *testing.T, guard *iterator.SchemaGuard, batches ...batchOf) ([]string, []*arrow.Schema, error) {
+	t.Helper()
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+
+	src := &iteratortest.Source{}
+	for _, b := range batches {
+		src.Chunks = append(src.Chunks, iteratortest.NewSource(t, b.schema, b.rows).Chunks...)
+	}
+	it := src.Iterator(mem, iterator.WithSchemaGuard(guard))
+	defer it.Release()
+	var rows []string
+	var schemas []*arrow.Schema
+	for it.Next() {
+		record := it.Record()
+		schemas = append(schemas, record.Schema())
+		for i := 0; i < int(record.NumRows()); i++ {
+			values := make([]string, record.NumCols())
+			for j, column := range record.Columns() {
+				values[j] = column.ValueStr(i)
+			}
+			rows = append(rows, strings.Join(values, "|"))
+		}
+	}
+	return rows, schemas, it.Err()
+}
+
+func TestSchemaGuardFail(t *testing.T) {
+	guard := iterator.NewSchemaGuard(iterator.DriftFail, nil)
+	rows, _, err := guardedRows(t, guard,
+		batchOf{pinned, `[{"id": 1, "name": "a"}]`},
+		batchOf{pinned, `[{"id": 2, "name": "b"}]`},
+		batchOf{narrowID, `[{"id": 3, "name": "c"}]`},
+		batchOf{pinned, `[{"id": 4, "name": "d"}]`},
+	)
+	if !reflect.DeepEqual(rows, []string{"1|a", "2|b"}) {
+		t.Errorf("rows = %v, want the batches before the drift", rows)
+	}
+	var driftErr *iterator.SchemaDriftError
+	if !errors.As(err, &driftErr) {
+		t.Fatalf("err = %v, want *SchemaDriftError", err)
+	}
+	if driftErr.Batch != 3 || !reflect.DeepEqual(driftErr.Changes, []string{"column id: int64 -> int32"}) {
+		t.Errorf("drift = batch %d %v", driftErr.Batch, driftErr.Changes)
+	}
+	if guard.Drifts() != 0 || len(guard.Events()) != 0 {
+		t.Errorf("failed drift was recorded: %v", guard.Events())
+	}
+}
+
+func TestSchemaGuardCast(t *testing.T) {
+	tests := []struct {
+		name    string
+		drifted batchOf
+		want    []string
+		wantErr string
+	}{
+		{
+			"int32 to int64",
+			batchOf{narrowID, `[{"id": 2, "name": "b"}, {"id": 3, "name": null}]`},
+			[]string{"1|a", "2|b", "3|(null)"},
+			"",
+		},
+		{
+			"missing nullable column",
+			batchOf{idOnly, `[{"id": 2}]`},
+			[]string{"1|a", "2|(null)"},
+			"",
+		},
+		{
+			"reordered columns",
+			batchOf{arrow.NewSchema([]arrow.Field{pinned.Field(1), pinned.Field(0)}, nil), `[{"name": "b", "id": 2}]`},
+			[]string{"1|a", "2|b"},
+			"",
+		},
+		{
+			"extra column rejected",
+			batchOf{extra, `[{"id": 2, "name": "b", "age": 20}]`},
+			[]string{"1|a"},
+			"schema drift in batch 2 cannot be cast: unexpected column age (new column age int32)",
+		},
+		{
+			"missing non-nullable column rejected",
+			batchOf{nameOnly, `[{"name": "b"}]`},
+			[]string{"1|a"},
+			"schema drift in batch 2 cannot be cast: missing non-nullable column id (missing column id)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := iterator.NewSchemaGuard(iterator.DriftCast, nil)
+			rows, schemas, err := guardedRows(t, guard, batchOf{pinned, `[{"id": 1, "name": "a"}]`}, tt.drifted)
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("rows = %v, want %v", rows, tt.want)
+			}
+			for i, schema := range schemas {
+				if !schema.Equal(pinned) {
+					t.Errorf("batch %d schema = %v, want the pinned schema", i+1, schema)
+				}
+			}
+			if tt.wantErr != "" {
+				var driftErr *iterator.SchemaDriftError
+				if !errors.As(err, &driftErr) || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if events := guard.Events(); len(events) != 1 || !events[0].Cast || events[0].Batch != 2 {
+				t.Errorf("events = %v, want one cast of batch 2", events)
+			}
+		})
+	}
+}
+
+func TestSchemaGuardRecord(t *testing.T) {
+	guard := iterator.NewSchemaGuard(iterator.DriftRecord, nil)
+	var notified []int
+	guard.OnDrift = func(event iterator.DriftEvent) { notified = append(notified, event.Batch) }
+	rows, schemas, err := guardedRows(t, guard,
+		batchOf{pinned, `[{"id": 1, "name": "a"}]`},
+		batchOf{extra, `[{"id": 2, "name": "b", "age": 20}]`},
+		batchOf{pinned, `[{"id": 3, "name": "c"}]`},
+		batchOf{idOnly, `[{"id": 4}]`},
+	)
+	if err != nil {
+		t.Fatalf("err = %v", err)
+	}
+	if !reflect.DeepEqual(rows, []string{"1|a", "2|b|20", "3|c", "4"}) {
+		t.Errorf("rows = %v, want the batches unchanged", rows)
+	}
+	if !schemas[1].Equal(extra) || !schemas[3].Equal(idOnly) {
+		t.Errorf("drifted batches were modified: %v", schemas)
+	}
+	events := guard.Events()
+	if guard.Drifts() != 2 || len(events) != 2 || !reflect.DeepEqual(notified, []int{2, 4}) {
+		t.Fatalf("drifts = %d, events = %v, notified = %v", guard.Drifts(), events, notified)
+	}
+	if events[0].String() != "batch 2: new column age int32" || events[1].String() != "batch 4: missing column name" {
+		t.Errorf("events = %q, %q", events[0], events[1])
+	}
+}
+
+func TestSchemaGuardEventsCap(t *testing.T) {
+	guard := iterator.NewSchemaGuard(iterator.DriftRecord, nil)
+	batches := []batchOf{{pinned, `[{"id": 0, "name": "a"}]`}}
+	for i := 1; i <= 150; i++ {
+		batches = append(batches, batchOf{idOnly, fmt.Sprintf(`[{"id": %d}]`, i)})
+	}
+	rows, _, err := guardedRows(t, guard, batches...)
+	if err != nil || len(rows) != 151 {
+		t.Fatalf("read %d rows, err = %v", len(rows), err)
+	}
+	events := guard.Events()
+	if guard.Drifts() != 150 || len(events) != 100 {
+		t.Fatalf("drifts = %d, events = %d, want 150 and 100", guard.Drifts(), len(events))
+	}
+	if events[0].Batch != 2 || events[99].Batch != 101 {
+		t.Errorf("kept batches %d..%d, want the first 100 drifts", events[0].Batch, events[99].Batch)
+	}
+}
+
+func TestParseDriftPolicy(t *testing.T) {
+	for name, want := range map[string]iterator.DriftPolicy{"": iterator.DriftFail, "fail": iterator.DriftFail, "CAST": iterator.DriftCast, "record": iterator.DriftRecord} {
+		if got, err := iterator.ParseDriftPolicy(name); err != nil || got != want {
+			t.Errorf("ParseDriftPolicy(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := iterator.ParseDriftPolicy("ignore"); err == nil {
+		t.Error("unknown policy accepted")
+	}
+}
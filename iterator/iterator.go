@@ -0,0 +1,263 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/5
+	@note: Arrow 记录迭代器，屏蔽 "EOF" 批次、io.EOF 以及 IPC 解码细节
+
+*
+*/
+package iterator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+)
+
+// EOFMarker 服务端在 ReadStream/ReadInternalDBData 结束时发送的批次内容
+const EOFMarker = "EOF"
+
+// Source 返回下一段 Arrow IPC stream 数据，数据读完时返回 io.EOF
+type Source func() ([]byte, error)
+
+// Option 迭代器选项
+type Option func(*RecordIterator)
+
+// WithAllocator 指定解码使用的内存分配器
+func WithAllocator(allocator memory.Allocator) Option {
+	return func(it *RecordIterator) {
+		it.allocator = allocator
+	}
+}
+
+// WithCloser 注册迭代结束或 Release 时执行的清理函数，例如取消 gRPC 流的 context
+func WithCloser(fn func()) Option {
+	return func(it *RecordIterator) {
+		it.onClose(fn)
+	}
+}
+
+// WithSchemaGuard 以第一批的 schema 为准检查后续批次，按 guard 的策略报错、转换或记录变化
+func WithSchemaGuard(guard *SchemaGuard) Option {
+	return func(it *RecordIterator) {
+		it.guard = guard
+	}
+}
+
+// Records 逐批返回记录的读取器，RecordIterator 以及组合多个迭代器的读取器均满足该接口
+type Records interface {
+	Next() bool
+	// Record 返回当前记录，只在下一次调用 Next 之前有效
+	Record() arrow.Record
+	Err() error
+	// Release 释放资源，可以在读完之前调用以提前终止
+	Release()
+}
+
+// SchemaOf 返回读取器结果的 schema，结果为空时也可以据此输出表头等；
+// 读取器没有 Schema 方法或尚未收到 schema 时返回 nil
+func SchemaOf(r Records) *arrow.Schema {
+	if s, ok := r.(interface{ Schema() *arrow.Schema }); ok {
+		return s.Schema()
+	}
+	return nil
+}
+
+// RecordIterator 逐条返回数据流中的 arrow.Record
+//
+//	for it.Next() {
+//		record := it.Record()
+//		...
+//	}
+//	if err := it.Err(); err != nil { ... }
+//
+// Record 返回的记录只在下一次调用 Next 之前有效，需要保留时请调用 Retain。
+type RecordIterator struct {
+	src       Source
+	allocator memory.Allocator
+	reader    *ipc.Reader
+	schema    *arrow.Schema // 最近一段数据的 schema
+	guard     *SchemaGuard
+	win       *window       // WithLimit/WithOffset
+	stream    *sourceReader // WithByteStream
+	record    arrow.Record
+	err       error
+	done      bool
+	closers   []func()
+
+	chunks int
+	bytes  int64
+}
+
+// New 基于 Source 创建迭代器
+func New(src Source, opts ...Option) *RecordIterator {
+	it := &RecordIterator{
+		src:       src,
+		allocator: memory.NewGoAllocator(),
+	}
+	for _, opt := range opts {
+		opt(it)
+	}
+	return it
+}
+
+// Next 前进到下一条记录，没有更多记录或出错时返回 false
+func (it *RecordIterator) Next() bool {
+	if it.record != nil {
+		it.record.Release()
+		it.record = nil
+	}
+	if it.win != nil && it.win.full() {
+		it.finish()
+	}
+	for !it.done {
+		if it.reader == nil {
+			if !it.nextChunk() {
+				break
+			}
+		}
+		if it.reader.Next() {
+			record := it.reader.Record()
+			record.Retain()
+			if it.guard != nil {
+				checked, err := it.guard.Check(record)
+				record.Release()
+				if err != nil {
+					it.fail(err)
+					break
+				}
+				record = checked
+			}
+			if it.win != nil {
+				windowed := it.win.apply(record)
+				record.Release()
+				if it.win.full() {
+					// 已返回 limit 行，立即取消数据流
+					it.finish()
+				}
+				if windowed == nil {
+					continue
+				}
+				record = windowed
+			}
+			it.record = record
+			return true
+		}
+		if err := it.reader.Err(); err != nil {
+			if it.stream != nil && it.stream.err != nil {
+				// 字节流模式下 Source 的错误经由 IPC 读取器返回，保留原始错误
+				err = fmt.Errorf("error receiving data: %w", it.stream.err)
+			} else {
+				err = fmt.Errorf("failed to read record: %v", err)
+			}
+			it.fail(err)
+			break
+		}
+		it.reader.Release()
+		it.reader = nil
+	}
+	return false
+}
+
+// nextChunk 接收下一段数据并创建 IPC 读取器，数据结束或出错时返回 false
+func (it *RecordIterator) nextChunk() bool {
+	if it.stream != nil {
+		return it.nextStream()
+	}
+	for {
+		chunk, err := it.src()
+		if err == io.EOF {
+			it.finish()
+			return false
+		}
+		if err != nil {
+			it.fail(fmt.Errorf("error receiving data: %w", err))
+			return false
+		}
+		if string(chunk) == EOFMarker {
+			it.finish()
+			return false
+		}
+		if len(chunk) == 0 {
+			continue
+		}
+
+		reader, err := ipc.NewReader(bytes.NewReader(chunk), ipc.WithAllocator(it.allocator))
+		if err != nil {
+			it.fail(fmt.Errorf("failed to create Arrow reader: %v", err))
+			return false
+		}
+		it.reader = reader
+		it.schema = reader.Schema()
+		it.chunks++
+		it.bytes += int64(len(chunk))
+		return true
+	}
+}
+
+// Record 返回当前记录
+func (it *RecordIterator) Record() arrow.Record {
+	return it.record
+}
+
+// Schema 返回数据流的 schema，数据流中只有 schema 没有记录批次时同样有效；尚未收到数据时为 nil
+func (it *RecordIterator) Schema() *arrow.Schema {
+	if it.guard != nil && it.guard.Schema() != nil {
+		return it.guard.Schema()
+	}
+	return it.schema
+}
+
+// Err 返回迭代过程中遇到的错误，正常结束时为 nil
+func (it *RecordIterator) Err() error {
+	return it.err
+}
+
+// Chunks 返回已接收的非空数据段数量
+func (it *RecordIterator) Chunks() int {
+	return it.chunks
+}
+
+// Bytes 返回已接收的数据字节数
+func (it *RecordIterator) Bytes() int64 {
+	return it.bytes
+}
+
+// Release 释放迭代器持有的资源，可以在迭代结束前调用以提前终止
+func (it *RecordIterator) Release() {
+	if it.record != nil {
+		it.record.Release()
+		it.record = nil
+	}
+	it.finish()
+}
+
+// onClose 注册迭代结束时需要执行的清理函数
+func (it *RecordIterator) onClose(fn func()) {
+	it.closers = append(it.closers, fn)
+}
+
+func (it *RecordIterator) fail(err error) {
+	it.err = err
+	it.finish()
+}
+
+func (it *RecordIterator) finish() {
+	if it.reader != nil {
+		it.reader.Release()
+		it.reader = nil
+	}
+	if it.done {
+		return
+	}
+	it.done = true
+	for _, fn := range it.closers {
+		fn()
+	}
+	it.closers = nil
+}
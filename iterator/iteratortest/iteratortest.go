@@ -0,0 +1,72 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/25
+	@note: 测试用的内存数据源，将 JSON 描述的记录批次编码为 IPC stream，模拟 ReadStream 等函数的数据流
+
+*
+*/
+package iteratortest
+
+import (
+	"bytes"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"io"
+	"strings"
+	"test/iterator"
+	"testing"
+)
+
+// Source 按顺序返回预先编码的数据段，记录调用次数以及数据流是否已被取消
+type Source struct {
+	Chunks [][]byte
+	Err    error // 数据段返回完后的错误，为 nil 时返回 io.EOF
+	Calls  int   // Next 被调用的次数
+	Closed bool  // 迭代器的清理函数（即取消数据流）是否已执行
+}
+
+// NewSource 将每个 JSON 数组（格式同 array.RecordFromJSON）编码为一段独立的 IPC stream
+func NewSource(t testing.TB, schema *arrow.Schema, batches ...string) *Source {
+	t.Helper()
+	src := &Source{}
+	for _, batch := range batches {
+		record, _, err := array.RecordFromJSON(memory.DefaultAllocator, schema, strings.NewReader(batch))
+		if err != nil {
+			t.Fatalf("RecordFromJSON: %v", err)
+		}
+		var buf bytes.Buffer
+		writer := ipc.NewWriter(&buf, ipc.WithSchema(schema))
+		err = writer.Write(record)
+		record.Release()
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			t.Fatalf("failed to encode batch: %v", err)
+		}
+		src.Chunks = append(src.Chunks, buf.Bytes())
+	}
+	return src
+}
+
+// Next 满足 iterator.Source
+func (s *Source) Next() ([]byte, error) {
+	s.Calls++
+	if s.Calls <= len(s.Chunks) {
+		return s.Chunks[s.Calls-1], nil
+	}
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return nil, io.EOF
+}
+
+// Iterator 返回读取 s 的迭代器，mem 用于解码，opts 在 WithAllocator 和 WithCloser 之后应用
+func (s *Source) Iterator(mem memory.Allocator, opts ...iterator.Option) *iterator.RecordIterator {
+	opts = append([]iterator.Option{iterator.WithAllocator(mem), iterator.WithCloser(func() { s.Closed = true })}, opts...)
+	return iterator.New(s.Next, opts...)
+}
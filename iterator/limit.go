@@ -0,0 +1,138 @@
+package iterator
+
+import (
+	"github.com/apache/arrow/go/v15/arrow"
+)
+
+// window 跳过前 offset 行并最多保留 limit 行，limit 小于 0 表示不限制
+type window struct {
+	offset int64
+	limit  int64
+}
+
+// full 是否已返回 limit 行
+func (w *window) full() bool {
+	return w.limit == 0
+}
+
+// apply 返回记录中落在窗口内的部分（由调用方释放），整批被跳过时返回 nil，不改变 record 的引用计数
+func (w *window) apply(record arrow.Record) arrow.Record {
+	rows := record.NumRows()
+	if w.full() {
+		return nil
+	}
+	if w.offset >= rows {
+		w.offset -= rows
+		return nil
+	}
+	start, end := w.offset, rows
+	w.offset = 0
+	if w.limit >= 0 && end-start > w.limit {
+		end = start + w.limit
+	}
+	if w.limit > 0 {
+		w.limit -= end - start
+	}
+	if start == 0 && end == rows {
+		record.Retain()
+		return record
+	}
+	return record.NewSlice(start, end)
+}
+
+// WithLimit 最多返回 n 行，最后一批在边界处切分；返回第 n 行后立即结束迭代并执行清理函数，
+// ReadStream 等函数创建的迭代器会取消数据流的 context，服务端随即停止发送
+func WithLimit(n int64) Option {
+	return func(it *RecordIterator) {
+		it.window().limit = max(n, 0)
+	}
+}
+
+// WithOffset 跳过前 n 行，被跳过的数据仍需从服务端接收
+func WithOffset(n int64) Option {
+	return func(it *RecordIterator) {
+		it.window().offset = max(n, 0)
+	}
+}
+
+// window 返回迭代器的行窗口，首次调用时创建
+func (it *RecordIterator) window() *window {
+	if it.win == nil {
+		it.win = &window{limit: -1}
+	}
+	return it.win
+}
+
+// Limit 跳过 src 的前 offset 行并最多返回 limit 行（limit 小于 0 表示不限制），
+// 用于在客户端过滤之后截取结果；返回第 limit 行后立即释放 src，src 为 ReadStream 等函数创建的迭代器时会取消数据流
+func Limit(src Records, offset, limit int64) Records {
+	return &limitReader{src: src, window: window{offset: max(offset, 0), limit: limit}}
+}
+
+type limitReader struct {
+	src    Records
+	window window
+	schema *arrow.Schema
+	record arrow.Record
+	err    error
+}
+
+func (r *limitReader) Next() bool {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	for r.src != nil && !r.window.full() && r.src.Next() {
+		r.schema = r.src.Record().Schema()
+		record := r.window.apply(r.src.Record())
+		if record == nil {
+			continue
+		}
+		if r.window.full() {
+			r.close()
+		}
+		r.record = record
+		return true
+	}
+	r.close()
+	return false
+}
+
+// close 记录 src 的错误后释放 src
+func (r *limitReader) close() {
+	if r.src == nil {
+		return
+	}
+	if !r.window.full() {
+		r.err = r.src.Err()
+	}
+	if r.schema == nil {
+		r.schema = SchemaOf(r.src)
+	}
+	r.src.Release()
+	r.src = nil
+}
+
+func (r *limitReader) Record() arrow.Record {
+	return r.record
+}
+
+// Schema 返回 src 的 schema，所有行都被跳过时同样有效
+func (r *limitReader) Schema() *arrow.Schema {
+	if r.schema == nil && r.src != nil {
+		return SchemaOf(r.src)
+	}
+	return r.schema
+}
+
+func (r *limitReader) Err() error {
+	return r.err
+}
+
+func (r *limitReader) Release() {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	r.close()
+}
@@ -0,0 +1,166 @@
+package iterator_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"test/iterator"
+	"test/iterator/iteratortest"
+)
+
+var numbers = arrow.NewSchema([]arrow.Field{{Name: "n", Type: arrow.PrimitiveTypes.Int64}}, nil)
+
+// newNumbers 生成 len(sizes) 批数据，第 i 批有 sizes[i] 行，数值从 0 开始连续递增
+func newNumbers(t *testing.T, sizes ...int) *iteratortest.Source {
+	t.Helper()
+	var batches []string
+	n := 0
+	for _, size := range sizes {
+		values := make([]string, size)
+		for i := range values {
+			values[i] = fmt.Sprintf(`{"n": %d}`, n)
+			n++
+		}
+		batches = append(batches, "["+strings.Join(values, ",")+"]")
+	}
+	return iteratortest.NewSource(t, numbers, batches...)
+}
+
+// collect 读完 r，返回所有数值和每批的行数；最后一行返回后检查 closed 是否已为 true
+func collect(t *testing.T, r iterator.Records, closed *bool) ([]int64, []int64) {
+	t.Helper()
+	var values, batches []int64
+	for r.Next() {
+		record := r.Record()
+		column := record.Column(0).(*array.Int64)
+		values = append(values, column.Int64Values()...)
+		batches = append(batches, record.NumRows())
+	}
+	if !*closed {
+		t.Error("source not closed after the last row")
+	}
+	return values, batches
+}
+
+func TestWithLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		offset, limit int64 // limit 小于 0 表示不设置 WithLimit
+		values        []int64
+		batches       []int64
+		calls         int
+	}{
+		{"no window", 0, -1, []int64{0, 1, 2, 3, 4, 5, 6, 7}, []int64{3, 3, 2}, 4},
+		{"limit within a batch", 0, 2, []int64{0, 1}, []int64{2}, 1},
+		{"limit on a batch boundary", 0, 3, []int64{0, 1, 2}, []int64{3}, 1},
+		{"limit across batches", 0, 4, []int64{0, 1, 2, 3}, []int64{3, 1}, 2},
+		{"limit past the end", 0, 20, []int64{0, 1, 2, 3, 4, 5, 6, 7}, []int64{3, 3, 2}, 4},
+		{"limit 0", 0, 0, nil, nil, 0},
+		{"offset skips whole batches", 4, -1, []int64{4, 5, 6, 7}, []int64{2, 2}, 4},
+		{"offset on a batch boundary", 3, 2, []int64{3, 4}, []int64{2}, 2},
+		{"offset and limit across batches", 2, 4, []int64{2, 3, 4, 5}, []int64{1, 3}, 2},
+		{"offset past the end", 10, 5, nil, nil, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+			defer mem.AssertSize(t, 0)
+			src := newNumbers(t, 3, 3, 2)
+			opts := []iterator.Option{iterator.WithOffset(tt.offset)}
+			if tt.limit >= 0 {
+				opts = append(opts, iterator.WithLimit(tt.limit))
+			}
+			it := src.Iterator(mem, opts...)
+			defer it.Release()
+			values, batches := collect(t, it, &src.Closed)
+			if err := it.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(values, tt.values) || !reflect.DeepEqual(batches, tt.batches) {
+				t.Errorf("values = %v in batches %v, want %v in %v", values, batches, tt.values, tt.batches)
+			}
+			if src.Calls != tt.calls {
+				t.Errorf("Source called %d times, want %d", src.Calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		offset, limit int64
+		values        []int64
+		calls         int
+	}{
+		{"unlimited", 0, -1, []int64{0, 1, 2, 3, 4, 5, 6, 7}, 4},
+		{"limit", 0, 4, []int64{0, 1, 2, 3}, 2},
+		{"offset and limit", 5, 2, []int64{5, 6}, 3},
+		{"limit 0", 0, 0, nil, 0},
+		{"offset past the end", 8, 1, nil, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+			defer mem.AssertSize(t, 0)
+			src := newNumbers(t, 3, 3, 2)
+			r := iterator.Limit(src.Iterator(mem), tt.offset, tt.limit)
+			values, _ := collect(t, r, &src.Closed)
+			if tt.limit != 0 {
+				// 所有行都被跳过时 schema 仍然可用
+				if schema := iterator.SchemaOf(r); schema == nil || !schema.Equal(numbers) {
+					t.Errorf("Schema = %v, want %v", schema, numbers)
+				}
+			}
+			r.Release()
+			if err := r.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(values, tt.values) {
+				t.Errorf("values = %v, want %v", values, tt.values)
+			}
+			if src.Calls != tt.calls {
+				t.Errorf("Source called %d times, want %d", src.Calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestLimitError(t *testing.T) {
+	failure := errors.New("stream reset")
+	tests := []struct {
+		name  string
+		limit int64
+		err   bool
+	}{
+		{"error before the limit is reported", 5, true},
+		{"error after the limit is never received", 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, wrap := range []string{"WithLimit", "Limit"} {
+				src := newNumbers(t, 3)
+				src.Err = failure
+				var r iterator.Records
+				if wrap == "WithLimit" {
+					r = src.Iterator(memory.DefaultAllocator, iterator.WithLimit(tt.limit))
+				} else {
+					r = iterator.Limit(src.Iterator(memory.DefaultAllocator), 0, tt.limit)
+				}
+				for r.Next() {
+				}
+				err := r.Err()
+				r.Release()
+				if (err != nil) != tt.err {
+					t.Errorf("%s: Err = %v, want error %v", wrap, err, tt.err)
+				}
+			}
+		})
+	}
+}
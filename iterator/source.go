@@ -0,0 +1,75 @@
+package iterator
+
+import (
+	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"context"
+	"fmt"
+)
+
+// StreamSource 调用 ReadStream 并返回未解码的数据段，取消 ctx 即可结束数据流
+func StreamSource(ctx context.Context, dataServiceClient *client.DataServiceClient, request *pb.StreamReadRequest) (Source, error) {
+	stream, err := dataServiceClient.ReadStream(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stream: %v", err)
+	}
+	return func() ([]byte, error) {
+		response, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return response.GetArrowBatch(), nil
+	}, nil
+}
+
+// ReadStream 调用 ReadStream 并返回外部数据源记录的迭代器
+func ReadStream(ctx context.Context, dataServiceClient *client.DataServiceClient, request *pb.StreamReadRequest, opts ...Option) (*RecordIterator, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	src, err := StreamSource(ctx, dataServiceClient, request)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	it := New(src, opts...)
+	it.onClose(cancel)
+	return it, nil
+}
+
+// ReadInternalDBData 调用 ReadInternalDBData 并返回内部表记录的迭代器
+func ReadInternalDBData(ctx context.Context, dataServiceClient *client.DataServiceClient, request *pb.InternalReadRequest, opts ...Option) (*RecordIterator, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := dataServiceClient.ReadInternalDBData(ctx, request)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to read internal stream: %v", err)
+	}
+	it := New(func() ([]byte, error) {
+		response, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return response.GetArrowBatch(), nil
+	}, opts...)
+	it.onClose(cancel)
+	return it, nil
+}
+
+// ReadOSSData 调用 ReadOSSData 并返回 OSS 对象中记录的迭代器。
+// 只能读取单个对象，oss.Upload 指定 PartSize 分段上传的对象请使用 oss.ReadRecords
+func ReadOSSData(ctx context.Context, dataServiceClient *client.DataServiceClient, request *pb.OSSReadRequest, opts ...Option) (*RecordIterator, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := dataServiceClient.ReadOSSData(ctx, request)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to read OSS stream: %v", err)
+	}
+	it := New(func() ([]byte, error) {
+		response, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return response.Chunk, nil
+	}, opts...)
+	it.onClose(cancel)
+	return it, nil
+}
@@ -0,0 +1,155 @@
+package job
+
+import (
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Guard 在作业提交后捕获 SIGINT/SIGTERM。客户端没有取消作业的接口，中断只会停止等待、与作业脱离，
+// 作业仍在集群上运行；JobId 和提交请求会记录到本地状态文件，之后可以通过 job resume 继续等待
+//
+//	guard := job.NewGuard(ctx, store)
+//	defer guard.Stop()
+//	resp, err := dataServiceClient.SubmitBatchJob(ctx, request) // 提交本身不会被信号打断
+//	guard.Track(resp.JobId, request)
+//	status, err := job.WaitForJob(guard.Context(), dataServiceClient, resp.JobId, opts)
+//	guard.Done(status, err)
+type Guard struct {
+	store   *Store
+	ctx     context.Context
+	stopCtx context.CancelFunc
+	sigCh   chan os.Signal
+
+	mu          sync.Mutex
+	state       *State
+	interrupted bool
+}
+
+// NewGuard 开始捕获信号
+func NewGuard(ctx context.Context, store *Store) *Guard {
+	g := &Guard{
+		store: store,
+		sigCh: make(chan os.Signal, 1),
+	}
+	g.ctx, g.stopCtx = context.WithCancel(ctx)
+	signal.Notify(g.sigCh, os.Interrupt, syscall.SIGTERM)
+	go g.watch()
+	return g
+}
+
+func (g *Guard) watch() {
+	select {
+	case sig, ok := <-g.sigCh:
+		if !ok {
+			return
+		}
+		log.Printf("Received %v, stopping; press Ctrl-C again to exit immediately", sig)
+		g.mu.Lock()
+		g.interrupted = true
+		g.mu.Unlock()
+		// 恢复默认处理，再次中断直接退出
+		signal.Stop(g.sigCh)
+		g.stopCtx()
+	case <-g.ctx.Done():
+	}
+}
+
+// Context 返回收到信号时会被取消的 context，用于等待作业
+func (g *Guard) Context() context.Context {
+	return g.ctx
+}
+
+// Interrupted 是否收到了中断信号
+func (g *Guard) Interrupted() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.interrupted
+}
+
+// Track 记录已提交的作业，request 会以 JSON 形式保存
+func (g *Guard) Track(jobID string, request interface{}) error {
+	raw, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %v", err)
+	}
+	state := &State{
+		JobID:       jobID,
+		State:       StateRunning,
+		SubmittedAt: time.Now(),
+		Request:     raw,
+	}
+	g.mu.Lock()
+	g.state = state
+	g.mu.Unlock()
+	return g.store.Save(state)
+}
+
+// Resume 继续管理一个之前记录的作业
+func (g *Guard) Resume(state *State) error {
+	state.State = StateRunning
+	state.Note = ""
+	g.mu.Lock()
+	g.state = state
+	g.mu.Unlock()
+	return g.store.Save(state)
+}
+
+// Done 根据等待结果处理本地状态：作业已结束（包括失败）时删除记录；
+// 被信号中断或其他错误（如等待超时）时保留记录
+func (g *Guard) Done(status pb.JobStatus, waitErr error) error {
+	if _, terminal := waitErr.(*TerminalError); waitErr == nil || terminal {
+		return g.Finish()
+	}
+	if g.Interrupted() {
+		return g.Detach(status)
+	}
+	return g.save(status, StateInterrupted, fmt.Sprintf("wait failed: %v", waitErr))
+}
+
+// Finish 作业已结束，删除本地状态
+func (g *Guard) Finish() error {
+	g.mu.Lock()
+	state := g.state
+	g.mu.Unlock()
+	if state == nil {
+		return nil
+	}
+	return g.store.Remove(state.JobID)
+}
+
+// Detach 等待被中断时调用，作业仍在运行，保留本地状态以便 job resume
+func (g *Guard) Detach(lastStatus pb.JobStatus) error {
+	return g.save(lastStatus, StateInterrupted, "interrupted, job detached and may still be running")
+}
+
+// save 更新并保存本地状态
+func (g *Guard) save(lastStatus pb.JobStatus, stateName, note string) error {
+	g.mu.Lock()
+	state := g.state
+	g.mu.Unlock()
+	if state == nil {
+		return nil
+	}
+	state.State = stateName
+	state.Status = lastStatus.String()
+	state.Note = note
+	if err := g.store.Save(state); err != nil {
+		return err
+	}
+	log.Printf("Job %s state saved to %s", state.JobID, g.store.path(state.JobID))
+	return nil
+}
+
+// Stop 停止捕获信号
+func (g *Guard) Stop() {
+	signal.Stop(g.sigCh)
+	g.stopCtx()
+}
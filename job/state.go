@@ -0,0 +1,75 @@
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"test/statedir"
+	"time"
+)
+
+// 本地状态文件中记录的作业状态
+const (
+	StateRunning     = "RUNNING"     // 已提交，正在等待
+	StateInterrupted = "INTERRUPTED" // 等待过程被中断，作业可能仍在运行
+)
+
+// State 本地记录的已提交作业，用于中断后恢复等待
+type State struct {
+	JobID       string          `json:"jobId"`
+	State       string          `json:"state"`
+	Status      string          `json:"status,omitempty"` // 最后一次查询到的 JobStatus
+	SubmittedAt time.Time       `json:"submittedAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+	Request     json.RawMessage `json:"request,omitempty"` // 提交时的 BatchReadRequest
+	Note        string          `json:"note,omitempty"`
+}
+
+// Store 以目录形式保存作业状态，每个作业一个 JSON 文件
+type Store struct {
+	files *statedir.Dir[State]
+}
+
+// DefaultStateDir 默认的状态目录 ~/.mira/jobs，无法获取用户目录时使用当前目录下的 .mira/jobs
+func DefaultStateDir() string {
+	return statedir.Default("jobs")
+}
+
+// NewStore 创建状态存储，dir 为空时使用默认目录
+func NewStore(dir string) *Store {
+	return &Store{files: statedir.New[State](dir, "jobs", "job state")}
+}
+
+// path 返回作业状态文件的路径，JobId 中的路径分隔符会被替换
+func (s *Store) path(jobID string) string {
+	return s.files.Path(jobID)
+}
+
+// Save 写入作业状态
+func (s *Store) Save(state *State) error {
+	if state.JobID == "" {
+		return errors.New("job id is empty")
+	}
+	state.UpdatedAt = time.Now()
+	return s.files.Save(state.JobID, state)
+}
+
+// Load 读取作业状态
+func (s *Store) Load(jobID string) (*State, error) {
+	return s.files.Load(jobID)
+}
+
+// List 按提交时间列出所有记录的作业
+func (s *Store) List() ([]*State, error) {
+	states, err := s.files.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].SubmittedAt.Before(states[j].SubmittedAt) })
+	return states, nil
+}
+
+// Remove 删除作业状态，文件不存在时不报错
+func (s *Store) Remove(jobID string) error {
+	return s.files.Remove(jobID)
+}
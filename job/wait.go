@@ -0,0 +1,208 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/9
+	@note: 批处理作业状态轮询，支持指数退避、总超时和状态变化回调
+
+*
+*/
+package job
+
+import (
+	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 轮询的默认参数
+const (
+	DefaultInitialInterval      = time.Second
+	DefaultMaxInterval          = 30 * time.Second
+	DefaultMultiplier           = 2.0
+	DefaultMaxConsecutiveErrors = 5
+)
+
+// ErrTooManyErrors 连续查询失败次数超过上限
+var ErrTooManyErrors = errors.New("too many consecutive job status errors")
+
+// Outcome 作业的结束类型
+type Outcome int
+
+const (
+	Running Outcome = iota
+	Succeeded
+	Failed
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Succeeded:
+		return "succeeded"
+	case Failed:
+		return "failed"
+	default:
+		return "running"
+	}
+}
+
+// Classify 判断作业是否已结束，其余状态视为仍在运行；客户端新增终止状态时需要在这里补充
+func Classify(status pb.JobStatus) Outcome {
+	switch status {
+	case pb.JobStatus_JOB_STATUS_SUCCEEDED:
+		return Succeeded
+	case pb.JobStatus_JOB_STATUS_FAILED:
+		return Failed
+	}
+	return Running
+}
+
+// TerminalError 作业以非成功状态结束
+type TerminalError struct {
+	JobID   string
+	Status  pb.JobStatus
+	Outcome Outcome
+}
+
+func (e *TerminalError) Error() string {
+	return fmt.Sprintf("job %s %s with status %v", e.JobID, e.Outcome, e.Status)
+}
+
+// Transition 一次状态变化
+type Transition struct {
+	JobID   string
+	From    pb.JobStatus
+	To      pb.JobStatus
+	First   bool          // 第一次查询到状态时为 true，此时 From 无意义
+	Elapsed time.Duration // 从开始等待到本次变化的时长
+}
+
+// WaitOptions 等待作业的参数，零值字段使用默认值
+type WaitOptions struct {
+	InitialInterval      time.Duration // 第一次查询前以及状态变化后的等待间隔
+	MaxInterval          time.Duration // 退避的最大间隔
+	Multiplier           float64       // 每次状态未变化或查询失败后间隔的倍数
+	Timeout              time.Duration // 总等待时长，0 表示不限制
+	MaxConsecutiveErrors int           // 连续查询失败的上限，负数表示不限制
+	OnTransition         func(Transition)
+}
+
+func (o *WaitOptions) withDefaults() WaitOptions {
+	opts := WaitOptions{}
+	if o != nil {
+		opts = *o
+	}
+	if opts.InitialInterval <= 0 {
+		opts.InitialInterval = DefaultInitialInterval
+	}
+	if opts.MaxInterval <= 0 {
+		opts.MaxInterval = DefaultMaxInterval
+	}
+	if opts.MaxInterval < opts.InitialInterval {
+		opts.MaxInterval = opts.InitialInterval
+	}
+	if opts.Multiplier < 1 {
+		opts.Multiplier = DefaultMultiplier
+	}
+	if opts.MaxConsecutiveErrors == 0 {
+		opts.MaxConsecutiveErrors = DefaultMaxConsecutiveErrors
+	}
+	return opts
+}
+
+// StatusFunc 查询作业状态
+type StatusFunc func(ctx context.Context, jobID string) (pb.JobStatus, error)
+
+// ClientStatus 基于数据服务客户端的 GetJobStatus 返回 StatusFunc
+func ClientStatus(dataServiceClient *client.DataServiceClient) StatusFunc {
+	return func(ctx context.Context, jobID string) (pb.JobStatus, error) {
+		statusResp, err := dataServiceClient.GetJobStatus(ctx, jobID)
+		if err != nil {
+			return 0, err
+		}
+		return statusResp.Status, nil
+	}
+}
+
+// WaitForJob 轮询作业状态直到作业结束
+//
+// 作业成功时返回最终状态和 nil；作业失败时返回 *TerminalError。终止状态由 Classify 判断，
+// 目前只有 SUCCEEDED 和 FAILED，客户端没有取消或超时状态，其余状态都视为仍在运行。
+// 超过总等待时长、连续失败次数超限或 ctx 被取消时返回对应的错误，此时状态为最后一次查询到的值。
+func WaitForJob(ctx context.Context, dataServiceClient *client.DataServiceClient, jobID string, opts *WaitOptions) (pb.JobStatus, error) {
+	return Wait(ctx, ClientStatus(dataServiceClient), jobID, opts)
+}
+
+// Wait 使用指定的 StatusFunc 等待作业结束，语义同 WaitForJob
+func Wait(ctx context.Context, getStatus StatusFunc, jobID string, opts *WaitOptions) (pb.JobStatus, error) {
+	o := opts.withDefaults()
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+
+	startTime := time.Now()
+	interval := o.InitialInterval
+	var (
+		last    pb.JobStatus
+		seen    bool
+		errs    int
+		lastErr error
+	)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return last, fmt.Errorf("waiting for job %s: %v (last error: %v)", jobID, ctx.Err(), lastErr)
+			}
+			return last, fmt.Errorf("waiting for job %s: %v", jobID, ctx.Err())
+		case <-timer.C:
+		}
+
+		status, err := getStatus(ctx, jobID)
+		if err != nil {
+			errs++
+			lastErr = err
+			if o.MaxConsecutiveErrors > 0 && errs >= o.MaxConsecutiveErrors {
+				return last, fmt.Errorf("%w: job %s, last error: %v", ErrTooManyErrors, jobID, err)
+			}
+			interval = nextInterval(interval, o)
+			timer.Reset(interval)
+			continue
+		}
+		errs, lastErr = 0, nil
+
+		if !seen || status != last {
+			if o.OnTransition != nil {
+				o.OnTransition(Transition{JobID: jobID, From: last, To: status, First: !seen, Elapsed: time.Since(startTime)})
+			}
+			seen, last = true, status
+			interval = o.InitialInterval
+		} else {
+			interval = nextInterval(interval, o)
+		}
+
+		switch outcome := Classify(status); outcome {
+		case Succeeded:
+			return status, nil
+		case Failed:
+			return status, &TerminalError{JobID: jobID, Status: status, Outcome: outcome}
+		}
+		timer.Reset(interval)
+	}
+}
+
+func nextInterval(interval time.Duration, o WaitOptions) time.Duration {
+	next := time.Duration(float64(interval) * o.Multiplier)
+	if next > o.MaxInterval {
+		next = o.MaxInterval
+	}
+	return next
+}
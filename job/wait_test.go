@@ -0,0 +1,25 @@
+package job
+
+import (
+	"testing"
+
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		status pb.JobStatus
+		want   Outcome
+	}{
+		{pb.JobStatus_JOB_STATUS_SUCCEEDED, Succeeded},
+		{pb.JobStatus_JOB_STATUS_FAILED, Failed},
+		{pb.JobStatus_JOB_STATUS_RUNNING, Running},
+		{pb.JobStatus_JOB_STATUS_UNSPECIFIED, Running},
+		{pb.JobStatus(99), Running}, // 未知状态不视为结束
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.status); got != tt.want {
+			t.Errorf("Classify(%v) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
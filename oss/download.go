@@ -0,0 +1,261 @@
+package oss
+
+import (
+	"bytes"
+	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"io"
+	"log"
+	"os"
+	"test/iterator"
+)
+
+// Format 对象内容的格式
+type Format int
+
+const (
+	FormatOpaque      Format = iota // 非 Arrow 数据
+	FormatArrowFile                 // Arrow IPC file 格式，以 ARROW1 开头
+	FormatArrowStream               // Arrow IPC stream 格式
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatArrowFile:
+		return "arrow-file"
+	case FormatArrowStream:
+		return "arrow-stream"
+	}
+	return "opaque"
+}
+
+// arrowMagic Arrow IPC file 格式的文件头
+var arrowMagic = []byte("ARROW1")
+
+// continuationMarker Arrow IPC stream 中每条消息前的标记
+const continuationMarker = 0xFFFFFFFF
+
+// DetectFormat 根据对象开头的字节判断格式，至少需要 8 个字节才能识别 stream 格式
+func DetectFormat(header []byte) Format {
+	if bytes.HasPrefix(header, arrowMagic) {
+		return FormatArrowFile
+	}
+	if len(header) >= 8 && binary.LittleEndian.Uint32(header) == continuationMarker &&
+		int32(binary.LittleEndian.Uint32(header[4:])) > 0 {
+		return FormatArrowStream
+	}
+	return FormatOpaque
+}
+
+// SourceFunc 打开读取指定对象的数据流，返回的 Source 在读完时返回 io.EOF
+type SourceFunc func(ctx context.Context, bucket, object string) (iterator.Source, error)
+
+// ClientSource 基于 ReadOSSData 的 SourceFunc
+func ClientSource(dataServiceClient *client.DataServiceClient) SourceFunc {
+	return func(ctx context.Context, bucket, object string) (iterator.Source, error) {
+		stream, err := dataServiceClient.ReadOSSData(ctx, &pb.OSSReadRequest{BucketName: bucket, ObjectName: object})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OSS data: %v", err)
+		}
+		return func() ([]byte, error) {
+			response, err := stream.Recv()
+			if err != nil {
+				return nil, err
+			}
+			return response.GetChunk(), nil
+		}, nil
+	}
+}
+
+// DownloadOptions 下载参数
+type DownloadOptions struct {
+	Validate        bool // 下载完成后解析 Arrow 数据，确认文件完整
+	SkipVerify      bool // 不读取校验清单
+	RequireManifest bool // 没有校验清单时报错，默认只打印警告
+	Allocator       memory.Allocator
+}
+
+// DownloadResult 下载结果
+type DownloadResult struct {
+	Size     int64
+	Chunks   int
+	Parts    int // 分段上传的对象包含的 part 数，普通对象为 1
+	Format   Format
+	Batches  int   // 仅在 Validate 时统计
+	Rows     int64 // 仅在 Validate 时统计
+	SHA256   string
+	Verified bool // 已按校验清单校验
+}
+
+// Download 将对象写入本地文件 path，先写入 path.download 再重命名，失败时不会留下不完整的文件
+//
+// 对象通过 OpenObject 打开，Upload 分段写入的对象会按 object+PartsSuffix 索引拼接各 part。
+// 数据流以 io.EOF（或 iterator.EOFMarker）正常结束。
+// 默认读取 object+ManifestSuffix 校验清单，边下载边校验，不一致时返回 *ChecksumError 且不会生成文件。
+func Download(ctx context.Context, open SourceFunc, bucket, object, path string, opts DownloadOptions) (*DownloadResult, error) {
+	var verifier *Verifier
+	if !opts.SkipVerify {
+		manifest, err := ReadManifest(ctx, open, bucket, object)
+		switch {
+		case err == nil:
+			verifier = NewVerifier(manifest)
+		case opts.RequireManifest:
+			return nil, err
+		default:
+			log.Printf("Warning: %v, skipping checksum verification", err)
+		}
+	}
+
+	tmp := path + ".download"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %v", err)
+	}
+	defer os.Remove(tmp)
+
+	result := &DownloadResult{Parts: 1}
+	header := make([]byte, 0, 8)
+	digest := sha256.New()
+	write := func(chunk []byte) error {
+		if verifier != nil {
+			if _, err := verifier.Write(chunk); err != nil {
+				return err
+			}
+		}
+		digest.Write(chunk)
+		if len(header) < cap(header) {
+			header = append(header, chunk[:min(len(chunk), cap(header)-len(header))]...)
+		}
+		if _, err := file.Write(chunk); err != nil {
+			return fmt.Errorf("failed to write file: %v", err)
+		}
+		result.Size += int64(len(chunk))
+		result.Chunks++
+		return nil
+	}
+
+	// 普通对象和分段上传的对象都通过 OpenObject 读取
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	src, index, err := OpenObject(readCtx, open, bucket, object)
+	if err == nil {
+		if index != nil {
+			result.Parts = len(index.Parts)
+		}
+		err = drain(src, write)
+	}
+	if cerr := file.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("failed to close file: %v", cerr)
+	}
+	if err == nil && verifier != nil {
+		err = verifier.Finish()
+	}
+	if err != nil {
+		return result, err
+	}
+	result.SHA256 = hex.EncodeToString(digest.Sum(nil))
+	result.Verified = verifier != nil
+
+	result.Format = DetectFormat(header)
+	if opts.Validate && result.Format != FormatOpaque {
+		if result.Batches, result.Rows, err = ValidateFile(tmp, result.Format, opts.Allocator); err != nil {
+			return result, fmt.Errorf("downloaded %s object is invalid: %v", result.Format, err)
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return result, fmt.Errorf("failed to rename file: %v", err)
+	}
+	return result, nil
+}
+
+// copyObject 读取一个对象的全部数据块
+func copyObject(ctx context.Context, open SourceFunc, bucket, object string, write func([]byte) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	src, err := open(ctx, bucket, object)
+	if err != nil {
+		return err
+	}
+	return drain(src, write)
+}
+
+// drain 读取 src 直到 io.EOF 或 iterator.EOFMarker
+func drain(src iterator.Source, write func([]byte) error) error {
+	for {
+		chunk, err := src()
+		if err == io.EOF || (err == nil && string(chunk) == iterator.EOFMarker) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error receiving data: %w", err)
+		}
+		if len(chunk) == 0 {
+			continue
+		}
+		if err := write(chunk); err != nil {
+			return err
+		}
+	}
+}
+
+// ValidateFile 读取本地 Arrow 文件中的所有记录批次，返回批次数和行数
+func ValidateFile(path string, format Format, allocator memory.Allocator) (int, int64, error) {
+	if allocator == nil {
+		allocator = memory.NewGoAllocator()
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	var batches int
+	var rows int64
+	switch format {
+	case FormatArrowFile:
+		reader, err := ipc.NewFileReader(file, ipc.WithAllocator(allocator))
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to create Arrow IPC file reader: %v", err)
+		}
+		defer reader.Close()
+		for i := 0; i < reader.NumRecords(); i++ {
+			record, err := reader.Record(i)
+			if err != nil {
+				return batches, rows, fmt.Errorf("failed to read record batch %d: %v", i, err)
+			}
+			batches++
+			rows += record.NumRows()
+		}
+	case FormatArrowStream:
+		// 逐批写入的对象由多个首尾相接的 stream 组成
+		for streams := 0; ; streams++ {
+			reader, err := ipc.NewReader(file, ipc.WithAllocator(allocator))
+			if streams > 0 && errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return batches, rows, fmt.Errorf("failed to create Arrow IPC reader: %v", err)
+			}
+			for reader.Next() {
+				batches++
+				rows += reader.Record().NumRows()
+			}
+			err = reader.Err()
+			reader.Release()
+			if err != nil {
+				return batches, rows, fmt.Errorf("failed to read record batch %d: %v", batches, err)
+			}
+		}
+	default:
+		return 0, 0, fmt.Errorf("cannot validate %s data", format)
+	}
+	return batches, rows, nil
+}
@@ -0,0 +1,222 @@
+package oss
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"test/iterator"
+)
+
+// LocalStore 以本地目录模拟对象存储，对象保存在 <root>/<bucket>/<object>，对象名中的 / 对应子目录。
+// 用于开发和离线测试，读写行为与数据服务一致：写入在 Close 成功后才可见，读取按 DefaultChunkSize 分块。
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore 创建以 root 为根目录的 LocalStore，目录在首次写入时创建
+func NewLocalStore(root string) *LocalStore {
+	return &LocalStore{root: root}
+}
+
+// Root 返回根目录
+func (s *LocalStore) Root() string {
+	return s.root
+}
+
+// path 返回对象的本地路径，拒绝指向 bucket 目录之外的 bucket 和对象名
+func (s *LocalStore) path(bucket, object string) (string, error) {
+	if bucket == "" || object == "" {
+		return "", errors.New("bucket and object name are required")
+	}
+	dir := filepath.Join(s.root, bucket)
+	path := filepath.Join(dir, filepath.FromSlash(object))
+	if !within(s.root, dir) || !within(dir, path) {
+		return "", fmt.Errorf("invalid object %s/%s", bucket, object)
+	}
+	return path, nil
+}
+
+// within 判断 path 是否位于 base 之下（不含 base 本身）
+func within(base, path string) bool {
+	rel, err := filepath.Rel(base, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Put 写入同目录下的临时文件，Close 时重命名为对象文件；ctx 在 Close 之前被取消时放弃写入
+func (s *LocalStore) Put(ctx context.Context, bucket, object string) (Writer, error) {
+	path, err := s.path(bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create object file: %v", err)
+	}
+	return &localWriter{ctx: ctx, file: file, path: path}, nil
+}
+
+// Get 按写入时的分块读取对象文件：存在校验清单时按清单中记录的块边界返回，
+// 与数据服务一样逐批写入的每段 IPC stream 仍是单独的一块；没有清单时按 DefaultChunkSize 分块
+func (s *LocalStore) Get(ctx context.Context, bucket, object string) (iterator.Source, error) {
+	path, err := s.path(bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s/%s: %w", bucket, object, ErrNotExist)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open object: %v", err)
+	}
+	sizes := chunkSizes(path)
+	// 提前结束读取时由 ctx 关闭文件
+	stop := context.AfterFunc(ctx, func() { file.Close() })
+	done := false
+	return func() ([]byte, error) {
+		if done {
+			return nil, io.EOF
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		size := DefaultChunkSize
+		if len(sizes) > 0 {
+			size, sizes = int(sizes[0]), sizes[1:]
+		}
+		// 每个块使用新的切片，调用方可以保留返回的数据
+		buf := make([]byte, size)
+		n, err := io.ReadFull(file, buf)
+		if err == io.ErrUnexpectedEOF || (err == io.EOF && n == 0) {
+			done = true
+			stop()
+			file.Close()
+			if n == 0 {
+				return nil, io.EOF
+			}
+			err = nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("failed to read object: %v", err)
+		}
+		return buf[:n], nil
+	}, nil
+}
+
+// chunkSizes 返回校验清单记录的各块大小，没有清单或清单与文件大小不一致时返回 nil
+func chunkSizes(path string) []int64 {
+	data, err := os.ReadFile(path + ManifestSuffix)
+	if err != nil {
+		return nil
+	}
+	manifest := &Manifest{}
+	if json.Unmarshal(data, manifest) != nil {
+		return nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil || fi.Size() != manifest.Size {
+		return nil
+	}
+	sizes := make([]int64, len(manifest.Chunks))
+	for i, chunk := range manifest.Chunks {
+		sizes[i] = chunk.Size
+	}
+	return sizes
+}
+
+// Stat 返回对象文件的大小和修改时间，存在校验清单时附带 SHA-256；
+// 分段上传的对象返回 object+PartsSuffix 索引中记录的总大小
+func (s *LocalStore) Stat(ctx context.Context, bucket, object string) (*ObjectInfo, error) {
+	path, err := s.path(bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	info := &ObjectInfo{Bucket: bucket, Object: object}
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if fi, err = os.Stat(path + PartsSuffix); err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", bucket, object, ErrNotExist)
+		}
+		index, err := readLocalPartIndex(path + PartsSuffix)
+		if err != nil {
+			return nil, err
+		}
+		info.Size = index.Size
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to stat object: %v", err)
+	} else {
+		info.Size = fi.Size()
+	}
+	info.ModTime = fi.ModTime()
+	if data, err := os.ReadFile(path + ManifestSuffix); err == nil {
+		manifest := &Manifest{}
+		if json.Unmarshal(data, manifest) == nil {
+			info.SHA256 = manifest.SHA256
+		}
+	}
+	return info, nil
+}
+
+func readLocalPartIndex(path string) (*PartIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read part index: %v", err)
+	}
+	index := &PartIndex{}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("failed to parse part index: %v", err)
+	}
+	return index, nil
+}
+
+// localWriter 写入临时文件，Close 成功时原子地替换对象文件
+type localWriter struct {
+	ctx  context.Context
+	file *os.File
+	path string
+	err  error // 写入失败后 Close 放弃对象
+}
+
+func (w *localWriter) Write(chunk []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := w.file.Write(chunk); err != nil {
+		w.err = fmt.Errorf("failed to write object file: %v", err)
+		return w.err
+	}
+	return nil
+}
+
+func (w *localWriter) Close() error {
+	tmp := w.file.Name()
+	err := w.file.Close()
+	if err == nil {
+		err = w.err
+	}
+	if err == nil {
+		err = w.ctx.Err()
+	}
+	if err == nil {
+		err = os.Rename(tmp, w.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"test/iterator"
+	"time"
+)
+
+// ManifestSuffix 校验清单对象名的后缀，清单与数据对象放在同一个 bucket 中
+const ManifestSuffix = ".manifest.json"
+
+// ChunkHash 一个数据块的校验值
+type ChunkHash struct {
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest 对象的校验清单，分段上传的对象描述的是拼接后的完整数据
+type Manifest struct {
+	Object    string      `json:"object"`
+	Size      int64       `json:"size"`
+	SHA256    string      `json:"sha256"`
+	Chunks    []ChunkHash `json:"chunks"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// ChecksumError 校验失败，Chunk 为 -1 时表示整体大小或 SHA-256 不一致
+type ChecksumError struct {
+	Object   string
+	Chunk    int
+	Offset   int64
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	if e.Chunk < 0 {
+		return fmt.Sprintf("checksum mismatch for %s: expected %s, got %s", e.Object, e.Expected, e.Actual)
+	}
+	return fmt.Sprintf("checksum mismatch for %s at chunk %d (offset %d): expected sha256 %s, got %s",
+		e.Object, e.Chunk, e.Offset, e.Expected, e.Actual)
+}
+
+// Hasher 按发送的数据块计算校验值
+type Hasher struct {
+	total  hash.Hash
+	size   int64
+	chunks []ChunkHash
+}
+
+// NewHasher 创建 Hasher
+func NewHasher() *Hasher {
+	return &Hasher{total: sha256.New()}
+}
+
+// Add 记录一个数据块
+func (h *Hasher) Add(chunk []byte) {
+	sum := sha256.Sum256(chunk)
+	h.chunks = append(h.chunks, ChunkHash{Offset: h.size, Size: int64(len(chunk)), SHA256: hex.EncodeToString(sum[:])})
+	h.total.Write(chunk)
+	h.size += int64(len(chunk))
+}
+
+// Manifest 返回已记录数据的校验清单
+func (h *Hasher) Manifest(object string) *Manifest {
+	return &Manifest{
+		Object:    object,
+		Size:      h.size,
+		SHA256:    hex.EncodeToString(h.total.Sum(nil)),
+		Chunks:    h.chunks,
+		CreatedAt: time.Now(),
+	}
+}
+
+// WriteManifest 将清单写入 object+ManifestSuffix
+func WriteManifest(ctx context.Context, open OpenFunc, bucket, object string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %v", err)
+	}
+	if err := writeObject(ctx, open, bucket, object+ManifestSuffix, data); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	return nil
+}
+
+// ReadManifest 读取 object 的校验清单；接口无法区分对象不存在和读取失败，调用方需自行决定如何处理错误
+func ReadManifest(ctx context.Context, open SourceFunc, bucket, object string) (*Manifest, error) {
+	var buf bytes.Buffer
+	err := copyObject(ctx, open, bucket, object+ManifestSuffix, func(chunk []byte) error {
+		buf.Write(chunk)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest of %s: %v", object, err)
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(buf.Bytes(), manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest of %s: %v", object, err)
+	}
+	return manifest, nil
+}
+
+// Verifier 按清单中的块边界校验读取到的数据，与读取时的分块方式无关
+type Verifier struct {
+	manifest *Manifest
+	total    hash.Hash
+	chunk    hash.Hash
+	index    int   // 当前校验的块
+	filled   int64 // 当前块已读取的字节数
+	size     int64
+}
+
+// NewVerifier 创建 Verifier
+func NewVerifier(manifest *Manifest) *Verifier {
+	return &Verifier{manifest: manifest, total: sha256.New(), chunk: sha256.New()}
+}
+
+// Write 校验一段数据，某个块读满后立即比较，不一致时返回 *ChecksumError
+func (v *Verifier) Write(p []byte) (int, error) {
+	n := len(p)
+	v.total.Write(p)
+	v.size += int64(n)
+	for len(p) > 0 && v.index < len(v.manifest.Chunks) {
+		expected := v.manifest.Chunks[v.index]
+		m := int(min(int64(len(p)), expected.Size-v.filled))
+		v.chunk.Write(p[:m])
+		v.filled += int64(m)
+		p = p[m:]
+		if v.filled == expected.Size {
+			if actual := hex.EncodeToString(v.chunk.Sum(nil)); actual != expected.SHA256 {
+				return n, &ChecksumError{Object: v.manifest.Object, Chunk: v.index, Offset: expected.Offset, Expected: expected.SHA256, Actual: actual}
+			}
+			v.chunk.Reset()
+			v.filled = 0
+			v.index++
+		}
+	}
+	if v.size > v.manifest.Size {
+		return n, &ChecksumError{Object: v.manifest.Object, Chunk: -1,
+			Expected: fmt.Sprintf("%d bytes", v.manifest.Size), Actual: fmt.Sprintf("more than %d bytes", v.manifest.Size)}
+	}
+	return n, nil
+}
+
+// Finish 数据读完后校验总大小和 SHA-256
+func (v *Verifier) Finish() error {
+	if v.size != v.manifest.Size {
+		return &ChecksumError{Object: v.manifest.Object, Chunk: -1,
+			Expected: fmt.Sprintf("%d bytes", v.manifest.Size), Actual: fmt.Sprintf("%d bytes", v.size)}
+	}
+	if actual := hex.EncodeToString(v.total.Sum(nil)); actual != v.manifest.SHA256 {
+		return &ChecksumError{Object: v.manifest.Object, Chunk: -1, Expected: "sha256 " + v.manifest.SHA256, Actual: actual}
+	}
+	return nil
+}
+
+// VerifySource 在 Source 上校验数据，读到 io.EOF 或 iterator.EOFMarker 时检查总大小和 SHA-256，
+// 不一致时返回 *ChecksumError 而不是正常结束
+func VerifySource(src iterator.Source, verifier *Verifier) iterator.Source {
+	return func() ([]byte, error) {
+		chunk, err := src()
+		if err == io.EOF || (err == nil && string(chunk) == iterator.EOFMarker) {
+			if verr := verifier.Finish(); verr != nil {
+				return nil, verr
+			}
+			return chunk, err
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, verr := verifier.Write(chunk); verr != nil {
+			return nil, verr
+		}
+		return chunk, nil
+	}
+}
+
+// hashFile 按 Upload 发送的块边界计算本地文件的校验清单
+func hashFile(r io.ReaderAt, parts []Part, chunkSize int, object string) (*Manifest, error) {
+	hasher := NewHasher()
+	buf := make([]byte, chunkSize)
+	for _, part := range parts {
+		section := io.NewSectionReader(r, part.Offset, part.Size)
+		for {
+			n, err := io.ReadFull(section, buf)
+			if n > 0 {
+				hasher.Add(buf[:n])
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read file: %v", err)
+			}
+		}
+	}
+	return hasher.Manifest(object), nil
+}
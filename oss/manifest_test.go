@@ -0,0 +1,148 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+)
+
+// writeArrowFile 写入包含 batches 个批次、每批 rows 行的 Arrow IPC stream 文件
+func writeArrowFile(t *testing.T, batches, rows int) string {
+	t.Helper()
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "name", Type: arrow.BinaryTypes.String},
+	}, nil)
+	var buf bytes.Buffer
+	writer := ipc.NewWriter(&buf, ipc.WithSchema(schema))
+	for b := 0; b < batches; b++ {
+		var sb strings.Builder
+		sb.WriteString("[")
+		for i := 0; i < rows; i++ {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			fmt.Fprintf(&sb, `{"id": %d, "name": "student-%d"}`, b*rows+i, b*rows+i)
+		}
+		sb.WriteString("]")
+		record, _, err := array.RecordFromJSON(memory.NewGoAllocator(), schema, strings.NewReader(sb.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writer.Write(record)
+		record.Release()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "students.arrow")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// corruptChunk 翻转清单中第 chunk 块的一个字节，分段上传时修改对应的 part 文件
+func corruptChunk(t *testing.T, store *LocalStore, bucket string, result *UploadResult, chunk int) {
+	t.Helper()
+	offset := result.Manifest.Chunks[chunk].Offset + result.Manifest.Chunks[chunk].Size/2
+	for _, part := range result.Parts {
+		if offset < part.Offset || offset >= part.Offset+part.Size {
+			continue
+		}
+		path, err := store.path(bucket, part.Object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[offset-part.Offset] ^= 0xFF
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	t.Fatalf("offset %d is not in any part", offset)
+}
+
+func TestManifestDetectsCorruption(t *testing.T) {
+	for _, partSize := range []int64{0, 1500} {
+		t.Run(fmt.Sprintf("part size %d", partSize), func(t *testing.T) {
+			ctx := context.Background()
+			path := writeArrowFile(t, 4, 50)
+			store := NewLocalStore(t.TempDir())
+			result, err := Upload(ctx, store.Put, "bucket", "students.arrow", path, UploadOptions{
+				ChunkSize: 512,
+				PartSize:  partSize,
+				Retries:   -1,
+				Store:     NewStateStore(t.TempDir()),
+			})
+			if err != nil {
+				t.Fatalf("Upload: %v", err)
+			}
+			if len(result.Manifest.Chunks) < 4 {
+				t.Fatalf("got %d chunks, want at least 4", len(result.Manifest.Chunks))
+			}
+
+			// 未修改时两种读取方式都能通过校验
+			out := filepath.Join(t.TempDir(), "out.arrow")
+			downloaded, err := Download(ctx, store.Get, "bucket", "students.arrow", out, DownloadOptions{RequireManifest: true, Validate: true})
+			if err != nil {
+				t.Fatalf("Download: %v", err)
+			}
+			if !downloaded.Verified || downloaded.Rows != 200 {
+				t.Fatalf("download verified=%v rows=%d, want verified 200 rows", downloaded.Verified, downloaded.Rows)
+			}
+			if rows, err := readAll(ctx, store); err != nil || rows != 200 {
+				t.Fatalf("ReadRecords = %d rows, %v", rows, err)
+			}
+
+			const corrupted = 2
+			corruptChunk(t, store, "bucket", result, corrupted)
+
+			var checksumErr *ChecksumError
+			out = filepath.Join(t.TempDir(), "corrupted.arrow")
+			_, err = Download(ctx, store.Get, "bucket", "students.arrow", out, DownloadOptions{RequireManifest: true})
+			if !errors.As(err, &checksumErr) || checksumErr.Chunk != corrupted {
+				t.Errorf("Download err = %v, want a checksum mismatch at chunk %d", err, corrupted)
+			}
+			if _, err := os.Stat(out); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("corrupted download left a file: %v", err)
+			}
+
+			_, err = readAll(ctx, store)
+			if !errors.As(err, &checksumErr) || checksumErr.Chunk != corrupted {
+				t.Errorf("ReadRecords err = %v, want a checksum mismatch at chunk %d", err, corrupted)
+			}
+		})
+	}
+}
+
+// readAll 通过 ReadRecords 读取全部记录，返回行数
+func readAll(ctx context.Context, store *LocalStore) (int64, error) {
+	it, err := ReadRecords(ctx, store.Get, "bucket", "students.arrow", ReadOptions{RequireManifest: true})
+	if err != nil {
+		return 0, err
+	}
+	defer it.Release()
+	var rows int64
+	for it.Next() {
+		rows += it.Record().NumRows()
+	}
+	return rows, it.Err()
+}
@@ -0,0 +1,110 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"test/iterator"
+)
+
+// OpenObject 打开读取对象的数据流，读取对象的函数都应通过它打开对象
+//
+// Upload 指定了 PartSize 且文件更大时分段写入，object 本身并不存在。object 读不到时按
+// object+PartsSuffix 索引依次读取各 part，拼接后的数据与原文件一致；index 为 nil 表示普通对象。
+func OpenObject(ctx context.Context, open SourceFunc, bucket, object string) (src iterator.Source, index *PartIndex, err error) {
+	src, err = open(ctx, bucket, object)
+	if err == nil {
+		// 数据服务在第一次 Recv 时才返回对象不存在等错误，先读取第一块
+		first, ferr := src()
+		if ferr == nil || ferr == io.EOF {
+			return prepend(first, ferr, src), nil, nil
+		}
+		err = fmt.Errorf("error receiving data: %w", ferr)
+	}
+	index, indexErr := readPartIndex(ctx, open, bucket, object)
+	if indexErr != nil {
+		return nil, nil, err
+	}
+	return partsSource(ctx, open, bucket, index), index, nil
+}
+
+// prepend 先返回已读取的第一块（或错误），再继续读取 src
+func prepend(first []byte, firstErr error, src iterator.Source) iterator.Source {
+	pending := true
+	return func() ([]byte, error) {
+		if pending {
+			pending = false
+			return first, firstErr
+		}
+		return src()
+	}
+}
+
+// partsSource 依次读取索引中的各 part，全部读完后检查总大小
+func partsSource(ctx context.Context, open SourceFunc, bucket string, index *PartIndex) iterator.Source {
+	var (
+		i      int
+		size   int64
+		src    iterator.Source
+		cancel context.CancelFunc
+	)
+	return func() ([]byte, error) {
+		for {
+			if src == nil {
+				if i == len(index.Parts) {
+					if size != index.Size {
+						return nil, fmt.Errorf("read %d bytes, expected %d from the part index", size, index.Size)
+					}
+					return nil, io.EOF
+				}
+				var partCtx context.Context
+				partCtx, cancel = context.WithCancel(ctx)
+				var err error
+				if src, err = open(partCtx, bucket, index.Parts[i].Object); err != nil {
+					cancel()
+					return nil, partError(i, index, err)
+				}
+			}
+			chunk, err := src()
+			if err == io.EOF || (err == nil && string(chunk) == iterator.EOFMarker) {
+				cancel()
+				src = nil
+				i++
+				continue
+			}
+			if err != nil {
+				cancel()
+				return nil, partError(i, index, err)
+			}
+			size += int64(len(chunk))
+			return chunk, nil
+		}
+	}
+}
+
+func partError(i int, index *PartIndex, err error) error {
+	return fmt.Errorf("part %d/%d (%s): %w", i+1, len(index.Parts), index.Parts[i].Object, err)
+}
+
+// readPartIndex 读取分段上传的索引对象
+func readPartIndex(ctx context.Context, open SourceFunc, bucket, object string) (*PartIndex, error) {
+	var buf bytes.Buffer
+	err := copyObject(ctx, open, bucket, object+PartsSuffix, func(chunk []byte) error {
+		buf.Write(chunk)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	index := &PartIndex{}
+	if err := json.Unmarshal(buf.Bytes(), index); err != nil {
+		return nil, fmt.Errorf("failed to parse part index: %v", err)
+	}
+	if len(index.Parts) == 0 {
+		return nil, errors.New("empty part index")
+	}
+	return index, nil
+}
@@ -0,0 +1,44 @@
+package oss
+
+import (
+	"context"
+	"log"
+	"test/iterator"
+)
+
+// ReadOptions 读取对象记录的参数
+type ReadOptions struct {
+	SkipVerify      bool // 不读取校验清单
+	RequireManifest bool // 没有校验清单时报错，默认只打印警告
+}
+
+// ReadRecords 读取对象中的 Arrow 记录，分段上传的对象通过 OpenObject 按 part 索引读取；默认按 object+ManifestSuffix 校验清单边读边校验，
+// 数据不一致时迭代器的 Err 返回包装了 *ChecksumError 的错误，可用 errors.As 取出
+func ReadRecords(ctx context.Context, open SourceFunc, bucket, object string, opts ReadOptions, iterOpts ...iterator.Option) (*iterator.RecordIterator, error) {
+	var verifier *Verifier
+	if !opts.SkipVerify {
+		manifest, err := ReadManifest(ctx, open, bucket, object)
+		switch {
+		case err == nil:
+			verifier = NewVerifier(manifest)
+		case opts.RequireManifest:
+			return nil, err
+		default:
+			log.Printf("Warning: %v, skipping checksum verification", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	src, _, err := OpenObject(ctx, open, bucket, object)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if verifier != nil {
+		src = VerifySource(src, verifier)
+	}
+	// Upload 按固定大小切分文件，块边界与消息边界无关，需要作为连续的字节流解码；
+	// 逐批写入的对象每块是一个完整的 stream，按字节流读取时同样是首尾相接的多个 stream
+	iterOpts = append(iterOpts, iterator.WithCloser(cancel), iterator.WithByteStream())
+	return iterator.New(src, iterOpts...), nil
+}
@@ -0,0 +1,78 @@
+package oss
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"test/statedir"
+	"time"
+)
+
+// UploadState 本地记录的上传进度，每个 part 上传成功后更新
+type UploadState struct {
+	Bucket    string    `json:"bucket"`
+	Object    string    `json:"object"`
+	File      string    `json:"file"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"modTime"`
+	PartSize  int64     `json:"partSize"`
+	Parts     int       `json:"parts"`  // 已确认的 part 数
+	Offset    int64     `json:"offset"` // 已确认的字节偏移量
+	StartedAt time.Time `json:"startedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// matches 本地文件自上次上传以来是否未被修改
+func (s *UploadState) matches(info os.FileInfo) bool {
+	return s.Size == info.Size() && s.ModTime.Equal(info.ModTime())
+}
+
+// StateStore 以目录形式保存上传进度，每个目标对象一个 JSON 文件
+type StateStore struct {
+	files *statedir.Dir[UploadState]
+}
+
+// DefaultStateDir 默认的状态目录 ~/.mira/uploads，无法获取用户目录时使用当前目录下的 .mira/uploads
+func DefaultStateDir() string {
+	return statedir.Default("uploads")
+}
+
+// NewStateStore 创建状态存储，dir 为空时使用默认目录
+func NewStateStore(dir string) *StateStore {
+	return &StateStore{files: statedir.New[UploadState](dir, "uploads", "upload state")}
+}
+
+// stateKey 上传进度的 key，对象名中的路径分隔符由 statedir 替换
+func stateKey(bucket, object string) string {
+	return bucket + "/" + object
+}
+
+// Save 写入上传进度
+func (s *StateStore) Save(state *UploadState) error {
+	state.UpdatedAt = time.Now()
+	return s.files.Save(stateKey(state.Bucket, state.Object), state)
+}
+
+// Load 读取上传进度，没有记录时返回 nil
+func (s *StateStore) Load(bucket, object string) (*UploadState, error) {
+	state, err := s.files.Load(stateKey(bucket, object))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return state, err
+}
+
+// List 按开始时间列出所有未完成的上传
+func (s *StateStore) List() ([]*UploadState, error) {
+	states, err := s.files.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].StartedAt.Before(states[j].StartedAt) })
+	return states, nil
+}
+
+// Remove 删除上传进度，文件不存在时不报错
+func (s *StateStore) Remove(bucket, object string) error {
+	return s.files.Remove(stateKey(bucket, object))
+}
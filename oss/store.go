@@ -0,0 +1,74 @@
+package oss
+
+import (
+	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
+	"context"
+	"errors"
+	"fmt"
+	"test/iterator"
+	"time"
+)
+
+// ErrNotExist 对象不存在，数据服务无法区分对象不存在和读取失败，只有本地目录实现会返回
+var ErrNotExist = errors.New("object does not exist")
+
+// ObjectInfo 对象信息
+type ObjectInfo struct {
+	Bucket  string
+	Object  string
+	Size    int64
+	SHA256  string    // 来自校验清单，没有清单时为空
+	ModTime time.Time // 本地文件的修改时间或清单的创建时间，都没有时为零值
+}
+
+// ObjectStore 对象存储，Get、Put 的方法值分别满足 SourceFunc、OpenFunc，
+// 可以直接传给 ReadRecords、Download、Upload、NewObjectWriter 等函数
+type ObjectStore interface {
+	// Put 打开写入对象的流，Writer.Close 返回 nil 后对象才可见
+	Put(ctx context.Context, bucket, object string) (Writer, error)
+	// Get 打开读取对象的数据流，读完时返回 io.EOF，取消 ctx 即可提前结束
+	Get(ctx context.Context, bucket, object string) (iterator.Source, error)
+	// Stat 返回对象的大小等信息
+	Stat(ctx context.Context, bucket, object string) (*ObjectInfo, error)
+}
+
+// clientStore 基于数据服务 ReadOSSData/WriteOSSData 的实现
+type clientStore struct {
+	get SourceFunc
+	put OpenFunc
+}
+
+// NewClientStore 返回通过数据服务读写 OSS 的 ObjectStore
+func NewClientStore(dataServiceClient *client.DataServiceClient) ObjectStore {
+	return &clientStore{get: ClientSource(dataServiceClient), put: ClientOpener(dataServiceClient)}
+}
+
+func (s *clientStore) Put(ctx context.Context, bucket, object string) (Writer, error) {
+	return s.put(ctx, bucket, object)
+}
+
+func (s *clientStore) Get(ctx context.Context, bucket, object string) (iterator.Source, error) {
+	return s.get(ctx, bucket, object)
+}
+
+// Stat 数据服务没有查询对象信息的接口，依次尝试校验清单、分段上传的索引，
+// 都没有时读取整个对象统计大小
+func (s *clientStore) Stat(ctx context.Context, bucket, object string) (*ObjectInfo, error) {
+	info := &ObjectInfo{Bucket: bucket, Object: object}
+	if manifest, err := ReadManifest(ctx, s.get, bucket, object); err == nil {
+		info.Size, info.SHA256, info.ModTime = manifest.Size, manifest.SHA256, manifest.CreatedAt
+		return info, nil
+	}
+	if index, err := readPartIndex(ctx, s.get, bucket, object); err == nil {
+		info.Size = index.Size
+		return info, nil
+	}
+	err := copyObject(ctx, s.get, bucket, object, func(chunk []byte) error {
+		info.Size += int64(len(chunk))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s/%s: %v", bucket, object, err)
+	}
+	return info, nil
+}
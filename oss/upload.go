@@ -0,0 +1,304 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/16
+	@note: 分块、可断点续传的 OSS 上传
+
+*
+*/
+package oss
+
+import (
+	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	// DefaultChunkSize 每条 WriteOSSData 消息的默认大小，低于 gRPC 默认的 4MB 消息大小
+	DefaultChunkSize = 1 << 20
+	// DefaultRetries 每个 part 失败后的默认重试次数
+	DefaultRetries = 3
+	// PartsSuffix 分段上传时索引对象名的后缀
+	PartsSuffix = ".parts"
+)
+
+// Writer 写入一个完整对象的客户端流
+type Writer interface {
+	Write(chunk []byte) error
+	// Close 结束流并等待服务端确认，返回 nil 才表示对象已写入
+	Close() error
+}
+
+// OpenFunc 打开写入指定对象的流
+type OpenFunc func(ctx context.Context, bucket, object string) (Writer, error)
+
+type streamWriter struct {
+	send  func(chunk []byte) error
+	close func() error
+}
+
+func (w *streamWriter) Write(chunk []byte) error { return w.send(chunk) }
+func (w *streamWriter) Close() error             { return w.close() }
+
+// ClientOpener 基于 WriteOSSData 客户端流的 OpenFunc
+func ClientOpener(dataServiceClient *client.DataServiceClient) OpenFunc {
+	return func(ctx context.Context, bucket, object string) (Writer, error) {
+		stream, err := dataServiceClient.WriteOSSData(ctx, bucket, object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OSS write stream: %v", err)
+		}
+		return &streamWriter{
+			send: func(chunk []byte) error {
+				return stream.Send(&pb.OSSWriteRequest{BucketName: bucket, ObjectName: object, Chunk: chunk})
+			},
+			close: func() error {
+				_, err := stream.CloseAndRecv()
+				return err
+			},
+		}, nil
+	}
+}
+
+// PartIndex 分段上传完成后写入 <object>.parts 的索引，按顺序拼接各 part 即为原文件
+type PartIndex struct {
+	Size     int64  `json:"size"`
+	PartSize int64  `json:"partSize"`
+	Parts    []Part `json:"parts"`
+}
+
+// Part 一个 part 对象
+type Part struct {
+	Object string `json:"object"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+}
+
+// PartName 第 i 个 part 的对象名
+func PartName(object string, i int) string {
+	return fmt.Sprintf("%s.part-%05d", object, i)
+}
+
+// UploadOptions 上传参数
+type UploadOptions struct {
+	ChunkSize int // 每条消息的字节数，默认 DefaultChunkSize
+	// PartSize 分段上传时每个 part 的字节数，续传时沿用上次的值。默认 0 表示不分段，
+	// 整个文件写为 object 一个对象；大于 0 且文件超过 PartSize 时写入 PartName(object, i)
+	// 和 object+PartsSuffix 索引，object 本身不存在，只能通过 OpenObject 读取。
+	//
+	// WriteOSSData 不支持追加，续传的粒度是 part：不分段时中断后只能从头上传，
+	// 分段时从最后一个已确认的 part 之后继续，最多重传一个 part。
+	PartSize   int64
+	Retries    int  // 每个 part 的重试次数，默认 DefaultRetries，负数表示不重试
+	Restart    bool // 忽略本地进度，从头上传
+	Store      *StateStore
+	OnProgress func(Progress)
+}
+
+// Progress 上传进度，每个 part 确认后回调一次
+type Progress struct {
+	Part   int
+	Parts  int
+	Offset int64
+	Size   int64
+}
+
+// UploadResult 上传结果
+type UploadResult struct {
+	Size         int64
+	Parts        []Part
+	ResumedParts int // 续传时跳过的已确认 part 数
+	Manifest     *Manifest
+}
+
+// Upload 将本地文件分块上传到 OSS
+//
+// 默认整个文件写入 object；指定 PartSize 且文件更大时依次写入 PartName(object, i)，
+// 每个 part 的流 Close 成功后把偏移量记录到本地状态文件，中断或失败后再次调用会跳过已确认的 part，
+// 全部完成后写入 object+PartsSuffix 索引。最后写入 object+ManifestSuffix 校验清单并删除状态文件。
+// 续传粒度见 UploadOptions.PartSize。
+func Upload(ctx context.Context, open OpenFunc, bucket, object, path string, opts UploadOptions) (*UploadResult, error) {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	if opts.Retries == 0 {
+		opts.Retries = DefaultRetries
+	}
+	if opts.Store == nil {
+		opts.Store = NewStateStore("")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %v", err)
+	}
+
+	state, err := opts.Store.Load(bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case state == nil || opts.Restart:
+		state = &UploadState{
+			Bucket:    bucket,
+			Object:    object,
+			File:      path,
+			Size:      info.Size(),
+			ModTime:   info.ModTime(),
+			PartSize:  opts.PartSize,
+			StartedAt: time.Now(),
+		}
+	case !state.matches(info):
+		return nil, fmt.Errorf("%s changed since the interrupted upload to %s/%s, restart the upload", path, bucket, object)
+	}
+	if err := opts.Store.Save(state); err != nil {
+		return nil, err
+	}
+
+	parts := splitParts(object, state.Size, state.PartSize)
+	result := &UploadResult{Size: state.Size, Parts: parts, ResumedParts: state.Parts}
+	if state.Parts > 0 {
+		log.Printf("Resuming upload of %s to %s/%s at part %d/%d (offset %d)", path, bucket, object, state.Parts+1, len(parts), state.Offset)
+	}
+
+	buf := make([]byte, opts.ChunkSize)
+	for i := state.Parts; i < len(parts); i++ {
+		part := parts[i]
+		err := retry(ctx, opts.Retries, func() error {
+			return uploadPart(ctx, open, bucket, part, file, buf)
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to upload part %d/%d (%s): %w", i+1, len(parts), part.Object, err)
+		}
+		state.Parts = i + 1
+		state.Offset = part.Offset + part.Size
+		if err := opts.Store.Save(state); err != nil {
+			return result, err
+		}
+		if opts.OnProgress != nil {
+			opts.OnProgress(Progress{Part: i + 1, Parts: len(parts), Offset: state.Offset, Size: state.Size})
+		}
+	}
+
+	// 按发送时的块边界重新计算本地文件的校验值，续传时跳过的 part 同样包含在内
+	manifest, err := hashFile(file, parts, opts.ChunkSize, object)
+	if err != nil {
+		return result, err
+	}
+	result.Manifest = manifest
+
+	if len(parts) > 1 {
+		index, err := json.Marshal(&PartIndex{Size: state.Size, PartSize: state.PartSize, Parts: parts})
+		if err != nil {
+			return result, fmt.Errorf("failed to marshal part index: %v", err)
+		}
+		err = retry(ctx, opts.Retries, func() error {
+			return writeObject(ctx, open, bucket, object+PartsSuffix, index)
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to write part index: %w", err)
+		}
+	}
+	err = retry(ctx, opts.Retries, func() error {
+		return WriteManifest(ctx, open, bucket, object, manifest)
+	})
+	if err != nil {
+		return result, err
+	}
+	return result, opts.Store.Remove(bucket, object)
+}
+
+// splitParts 计算各 part 的范围，不分段或文件不超过 partSize 时只有一个 part 且直接写入 object
+func splitParts(object string, size, partSize int64) []Part {
+	if partSize <= 0 || size <= partSize {
+		return []Part{{Object: object, Size: size}}
+	}
+	var parts []Part
+	for offset := int64(0); offset < size; offset += partSize {
+		parts = append(parts, Part{
+			Object: PartName(object, len(parts)),
+			Offset: offset,
+			Size:   min(partSize, size-offset),
+		})
+	}
+	return parts
+}
+
+// uploadPart 用一个流写入文件中的一段
+func uploadPart(ctx context.Context, open OpenFunc, bucket string, part Part, file io.ReaderAt, buf []byte) error {
+	writer, err := open(ctx, bucket, part.Object)
+	if err != nil {
+		return err
+	}
+	section := io.NewSectionReader(file, part.Offset, part.Size)
+	for {
+		n, err := io.ReadFull(section, buf)
+		if n > 0 {
+			if werr := writer.Write(buf[:n]); werr != nil {
+				writer.Close()
+				return fmt.Errorf("failed to send data chunk: %v", werr)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			writer.Close()
+			return fmt.Errorf("failed to read file: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close OSS stream: %v", err)
+	}
+	return nil
+}
+
+// writeObject 用一个流写入一个小对象
+func writeObject(ctx context.Context, open OpenFunc, bucket, object string, data []byte) error {
+	writer, err := open(ctx, bucket, object)
+	if err != nil {
+		return err
+	}
+	if err := writer.Write(data); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to send data chunk: %v", err)
+	}
+	return writer.Close()
+}
+
+// retry 执行 fn，失败后按 1s、2s、4s... 退避重试，context 取消时立即返回
+func retry(ctx context.Context, retries int, fn func() error) error {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return errors.Join(err, ctx.Err())
+		}
+		if attempt >= retries {
+			return err
+		}
+		log.Printf("Attempt %d failed: %v, retrying in %v", attempt+1, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,165 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFile 在临时目录中写入 size 字节的随机数据
+func writeTestFile(t *testing.T, size int) (string, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	path := filepath.Join(t.TempDir(), "data.arrow")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+// readObject 通过 OpenObject 读取对象的全部字节
+func readObject(t *testing.T, store *LocalStore, bucket, object string) []byte {
+	t.Helper()
+	src, _, err := OpenObject(context.Background(), store.Get, bucket, object)
+	if err != nil {
+		t.Fatalf("OpenObject: %v", err)
+	}
+	var buf bytes.Buffer
+	for {
+		chunk, err := src()
+		if err == io.EOF {
+			return buf.Bytes()
+		}
+		if err != nil {
+			t.Fatalf("read object: %v", err)
+		}
+		buf.Write(chunk)
+	}
+}
+
+func TestUploadSingleObject(t *testing.T) {
+	path, data := writeTestFile(t, 5000)
+	store := NewLocalStore(t.TempDir())
+
+	result, err := Upload(context.Background(), store.Put, "bucket", "dir/data.arrow", path, UploadOptions{
+		ChunkSize: 64,
+		Retries:   -1,
+		Store:     NewStateStore(t.TempDir()),
+	})
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if len(result.Parts) != 1 || result.Parts[0].Object != "dir/data.arrow" {
+		t.Fatalf("parts = %+v, want the object itself", result.Parts)
+	}
+	got, err := os.ReadFile(filepath.Join(store.Root(), "bucket", "dir", "data.arrow"))
+	if err != nil {
+		t.Fatalf("object not written under the requested name: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("object content differs from the file")
+	}
+	if _, err := os.Stat(filepath.Join(store.Root(), "bucket", "dir", "data.arrow"+PartsSuffix)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("unexpected part index: %v", err)
+	}
+}
+
+func TestUploadResume(t *testing.T) {
+	const (
+		bucket   = "bucket"
+		object   = "data.arrow"
+		partSize = 1000
+		failPart = 3
+	)
+	path, data := writeTestFile(t, 9500)
+	store := NewLocalStore(t.TempDir())
+	states := NewStateStore(t.TempDir())
+	opts := UploadOptions{ChunkSize: 64, PartSize: partSize, Retries: -1, Store: states}
+
+	// 第一次上传在第 failPart 个 part 失败
+	failing := func(ctx context.Context, bucket, name string) (Writer, error) {
+		if name == PartName(object, failPart) {
+			return nil, errors.New("connection reset")
+		}
+		return store.Put(ctx, bucket, name)
+	}
+	result, err := Upload(context.Background(), failing, bucket, object, path, opts)
+	if err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Fatalf("first upload err = %v, want the part failure", err)
+	}
+	if len(result.Parts) != 10 {
+		t.Fatalf("got %d parts, want 10", len(result.Parts))
+	}
+	state, err := states.Load(bucket, object)
+	if err != nil || state == nil {
+		t.Fatalf("state after failure = %v, %v", state, err)
+	}
+	if state.Parts != failPart || state.Offset != failPart*partSize {
+		t.Errorf("state = %d parts at offset %d, want %d at %d", state.Parts, state.Offset, failPart, failPart*partSize)
+	}
+
+	// 再次上传跳过已确认的 part，即使改变了 PartSize 也沿用上次的值
+	var opened []string
+	recording := func(ctx context.Context, bucket, name string) (Writer, error) {
+		opened = append(opened, name)
+		return store.Put(ctx, bucket, name)
+	}
+	opts.PartSize = 4000
+	result, err = Upload(context.Background(), recording, bucket, object, path, opts)
+	if err != nil {
+		t.Fatalf("resumed upload: %v", err)
+	}
+	if result.ResumedParts != failPart {
+		t.Errorf("ResumedParts = %d, want %d", result.ResumedParts, failPart)
+	}
+	if opened[0] != PartName(object, failPart) {
+		t.Errorf("resumed upload started at %s, want %s", opened[0], PartName(object, failPart))
+	}
+	if want := len(result.Parts) - failPart + 2; len(opened) != want {
+		t.Errorf("opened %d objects %v, want %d parts plus index and manifest", len(opened), opened, want)
+	}
+
+	if got := readObject(t, store, bucket, object); !bytes.Equal(got, data) {
+		t.Errorf("uploaded %d bytes differ from the %d byte file", len(got), len(data))
+	}
+	manifest, err := ReadManifest(context.Background(), store.Get, bucket, object)
+	if err != nil {
+		t.Fatalf("ReadManifest: %v", err)
+	}
+	if manifest.Size != int64(len(data)) || manifest.SHA256 != result.Manifest.SHA256 {
+		t.Errorf("manifest = %d bytes %s, want %d bytes %s", manifest.Size, manifest.SHA256, len(data), result.Manifest.SHA256)
+	}
+	if state, err := states.Load(bucket, object); err != nil || state != nil {
+		t.Errorf("state file not removed: %+v, %v", state, err)
+	}
+}
+
+func TestUploadChangedFile(t *testing.T) {
+	path, _ := writeTestFile(t, 3000)
+	store := NewLocalStore(t.TempDir())
+	states := NewStateStore(t.TempDir())
+	failing := func(ctx context.Context, bucket, name string) (Writer, error) {
+		if name == PartName("data.arrow", 1) {
+			return nil, errors.New("connection reset")
+		}
+		return store.Put(ctx, bucket, name)
+	}
+	opts := UploadOptions{PartSize: 1000, Retries: -1, Store: states}
+	if _, err := Upload(context.Background(), failing, "bucket", "data.arrow", path, opts); err == nil {
+		t.Fatal("first upload should fail")
+	}
+	if err := os.WriteFile(path, []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Upload(context.Background(), store.Put, "bucket", "data.arrow", path, opts)
+	if err == nil || !strings.Contains(err.Error(), "restart the upload") {
+		t.Fatalf("err = %v, want a changed file error", err)
+	}
+}
@@ -0,0 +1,146 @@
+package oss
+
+import (
+	"context"
+	"errors"
+)
+
+// ObjectWriter 将写入的字节按 ChunkSize 分块，通过一个流写入对象，同时计算每个块的校验值；
+// Close 成功后对象才写入完成，并写入 object+ManifestSuffix 校验清单
+type ObjectWriter struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	open   OpenFunc
+	bucket string
+	object string
+
+	writer   Writer
+	hasher   *Hasher
+	buf      []byte
+	manifest *Manifest
+	err      error
+	closed   bool
+}
+
+// NewObjectWriter 打开写入 bucket/object 的流，chunkSize <= 0 时使用 DefaultChunkSize
+func NewObjectWriter(ctx context.Context, open OpenFunc, bucket, object string, chunkSize int) (*ObjectWriter, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	writer, err := open(ctx, bucket, object)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &ObjectWriter{
+		ctx:    ctx,
+		cancel: cancel,
+		open:   open,
+		bucket: bucket,
+		object: object,
+		writer: writer,
+		hasher: NewHasher(),
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (w *ObjectWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		m := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		p = p[m:]
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+// WriteChunk 先发送缓冲区中的数据，再把 chunk 作为单独的一条消息发送，
+// 用于保持逐批写入时每条消息是一段完整 IPC stream 的约定
+func (w *ObjectWriter) WriteChunk(chunk []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.send(chunk)
+}
+
+// flush 发送缓冲区中的数据，每个块使用新的切片，避免流在发送前引用被覆盖的缓冲区
+func (w *ObjectWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	chunk := w.buf
+	w.buf = make([]byte, 0, cap(chunk))
+	return w.send(chunk)
+}
+
+func (w *ObjectWriter) send(chunk []byte) error {
+	if len(chunk) == 0 {
+		return nil
+	}
+	if err := w.writer.Write(chunk); err != nil {
+		w.err = err
+		return err
+	}
+	w.hasher.Add(chunk)
+	return nil
+}
+
+// Size 已发送的字节数
+func (w *ObjectWriter) Size() int64 {
+	return w.hasher.size
+}
+
+// Manifest 返回 Close 成功后写入的校验清单
+func (w *ObjectWriter) Manifest() *Manifest {
+	return w.manifest
+}
+
+// Close 发送剩余数据，等待服务端确认后写入校验清单；重复调用时直接返回
+func (w *ObjectWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer w.cancel()
+	if w.err != nil {
+		w.writer.Close()
+		return w.err
+	}
+	if err := w.flush(); err != nil {
+		w.writer.Close()
+		return err
+	}
+	w.err = errors.New("object writer is closed")
+	if err := w.writer.Close(); err != nil {
+		return err
+	}
+	manifest := w.hasher.Manifest(w.object)
+	if err := WriteManifest(w.ctx, w.open, w.bucket, w.object, manifest); err != nil {
+		return err
+	}
+	w.manifest = manifest
+	return nil
+}
+
+// Abort 取消写入流并放弃对象，不会写入校验清单；Close 之后调用没有作用
+func (w *ObjectWriter) Abort() {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.err = errors.New("object writer is aborted")
+	w.cancel()
+	w.writer.Close()
+}
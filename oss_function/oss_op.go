@@ -10,95 +10,73 @@
 package main
 
 import (
-	"bytes"
-	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
-	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
 	"context"
+	"flag"
 	"fmt"
-	"github.com/apache/arrow/go/v15/arrow/ipc"
-	"github.com/apache/arrow/go/v15/arrow/memory"
 	"log"
+	"test/config"
+	"test/oss"
 	"test/utils"
 )
 
 func main() {
 	ctx := context.Background()
 
-	// 创建一个ServerInfo实例
-	serverInfo := &pb.ServerInfo{
-		//Namespace:   "mira1", // 如果在Kubernetes集群中使用，可以指定命名空间
-		ServiceName: "192.168.40.243",
-		ServicePort: "30015",
-	}
-	dataServiceClient, err := client.NewDataServiceClient(ctx, serverInfo)
+	// 解析连接参数，通过 --profile 切换环境
+	opts := config.RegisterFlags(flag.CommandLine)
+	config.RegisterStoreFlags(flag.CommandLine, opts)
+	flag.Parse()
+
+	// 指定 --local-store 时读写本地目录，不连接数据服务
+	store, err := config.NewObjectStore(ctx, opts, nil)
 	if err != nil {
-		log.Fatalf("failed to initialize DataServiceClient: %v", err)
+		log.Fatalf("failed to open object store: %v", err)
 	}
-	//// 写入oss
-	//bucketName := "data-service"
-	//objectName := "bytedata.txt"
+	//// 写入oss：按块上传，大文件拆分为多个 part，中断后再次运行会跳过已确认的 part
 	//filePath := "C:\\software\\go\\src\\test\\oss_function\\00d22d1015704b1ebf46e73a2e2235d7.arrow"
-	//data1 := []byte("some data")
-	//response, err := dataServiceClient.WriteOSSData(ctx, bucketName, objectName, filePath, data1)
+	//result, err := oss.Upload(ctx, store.Put, "data-service", "bytedata.arrow", filePath, oss.UploadOptions{})
 	//if err != nil {
 	//	log.Fatalf("Failed to write OSS data: %v", err)
 	//}
-	//log.Printf("Response: %v", response)
+	//log.Printf("Uploaded %d bytes in %d parts, sha256 %s", result.Size, len(result.Parts), result.Manifest.SHA256)
 
-	// 读oss数据
-	request := &pb.OSSReadRequest{
-		BucketName: "data-service",
-		ObjectName: "data/ab58867b-dcd8-47bd-ab96-36324abf0ba6_partition_102995875df440ffa1e19a43f1401ef5.arrow",
-	}
+	//// 读取批处理作业的全部分区，按作业的 OrderByColumn 归并为全局有序的记录流
+	//manifest := &partition.Manifest{BucketName: "data-service", DataObject: "data/ab58867b-dcd8-47bd-ab96-36324abf0ba6",
+	//	OrderByColumn: "id", Partitions: []string{"102995875df440ffa1e19a43f1401ef5"}}
+	//it, err := partition.OpenManifest(ctx, store.Get, manifest, partition.Options{OrderBy: manifest.OrderByColumn})
+
+	// 读oss数据，存在校验清单时边读边校验
+	bucketName := "data-service"
+	objectName := "data/ab58867b-dcd8-47bd-ab96-36324abf0ba6_partition_102995875df440ffa1e19a43f1401ef5.arrow"
 
-	stream, err := dataServiceClient.ReadOSSData(ctx, request)
+	it, err := oss.ReadRecords(ctx, store.Get, bucketName, objectName, oss.ReadOptions{})
 	if err != nil {
 		log.Fatalf("Failed to read stream: %v", err)
 	}
+	defer it.Release()
 
 	// 处理流式数据
-	for {
-		response, err := stream.Recv()
-		if err != nil {
-			log.Fatalf("Error receiving data: %v", err)
-		}
+	for it.Next() {
+		record := it.Record()
 
-		// 解码 arrow_batch 数据
-		arrowBatch := response.Chunk // 获取 arrow_batch 字段
-		if len(arrowBatch) == 0 {
-			log.Println("Received empty arrow batch.")
-			continue
-		}
+		// 打印 Record 的 schema 信息
+		fmt.Println("Record schema:", record.Schema())
 
-		// 创建 Arrow 内存分配器
-		allocator := memory.NewGoAllocator()
-		reader, err := ipc.NewReader(bytes.NewReader(arrowBatch), ipc.WithAllocator(allocator))
+		// 提取并打印每一行的数据
+		rows, err := utils.ExtractRowData(record)
 		if err != nil {
-			log.Fatalf("Failed to create Arrow reader: %v", err)
+			log.Fatalf("Error extracting row data: %v", err)
 		}
-		defer reader.Release()
 
-		for reader.Next() {
-			record := reader.Record()
-			defer record.Release()
-
-			// 打印 Record 的 schema 信息
-			fmt.Println("Record schema:", record.Schema())
-
-			// 提取并打印每一行的数据
-			rows, err := utils.ExtractRowData(record)
-			if err != nil {
-				log.Fatalf("Error extracting row data: %v", err)
-			}
-
-			for rowIndex, row := range rows {
-				fmt.Printf("Row %d: ", rowIndex+1)
-				for colIndex, value := range row {
-					fmt.Printf("%s=%v ", record.ColumnName(colIndex), value)
-				}
-				fmt.Println()
+		for rowIndex, row := range rows {
+			fmt.Printf("Row %d: ", rowIndex+1)
+			for colIndex, value := range row {
+				fmt.Printf("%s=%v ", record.ColumnName(colIndex), value)
 			}
+			fmt.Println()
 		}
 	}
-
+	if err := it.Err(); err != nil {
+		log.Fatalf("Error receiving data: %v", err)
+	}
 }
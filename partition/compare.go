@@ -0,0 +1,109 @@
+package partition
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+)
+
+// compareFunc 比较 a 的第 i 行与 b 的第 j 行，空值最小
+type compareFunc func(a arrow.Array, i int, b arrow.Array, j int) int
+
+// valueArray 按下标返回可比较值的数组
+type valueArray[T cmp.Ordered] interface {
+	arrow.Array
+	Value(int) T
+}
+
+// orderedCompare 比较 Value 返回有序类型的数组
+func orderedCompare[T cmp.Ordered, A valueArray[T]]() compareFunc {
+	return withNulls(func(a arrow.Array, i int, b arrow.Array, j int) int {
+		return cmp.Compare(a.(A).Value(i), b.(A).Value(j))
+	})
+}
+
+// withNulls 在 compare 外处理空值
+func withNulls(compare compareFunc) compareFunc {
+	return func(a arrow.Array, i int, b arrow.Array, j int) int {
+		aNull, bNull := a.IsNull(i), b.IsNull(j)
+		switch {
+		case aNull && bNull:
+			return 0
+		case aNull:
+			return -1
+		case bNull:
+			return 1
+		}
+		return compare(a, i, b, j)
+	}
+}
+
+// descending 反转比较结果，空值随之排到最后
+func descending(compare compareFunc) compareFunc {
+	return func(a arrow.Array, i int, b arrow.Array, j int) int {
+		return -compare(a, i, b, j)
+	}
+}
+
+// newCompareFunc 返回排序列类型的比较函数
+func newCompareFunc(dt arrow.DataType) (compareFunc, error) {
+	switch dt.ID() {
+	case arrow.INT8:
+		return orderedCompare[int8, *array.Int8](), nil
+	case arrow.INT16:
+		return orderedCompare[int16, *array.Int16](), nil
+	case arrow.INT32:
+		return orderedCompare[int32, *array.Int32](), nil
+	case arrow.INT64:
+		return orderedCompare[int64, *array.Int64](), nil
+	case arrow.UINT8:
+		return orderedCompare[uint8, *array.Uint8](), nil
+	case arrow.UINT16:
+		return orderedCompare[uint16, *array.Uint16](), nil
+	case arrow.UINT32:
+		return orderedCompare[uint32, *array.Uint32](), nil
+	case arrow.UINT64:
+		return orderedCompare[uint64, *array.Uint64](), nil
+	case arrow.FLOAT32:
+		return orderedCompare[float32, *array.Float32](), nil
+	case arrow.FLOAT64:
+		return orderedCompare[float64, *array.Float64](), nil
+	case arrow.STRING:
+		return orderedCompare[string, *array.String](), nil
+	case arrow.LARGE_STRING:
+		return orderedCompare[string, *array.LargeString](), nil
+	case arrow.DATE32:
+		return orderedCompare[arrow.Date32, *array.Date32](), nil
+	case arrow.DATE64:
+		return orderedCompare[arrow.Date64, *array.Date64](), nil
+	case arrow.TIME32:
+		return orderedCompare[arrow.Time32, *array.Time32](), nil
+	case arrow.TIME64:
+		return orderedCompare[arrow.Time64, *array.Time64](), nil
+	case arrow.TIMESTAMP:
+		return orderedCompare[arrow.Timestamp, *array.Timestamp](), nil
+	case arrow.BOOL:
+		return withNulls(func(a arrow.Array, i int, b arrow.Array, j int) int {
+			x, y := a.(*array.Boolean).Value(i), b.(*array.Boolean).Value(j)
+			switch {
+			case x == y:
+				return 0
+			case y:
+				return -1
+			}
+			return 1
+		}), nil
+	case arrow.BINARY:
+		return withNulls(func(a arrow.Array, i int, b arrow.Array, j int) int {
+			return bytes.Compare(a.(*array.Binary).Value(i), b.(*array.Binary).Value(j))
+		}), nil
+	case arrow.DECIMAL128:
+		// 同一列的精度和小数位数相同，可以直接比较未缩放的值
+		return withNulls(func(a arrow.Array, i int, b arrow.Array, j int) int {
+			return a.(*array.Decimal128).Value(i).Cmp(b.(*array.Decimal128).Value(j))
+		}), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", dt)
+}
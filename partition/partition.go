@@ -0,0 +1,94 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/18
+	@note: 读取批处理作业按分区写入 OSS 的结果
+
+*
+*/
+package partition
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"test/oss"
+)
+
+// Manifest 作业输出的分区清单
+//
+// ReadOSSData 无法按前缀列出对象，分区 ID 随机生成时读取作业的全部输出需要一份本地清单；
+// 分区按 0、1、2... 编号时可以用 Probe 得到清单。
+type Manifest struct {
+	BucketName    string `json:"bucketName"`
+	DataObject    string `json:"dataObject"`
+	OrderByColumn string `json:"orderByColumn,omitempty"`
+	// Partitions 分区 ID，或者包含 "/" 或以 .arrow 结尾的完整对象名
+	Partitions []string `json:"partitions"`
+}
+
+// Prefix 分区对象名的前缀，即去掉 .arrow 后缀的 DataObject
+func Prefix(dataObject string) string {
+	return strings.TrimSuffix(dataObject, ".arrow")
+}
+
+// ObjectName 分区 id 的对象名，例如 data/<uuid>_partition_<id>.arrow
+func ObjectName(dataObject, id string) string {
+	return fmt.Sprintf("%s_partition_%s.arrow", Prefix(dataObject), id)
+}
+
+// Objects 按清单中的顺序返回各分区的对象名
+func (m *Manifest) Objects() []string {
+	objects := make([]string, 0, len(m.Partitions))
+	for _, p := range m.Partitions {
+		if strings.Contains(p, "/") || strings.HasSuffix(p, ".arrow") {
+			objects = append(objects, p)
+		} else {
+			objects = append(objects, ObjectName(m.DataObject, p))
+		}
+	}
+	return objects
+}
+
+// LoadManifest 读取本地的分区清单
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partition manifest: %v", err)
+	}
+	return parseManifest(data)
+}
+
+// Probe 依次对 <Prefix(dataObject)>_partition_0.arrow、_partition_1.arrow... 调用 stat，
+// 直到某个分区不存在，返回找到的分区；第一个分区就不存在时返回错误。
+//
+// 数据服务无法区分对象不存在和读取失败，stat 返回任何错误都视为分区结束。
+func Probe(ctx context.Context, stat func(ctx context.Context, bucket, object string) (*oss.ObjectInfo, error), bucket, dataObject string) (*Manifest, error) {
+	manifest := &Manifest{BucketName: bucket, DataObject: dataObject}
+	for i := 0; ; i++ {
+		id := strconv.Itoa(i)
+		if _, err := stat(ctx, bucket, ObjectName(dataObject, id)); err != nil {
+			if i == 0 {
+				return nil, fmt.Errorf("no partitions found for %s/%s: %w", bucket, dataObject, err)
+			}
+			return manifest, nil
+		}
+		manifest.Partitions = append(manifest.Partitions, id)
+	}
+}
+
+func parseManifest(data []byte) (*Manifest, error) {
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse partition manifest: %v", err)
+	}
+	if len(manifest.Partitions) == 0 {
+		return nil, errors.New("partition manifest lists no partitions")
+	}
+	return manifest, nil
+}
@@ -0,0 +1,39 @@
+package partition
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"test/oss"
+)
+
+func TestProbe(t *testing.T) {
+	ctx := context.Background()
+	store := oss.NewLocalStore(t.TempDir())
+	for _, id := range []string{"0", "1", "2", "4"} {
+		w, err := store.Put(ctx, "bucket", ObjectName("data/job.arrow", id))
+		if err == nil {
+			err = w.Close()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifest, err := Probe(ctx, store.Stat, "bucket", "data/job.arrow")
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	// 3 不存在，之后的分区不再探测
+	want := []string{"data/job_partition_0.arrow", "data/job_partition_1.arrow", "data/job_partition_2.arrow"}
+	if !reflect.DeepEqual(manifest.Objects(), want) {
+		t.Errorf("objects = %v, want %v", manifest.Objects(), want)
+	}
+
+	_, err = Probe(ctx, store.Stat, "bucket", "data/other")
+	if !errors.Is(err, oss.ErrNotExist) {
+		t.Errorf("err = %v, want ErrNotExist", err)
+	}
+}
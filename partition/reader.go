@@ -0,0 +1,412 @@
+package partition
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"test/iterator"
+	"test/oss"
+	"test/rebatch"
+)
+
+// DefaultBatchRows 归并时每批输出的默认最大行数
+const DefaultBatchRows = 4096
+
+// Options 读取参数
+type Options struct {
+	// OrderBy 非空时同时打开所有分区并按该列 k 路归并，要求各分区内已按该列排好序；
+	// 为空时按顺序逐个读取分区
+	OrderBy    string
+	Descending bool
+	BatchRows  int             // 归并时每批输出的最大行数，默认 DefaultBatchRows
+	Verify     oss.ReadOptions // 各分区的校验清单参数
+	Allocator  memory.Allocator
+}
+
+// Reader 将多个分区对象合并为一个记录流，满足 iterator.Records；所有分区的 schema 必须相同
+//
+// 归并时空值排在升序的最前面、降序的最后面，与 Spark 的默认规则一致，键相同的行按分区顺序输出。
+type Reader struct {
+	ctx     context.Context
+	open    oss.SourceFunc
+	bucket  string
+	objects []string
+	opts    Options
+
+	schema *arrow.Schema
+	record arrow.Record
+	err    error
+	done   bool
+
+	// 顺序读取
+	current *iterator.RecordIterator
+	next    int
+
+	// 归并读取
+	sources []*source
+	heap    mergeHeap
+	key     int
+	compare compareFunc
+
+	chunks int
+	bytes  int64
+}
+
+// source 归并中的一个分区
+type source struct {
+	index  int
+	object string
+	it     *iterator.RecordIterator
+	record arrow.Record
+	key    arrow.Array
+	row    int
+	last   arrow.Array // 上一批最后一行的键，用于检查分区是否有序
+}
+
+// Open 打开 bucket 中的分区对象
+func Open(ctx context.Context, open oss.SourceFunc, bucket string, objects []string, opts Options) (*Reader, error) {
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no partitions to read")
+	}
+	if opts.BatchRows <= 0 {
+		opts.BatchRows = DefaultBatchRows
+	}
+	if opts.Allocator == nil {
+		opts.Allocator = memory.NewGoAllocator()
+	}
+	r := &Reader{ctx: ctx, open: open, bucket: bucket, objects: objects, opts: opts}
+	if opts.OrderBy == "" {
+		return r, nil
+	}
+	if err := r.openAll(); err != nil {
+		r.Release()
+		return nil, err
+	}
+	return r, nil
+}
+
+// OpenManifest 打开清单中的所有分区
+func OpenManifest(ctx context.Context, open oss.SourceFunc, manifest *Manifest, opts Options) (*Reader, error) {
+	return Open(ctx, open, manifest.BucketName, manifest.Objects(), opts)
+}
+
+func (r *Reader) openPartition(object string) (*iterator.RecordIterator, error) {
+	it, err := oss.ReadRecords(r.ctx, r.open, r.bucket, object, r.opts.Verify, iterator.WithAllocator(r.opts.Allocator))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open partition %s: %w", object, err)
+	}
+	return it, nil
+}
+
+// checkSchema 检查分区的 schema 与第一个分区一致
+func (r *Reader) checkSchema(object string, schema *arrow.Schema) error {
+	if r.schema == nil {
+		r.schema = schema
+		return nil
+	}
+	if !r.schema.Equal(schema) {
+		return fmt.Errorf("partition %s has a different schema:\n%v\nexpected:\n%v", object, schema, r.schema)
+	}
+	return nil
+}
+
+// Schema 返回分区的 schema，在读到第一批记录之前可能为 nil
+func (r *Reader) Schema() *arrow.Schema {
+	return r.schema
+}
+
+// Next 读取下一批记录
+func (r *Reader) Next() bool {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	if r.done {
+		return false
+	}
+	var err error
+	if r.sources != nil {
+		r.record, err = r.merge()
+	} else {
+		r.record, err = r.sequential()
+	}
+	if err != nil {
+		r.fail(err)
+		return false
+	}
+	if r.record == nil {
+		r.Release()
+		return false
+	}
+	return true
+}
+
+// sequential 按顺序读取下一个分区中的记录
+func (r *Reader) sequential() (arrow.Record, error) {
+	for {
+		if r.current == nil {
+			if r.next >= len(r.objects) {
+				return nil, nil
+			}
+			it, err := r.openPartition(r.objects[r.next])
+			if err != nil {
+				return nil, err
+			}
+			r.current = it
+			r.next++
+		}
+		object := r.objects[r.next-1]
+		if r.current.Next() {
+			record := r.current.Record()
+			if err := r.checkSchema(object, record.Schema()); err != nil {
+				return nil, err
+			}
+			record.Retain()
+			return record, nil
+		}
+		err := r.current.Err()
+		r.addStats(r.current)
+		r.current.Release()
+		r.current = nil
+		if err != nil {
+			return nil, fmt.Errorf("partition %s: %w", object, err)
+		}
+	}
+}
+
+// openAll 打开所有分区并读取各自的第一批记录
+func (r *Reader) openAll() error {
+	r.key = -1
+	for i, object := range r.objects {
+		it, err := r.openPartition(object)
+		if err != nil {
+			return err
+		}
+		s := &source{index: i, object: object, it: it}
+		r.sources = append(r.sources, s)
+		ok, err := r.advance(s)
+		if err != nil {
+			return err
+		}
+		if ok {
+			r.heap.sources = append(r.heap.sources, s)
+		}
+	}
+	if r.key < 0 {
+		// 所有分区均为空
+		return nil
+	}
+	r.heap.less = func(a, b *source) bool {
+		if c := r.compare(a.key, a.row, b.key, b.row); c != 0 {
+			return c < 0
+		}
+		return a.index < b.index
+	}
+	heap.Init(&r.heap)
+	return nil
+}
+
+// advance 读取分区的下一批非空记录，分区读完时返回 false
+func (r *Reader) advance(s *source) (bool, error) {
+	if s.record != nil {
+		if s.last != nil {
+			s.last.Release()
+		}
+		s.last = array.NewSlice(s.key, int64(s.key.Len()-1), int64(s.key.Len()))
+		s.record.Release()
+		s.record = nil
+		s.key = nil
+	}
+	for s.it.Next() {
+		record := s.it.Record()
+		if record.NumRows() == 0 {
+			continue
+		}
+		if err := r.checkSchema(s.object, record.Schema()); err != nil {
+			return false, err
+		}
+		if r.key < 0 {
+			if err := r.initKey(); err != nil {
+				return false, err
+			}
+		}
+		record.Retain()
+		s.record = record
+		s.key = record.Column(r.key)
+		s.row = 0
+		if s.last != nil && r.compare(s.last, 0, s.key, 0) > 0 {
+			return false, r.unsorted(s)
+		}
+		return true, nil
+	}
+	r.addStats(s.it)
+	if err := s.it.Err(); err != nil {
+		return false, fmt.Errorf("partition %s: %w", s.object, err)
+	}
+	return false, nil
+}
+
+// initKey 根据第一批记录的 schema 确定排序列
+func (r *Reader) initKey() error {
+	indices := r.schema.FieldIndices(r.opts.OrderBy)
+	if len(indices) == 0 {
+		return fmt.Errorf("order by column %q not found in partition schema %v", r.opts.OrderBy, r.schema)
+	}
+	compare, err := newCompareFunc(r.schema.Field(indices[0]).Type)
+	if err != nil {
+		return fmt.Errorf("cannot order by column %q: %v", r.opts.OrderBy, err)
+	}
+	if r.opts.Descending {
+		compare = descending(compare)
+	}
+	r.key = indices[0]
+	r.compare = compare
+	return nil
+}
+
+func (r *Reader) unsorted(s *source) error {
+	return fmt.Errorf("partition %s is not sorted by %q", s.object, r.opts.OrderBy)
+}
+
+// merge 每次从键最小的分区取出一段连续的行，直到凑满 BatchRows 行
+func (r *Reader) merge() (arrow.Record, error) {
+	var pieces []arrow.Record
+	defer func() {
+		for _, piece := range pieces {
+			piece.Release()
+		}
+	}()
+	rows := 0
+	for rows < r.opts.BatchRows && r.heap.Len() > 0 {
+		s := r.heap.sources[0]
+		end, err := r.run(s, r.opts.BatchRows-rows)
+		if err != nil {
+			return nil, err
+		}
+		pieces = append(pieces, s.record.NewSlice(int64(s.row), int64(end)))
+		rows += end - s.row
+		s.row = end
+		if s.row < int(s.record.NumRows()) {
+			heap.Fix(&r.heap, 0)
+			continue
+		}
+		ok, err := r.advance(s)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			heap.Fix(&r.heap, 0)
+		} else {
+			heap.Pop(&r.heap)
+		}
+	}
+	if len(pieces) == 0 {
+		return nil, nil
+	}
+	return rebatch.Concat(r.schema, pieces, r.opts.Allocator)
+}
+
+// run 返回 s 从当前行开始、不大于其余分区最小键的连续行的结束位置，最多 limit 行
+func (r *Reader) run(s *source, limit int) (int, error) {
+	n := int(s.record.NumRows())
+	end := s.row + 1
+	var next *source
+	if r.heap.Len() > 1 {
+		next = r.heap.sources[1]
+		if r.heap.Len() > 2 && r.heap.less(r.heap.sources[2], next) {
+			next = r.heap.sources[2]
+		}
+	}
+	for end < n && end-s.row < limit {
+		if r.compare(s.key, end-1, s.key, end) > 0 {
+			return 0, r.unsorted(s)
+		}
+		if next != nil {
+			c := r.compare(s.key, end, next.key, next.row)
+			if c > 0 || (c == 0 && s.index > next.index) {
+				break
+			}
+		}
+		end++
+	}
+	return end, nil
+}
+
+// Record 返回当前批次
+func (r *Reader) Record() arrow.Record {
+	return r.record
+}
+
+// Err 返回读取过程中的错误
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Chunks 返回已读完的分区中接收的数据段数量
+func (r *Reader) Chunks() int {
+	return r.chunks
+}
+
+// Bytes 返回已读完的分区中接收的数据字节数
+func (r *Reader) Bytes() int64 {
+	return r.bytes
+}
+
+func (r *Reader) addStats(it *iterator.RecordIterator) {
+	r.chunks += it.Chunks()
+	r.bytes += it.Bytes()
+}
+
+func (r *Reader) fail(err error) {
+	r.err = err
+	r.Release()
+}
+
+// Release 释放所有分区的读取器，可以在读完之前调用以提前终止
+func (r *Reader) Release() {
+	r.done = true
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	if r.current != nil {
+		r.current.Release()
+		r.current = nil
+	}
+	for _, s := range r.sources {
+		if s.record != nil {
+			s.record.Release()
+			s.record = nil
+		}
+		if s.last != nil {
+			s.last.Release()
+			s.last = nil
+		}
+		s.it.Release()
+	}
+	r.sources = nil
+	r.heap.sources = nil
+}
+
+// mergeHeap 以各分区当前行的键排序的最小堆
+type mergeHeap struct {
+	sources []*source
+	less    func(a, b *source) bool
+}
+
+func (h mergeHeap) Len() int           { return len(h.sources) }
+func (h mergeHeap) Less(i, j int) bool { return h.less(h.sources[i], h.sources[j]) }
+func (h mergeHeap) Swap(i, j int)      { h.sources[i], h.sources[j] = h.sources[j], h.sources[i] }
+
+func (h *mergeHeap) Push(x interface{}) {
+	h.sources = append(h.sources, x.(*source))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	s := h.sources[len(h.sources)-1]
+	h.sources = h.sources[:len(h.sources)-1]
+	return s
+}
@@ -0,0 +1,194 @@
+package partition
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"test/iterator"
+	"test/iterator/iteratortest"
+	"test/oss"
+)
+
+var keyed = arrow.NewSchema([]arrow.Field{
+	{Name: "key", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	{Name: "part", Type: arrow.BinaryTypes.String},
+}, nil)
+
+// batch 生成一批 JSON 记录，part 列为分区标签，nil 表示 key 为 null
+func batch(part string, keys ...interface{}) string {
+	rows := make([]string, len(keys))
+	for i, key := range keys {
+		if key == nil {
+			key = "null"
+		}
+		rows[i] = fmt.Sprintf(`{"key": %v, "part": %q}`, key, part)
+	}
+	return "[" + strings.Join(rows, ",") + "]"
+}
+
+// readPartitions 以内存中的分区对象（每个元素为一个分区的各批记录）运行 Reader，
+// 返回 "key/part" 形式的行、每批的行数和错误
+func readPartitions(t *testing.T, partitions [][]string, opts Options) ([]string, []int, error) {
+	t.Helper()
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+
+	objects := make([]string, len(partitions))
+	for i := range partitions {
+		objects[i] = ObjectName("data/job", fmt.Sprint(i))
+	}
+	open := func(ctx context.Context, bucket, object string) (iterator.Source, error) {
+		for i, name := range objects {
+			if name == object {
+				return iteratortest.NewSource(t, keyed, partitions[i]...).Next, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: %w", object, oss.ErrNotExist)
+	}
+
+	opts.Allocator = mem
+	opts.Verify = oss.ReadOptions{SkipVerify: true}
+	r, err := Open(context.Background(), open, "bucket", objects, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Release()
+	var rows []string
+	var sizes []int
+	for r.Next() {
+		record := r.Record()
+		sizes = append(sizes, int(record.NumRows()))
+		for i := 0; i < int(record.NumRows()); i++ {
+			rows = append(rows, record.Column(0).ValueStr(i)+"/"+record.Column(1).ValueStr(i))
+		}
+	}
+	return rows, sizes, r.Err()
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name       string
+		partitions [][]string
+		descending bool
+		want       []string
+	}{
+		{
+			"ties keep partition order",
+			[][]string{
+				{batch("a", 1, 2), batch("a", 2, 4)},
+				{batch("b", 2, 3)},
+				{batch("c", 1, 2)},
+			},
+			false,
+			[]string{"1/a", "1/c", "2/a", "2/a", "2/b", "2/c", "3/b", "4/a"},
+		},
+		{
+			"nulls first ascending",
+			[][]string{
+				{batch("a", nil, 1, 3)},
+				{batch("b", nil, nil, 2)},
+			},
+			false,
+			[]string{"(null)/a", "(null)/b", "(null)/b", "1/a", "2/b", "3/a"},
+		},
+		{
+			"descending with nulls last",
+			[][]string{
+				{batch("a", 3, 1, nil)},
+				{batch("b", 4, 1), batch("b", nil)},
+			},
+			true,
+			[]string{"4/b", "3/a", "1/a", "1/b", "(null)/a", "(null)/b"},
+		},
+		{
+			"empty partitions and batches",
+			[][]string{
+				{},
+				{batch("b"), batch("b", 1, 2)},
+				{batch("c", 0)},
+			},
+			false,
+			[]string{"0/c", "1/b", "2/b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, _, err := readPartitions(t, tt.partitions, Options{OrderBy: "key", Descending: tt.descending})
+			if err != nil {
+				t.Fatalf("merge: %v", err)
+			}
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("rows = %v, want %v", rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeBatchRows(t *testing.T) {
+	// 分区 a 的 1..5 是一段连续的行，在 BatchRows 处被切开
+	partitions := [][]string{
+		{batch("a", 1, 2, 3, 4, 5), batch("a", 8)},
+		{batch("b", 6, 7)},
+	}
+	rows, sizes, err := readPartitions(t, partitions, Options{OrderBy: "key", BatchRows: 2})
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	want := []string{"1/a", "2/a", "3/a", "4/a", "5/a", "6/b", "7/b", "8/a"}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+	if !reflect.DeepEqual(sizes, []int{2, 2, 2, 2}) {
+		t.Errorf("batch sizes = %v, want [2 2 2 2]", sizes)
+	}
+}
+
+func TestMergeUnsorted(t *testing.T) {
+	tests := []struct {
+		name       string
+		partitions [][]string
+		descending bool
+	}{
+		{"within a batch", [][]string{{batch("a", 1, 3, 2)}, {batch("b", 5)}}, false},
+		{"across batches", [][]string{{batch("a", 1, 5), batch("a", 3)}, {batch("b", 9)}}, false},
+		{"null after values", [][]string{{batch("a", 1, nil)}}, false},
+		{"ascending read as descending", [][]string{{batch("a", 1, 2)}, {batch("b", 0)}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readPartitions(t, tt.partitions, Options{OrderBy: "key", Descending: tt.descending})
+			want := fmt.Sprintf("partition %s is not sorted by \"key\"", ObjectName("data/job", "0"))
+			if err == nil || err.Error() != want {
+				t.Fatalf("err = %v, want %q", err, want)
+			}
+		})
+	}
+}
+
+func TestMergeMissingColumn(t *testing.T) {
+	_, _, err := readPartitions(t, [][]string{{batch("a", 1)}}, Options{OrderBy: "score"})
+	if err == nil || !strings.Contains(err.Error(), `order by column "score" not found`) {
+		t.Fatalf("err = %v, want a missing column error", err)
+	}
+}
+
+func TestSequential(t *testing.T) {
+	partitions := [][]string{
+		{batch("a", 3, 1)},
+		{},
+		{batch("c", 2), batch("c", nil)},
+	}
+	rows, sizes, err := readPartitions(t, partitions, Options{})
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	want := []string{"3/a", "1/a", "2/c", "(null)/c"}
+	if !reflect.DeepEqual(rows, want) || !reflect.DeepEqual(sizes, []int{2, 1, 1}) {
+		t.Errorf("rows = %v %v, want %v [2 1 1]", rows, sizes, want)
+	}
+}
@@ -0,0 +1,384 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/20
+	@note: 接收、解码校验与写入并行执行的流式拷贝
+
+*
+*/
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"golang.org/x/sync/errgroup"
+	"io"
+	"runtime"
+	"sync"
+	"test/iterator"
+	"test/rebatch"
+	"test/utils"
+	"time"
+)
+
+// DefaultBuffer 各阶段之间通道的默认容量
+const DefaultBuffer = 8
+
+// OpenFunc 打开数据流，取消 ctx 时数据流应当结束，Copy 出错时依靠它中断阻塞的接收
+type OpenFunc func(ctx context.Context) (iterator.Source, error)
+
+// WriteFunc 按顺序写入一段完整的 IPC stream
+type WriteFunc func(chunk []byte) error
+
+// Options 拷贝参数
+type Options struct {
+	Workers int // 解码校验的并发数，默认 GOMAXPROCS
+	Buffer  int // 各阶段之间通道的容量，默认 DefaultBuffer
+	// Rebatch 非空时按目标大小重新组织批次后编码写入；为空时原样写入校验通过的数据段
+	Rebatch *rebatch.CopyOptions
+	// SchemaGuard 非空时按接收顺序检查每个数据段的 schema；DriftCast 需要解码后重新编码，必须同时指定 Rebatch
+	SchemaGuard *iterator.SchemaGuard
+	Allocator   memory.Allocator
+	// OnProgress 每隔 ProgressInterval 回调一次当前统计，默认每 5 秒
+	OnProgress       func(Stats)
+	ProgressInterval time.Duration
+}
+
+// Copy 以流水线方式拷贝数据：一个协程接收数据段，Workers 个协程并行解码校验，
+// 再按接收顺序（可选地重新分批后）交给写入协程，接收与写入可以同时进行。
+// 同时在途的数据段不超过 Buffer+Workers 个，任一阶段出错时取消其余阶段并返回第一个错误。
+func Copy(ctx context.Context, open OpenFunc, write WriteFunc, opts Options) (*Stats, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultBuffer
+	}
+	if opts.Allocator == nil {
+		opts.Allocator = memory.NewGoAllocator()
+	}
+	if opts.ProgressInterval <= 0 {
+		opts.ProgressInterval = 5 * time.Second
+	}
+
+	c := &copier{
+		opts:    opts,
+		start:   time.Now(),
+		chunks:  make(chan *chunk, opts.Buffer),
+		decoded: make(chan *chunk, opts.Buffer),
+		encoded: make(chan []byte, opts.Buffer),
+		tokens:  make(chan struct{}, opts.Buffer+opts.Workers),
+	}
+	if opts.SchemaGuard != nil && opts.SchemaGuard.Policy() == iterator.DriftCast && opts.Rebatch == nil {
+		return c.stats.snapshot(c.start), errors.New("casting drifted schemas re-encodes batches and requires Rebatch")
+	}
+	g, ctx := errgroup.WithContext(ctx)
+	src, err := open(ctx)
+	if err != nil {
+		return c.stats.snapshot(c.start), err
+	}
+
+	g.Go(func() error { return c.receive(ctx, src) })
+	var workers sync.WaitGroup
+	workers.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		g.Go(func() error {
+			defer workers.Done()
+			return c.decode(ctx)
+		})
+	}
+	go func() {
+		workers.Wait()
+		close(c.decoded)
+	}()
+	g.Go(func() error {
+		defer close(c.encoded)
+		return c.assemble(ctx)
+	})
+	g.Go(func() error { return c.send(ctx, write) })
+
+	done := make(chan struct{})
+	if opts.OnProgress != nil {
+		go func() {
+			ticker := time.NewTicker(opts.ProgressInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					opts.OnProgress(*c.stats.snapshot(c.start))
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	err = g.Wait()
+	close(done)
+	c.drain()
+	stats := c.stats.snapshot(c.start)
+	stats.Compression = c.compression
+	return stats, err
+}
+
+// chunk 一个接收到的数据段
+type chunk struct {
+	seq     int
+	data    []byte
+	schema  *arrow.Schema
+	records []arrow.Record // 仅在重新分批时保留解码结果
+	rows    int64
+}
+
+func (c *chunk) release() {
+	for _, record := range c.records {
+		record.Release()
+	}
+	c.records = nil
+}
+
+type copier struct {
+	opts  Options
+	start time.Time
+	stats counters
+
+	chunks  chan *chunk   // 接收 -> 解码
+	decoded chan *chunk   // 解码 -> 组装，顺序可能被打乱
+	encoded chan []byte   // 组装 -> 写入
+	tokens  chan struct{} // 限制在途数据段的数量
+
+	pending     map[int]*chunk // 组装阶段等待前序数据段的解码结果
+	compression utils.CompressionStats
+}
+
+// receive 依次接收数据段，数据流以 io.EOF 或 iterator.EOFMarker 结束
+func (c *copier) receive(ctx context.Context, src iterator.Source) error {
+	defer close(c.chunks)
+	for seq := 0; ; {
+		select {
+		case c.tokens <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		start := time.Now()
+		data, err := src()
+		c.stats.receiveTime.Add(int64(time.Since(start)))
+		if err == io.EOF || (err == nil && string(data) == iterator.EOFMarker) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error receiving data: %v", err)
+		}
+		if len(data) == 0 {
+			<-c.tokens
+			continue
+		}
+		c.stats.chunks.Add(1)
+		c.stats.receivedBytes.Add(int64(len(data)))
+		select {
+		case c.chunks <- &chunk{seq: seq, data: data}:
+			seq++
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// decode 解码并校验数据段中的全部记录
+func (c *copier) decode(ctx context.Context) error {
+	for ch := range c.chunks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		start := time.Now()
+		err := c.decodeChunk(ch)
+		c.stats.decodeTime.Add(int64(time.Since(start)))
+		if err != nil {
+			ch.release()
+			return fmt.Errorf("invalid Arrow data in chunk %d: %v", ch.seq, err)
+		}
+		select {
+		case c.decoded <- ch:
+		case <-ctx.Done():
+			ch.release()
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (c *copier) decodeChunk(ch *chunk) error {
+	reader, err := ipc.NewReader(bytes.NewReader(ch.data), ipc.WithAllocator(c.opts.Allocator))
+	if err != nil {
+		return err
+	}
+	defer reader.Release()
+	ch.schema = reader.Schema()
+	for reader.Next() {
+		record := reader.Record()
+		ch.rows += record.NumRows()
+		if c.opts.Rebatch != nil {
+			record.Retain()
+			ch.records = append(ch.records, record)
+		}
+	}
+	return reader.Err()
+}
+
+// assemble 按接收顺序取出解码结果，原样或重新分批编码后交给写入协程
+func (c *copier) assemble(ctx context.Context) error {
+	c.pending = make(map[int]*chunk)
+	ordered := &orderedRecords{c: c, ctx: ctx}
+	if c.opts.Rebatch == nil {
+		for {
+			ch, err := ordered.nextChunk()
+			if ch == nil || err != nil {
+				return err
+			}
+			if guard := c.opts.SchemaGuard; guard != nil {
+				if err := guard.CheckSchema(ch.schema); err != nil {
+					return fmt.Errorf("chunk %d: %w", ch.seq, err)
+				}
+			}
+			c.stats.rows.Add(ch.rows)
+			if err := c.emit(ctx, ch.data); err != nil {
+				return err
+			}
+		}
+	}
+	opts := *c.opts.Rebatch
+	if opts.Allocator == nil {
+		opts.Allocator = c.opts.Allocator
+	}
+	result, err := rebatch.Copy(ctx, ordered, func(data []byte) error {
+		return c.emit(ctx, data)
+	}, opts)
+	c.compression = result.Compression
+	return err
+}
+
+// checkRecords 按接收顺序检查数据段中记录的 schema，需要转换时替换为转换后的记录
+func (c *copier) checkRecords(ch *chunk) error {
+	for i, record := range ch.records {
+		checked, err := c.opts.SchemaGuard.Check(record)
+		if err != nil {
+			return fmt.Errorf("chunk %d: %w", ch.seq, err)
+		}
+		record.Release()
+		ch.records[i] = checked
+	}
+	return nil
+}
+
+func (c *copier) emit(ctx context.Context, data []byte) error {
+	select {
+	case c.encoded <- data:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// send 按顺序写入
+func (c *copier) send(ctx context.Context, write WriteFunc) error {
+	for data := range c.encoded {
+		start := time.Now()
+		err := write(data)
+		c.stats.writeTime.Add(int64(time.Since(start)))
+		if err != nil {
+			return fmt.Errorf("failed to write batch %d: %w", c.stats.batches.Load()+1, err)
+		}
+		c.stats.batches.Add(1)
+		c.stats.writtenBytes.Add(int64(len(data)))
+	}
+	return ctx.Err()
+}
+
+// drain 出错退出后释放仍在通道和等待队列中的记录
+func (c *copier) drain() {
+	for ch := range c.decoded {
+		ch.release()
+	}
+	for _, ch := range c.pending {
+		ch.release()
+	}
+}
+
+// orderedRecords 将乱序到达的解码结果恢复为接收顺序，满足 iterator.Records 供 rebatch 使用
+type orderedRecords struct {
+	c      *copier
+	ctx    context.Context
+	next   int
+	chunk  *chunk
+	index  int
+	record arrow.Record
+	err    error
+}
+
+// nextChunk 返回下一个序号的数据段，全部结束时返回 nil；调用方取走数据段后释放一个在途名额
+func (o *orderedRecords) nextChunk() (*chunk, error) {
+	for {
+		if ch, ok := o.c.pending[o.next]; ok {
+			delete(o.c.pending, o.next)
+			o.next++
+			<-o.c.tokens
+			return ch, nil
+		}
+		select {
+		case ch, ok := <-o.c.decoded:
+			if !ok {
+				if len(o.c.pending) > 0 {
+					return nil, errors.New("decoding stopped before all chunks arrived")
+				}
+				return nil, nil
+			}
+			o.c.pending[ch.seq] = ch
+		case <-o.ctx.Done():
+			return nil, o.ctx.Err()
+		}
+	}
+}
+
+func (o *orderedRecords) Next() bool {
+	o.record = nil
+	for o.err == nil {
+		if o.chunk != nil && o.index < len(o.chunk.records) {
+			o.record = o.chunk.records[o.index]
+			o.index++
+			return true
+		}
+		if o.chunk != nil {
+			o.c.stats.rows.Add(o.chunk.rows)
+			o.chunk.release()
+			o.chunk = nil
+		}
+		ch, err := o.nextChunk()
+		if err != nil {
+			o.err = err
+		}
+		if ch == nil {
+			break
+		}
+		o.chunk, o.index = ch, 0
+		if o.c.opts.SchemaGuard != nil {
+			o.err = o.c.checkRecords(ch)
+		}
+	}
+	return false
+}
+
+func (o *orderedRecords) Record() arrow.Record { return o.record }
+func (o *orderedRecords) Err() error           { return o.err }
+
+func (o *orderedRecords) Release() {
+	o.record = nil
+	if o.chunk != nil {
+		o.chunk.release()
+		o.chunk = nil
+	}
+}
@@ -0,0 +1,222 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"test/iterator"
+	"test/iterator/iteratortest"
+	"test/rebatch"
+)
+
+var numbers = arrow.NewSchema([]arrow.Field{{Name: "n", Type: arrow.PrimitiveTypes.Int64}}, nil)
+
+// rows 生成 [from, from+count) 的 JSON 批次
+func rows(from, count int) string {
+	values := make([]string, count)
+	for i := range values {
+		values[i] = fmt.Sprintf(`{"n": %d}`, from+i)
+	}
+	return "[" + strings.Join(values, ",") + "]"
+}
+
+// openChunks 返回按顺序输出 chunks 的 OpenFunc
+func openChunks(chunks [][]byte) OpenFunc {
+	return func(ctx context.Context) (iterator.Source, error) {
+		src := &iteratortest.Source{Chunks: chunks}
+		return src.Next, nil
+	}
+}
+
+// gatedAllocator 分配不小于 threshold 字节的内存时阻塞，直到 gate 关闭
+type gatedAllocator struct {
+	memory.Allocator
+	threshold int
+	gate      chan struct{}
+	small     atomic.Int32 // 阻塞期间其他数据段的分配次数
+}
+
+func (a *gatedAllocator) Allocate(size int) []byte {
+	if size >= a.threshold {
+		<-a.gate
+	} else if size > 0 {
+		a.small.Add(1)
+	}
+	return a.Allocator.Allocate(size)
+}
+
+func TestCopyKeepsOrder(t *testing.T) {
+	// 第一个数据段很大，解码时被阻塞，后面的小数据段先解码完成
+	batches := []string{rows(0, 1000)}
+	for i := 1; i <= 6; i++ {
+		batches = append(batches, rows(1000+i, 1))
+	}
+	chunks := iteratortest.NewSource(t, numbers, batches...).Chunks
+
+	mem := &gatedAllocator{Allocator: memory.NewGoAllocator(), threshold: 4096, gate: make(chan struct{})}
+	outOfOrder := make(chan bool, 1)
+	go func() {
+		deadline := time.Now().Add(5 * time.Second)
+		for mem.small.Load() < int32(len(batches)-1) && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		outOfOrder <- mem.small.Load() >= int32(len(batches)-1)
+		close(mem.gate)
+	}()
+
+	var written [][]byte
+	stats, err := Copy(context.Background(), openChunks(chunks), func(chunk []byte) error {
+		written = append(written, chunk)
+		return nil
+	}, Options{Workers: 4, Allocator: mem})
+	if err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if !<-outOfOrder {
+		t.Fatal("later chunks were not decoded while the first one was blocked")
+	}
+	if len(written) != len(chunks) {
+		t.Fatalf("wrote %d chunks, want %d", len(written), len(chunks))
+	}
+	for i := range chunks {
+		if !bytes.Equal(written[i], chunks[i]) {
+			t.Errorf("chunk %d written out of order", i)
+		}
+	}
+	if stats.Rows != 1006 || stats.Chunks != 7 || stats.Batches != 7 {
+		t.Errorf("stats = %d rows, %d chunks, %d batches", stats.Rows, stats.Chunks, stats.Batches)
+	}
+}
+
+func TestCopyCancelsOnWriteError(t *testing.T) {
+	chunk := iteratortest.NewSource(t, numbers, rows(0, 10)).Chunks[0]
+	var srcCtx context.Context
+	var received atomic.Int64
+	open := func(ctx context.Context) (iterator.Source, error) {
+		srcCtx = ctx
+		// 数据流不会自行结束，只能依靠取消 ctx 停止接收
+		return func() ([]byte, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			received.Add(1)
+			return chunk, nil
+		}, nil
+	}
+	errWrite := errors.New("disk full")
+	writes := 0
+	done := make(chan struct{})
+	var err error
+	go func() {
+		defer close(done)
+		_, err = Copy(context.Background(), open, func([]byte) error {
+			writes++
+			if writes == 2 {
+				return errWrite
+			}
+			return nil
+		}, Options{Workers: 2, Buffer: 2})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Copy did not stop after the write failed")
+	}
+
+	if !errors.Is(err, errWrite) || !strings.Contains(err.Error(), "failed to write batch 2") {
+		t.Errorf("err = %v, want the write error of batch 2", err)
+	}
+	if srcCtx.Err() == nil {
+		t.Error("source context was not canceled")
+	}
+	// 在途数据段受 Buffer 和 Workers 限制，接收不会在写入失败后继续
+	if n := received.Load(); n > 16 {
+		t.Errorf("received %d chunks after %d writes", n, writes)
+	}
+}
+
+func TestCopyDrainReleasesRecords(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+
+	var batches []string
+	for i := 0; i < 40; i++ {
+		batches = append(batches, rows(i*10, 10))
+	}
+	chunks := iteratortest.NewSource(t, numbers, batches...).Chunks
+	errWrite := errors.New("connection reset")
+	_, err := Copy(context.Background(), openChunks(chunks), func([]byte) error {
+		return errWrite
+	}, Options{
+		Workers:   4,
+		Buffer:    4,
+		Rebatch:   &rebatch.CopyOptions{Options: rebatch.Options{TargetRows: 15}},
+		Allocator: mem,
+	})
+	if !errors.Is(err, errWrite) {
+		t.Fatalf("err = %v, want the write error", err)
+	}
+}
+
+func TestCopyDriftCast(t *testing.T) {
+	narrow := arrow.NewSchema([]arrow.Field{{Name: "n", Type: arrow.PrimitiveTypes.Int32}}, nil)
+	chunks := append(iteratortest.NewSource(t, numbers, rows(0, 2)).Chunks,
+		iteratortest.NewSource(t, narrow, rows(2, 3)).Chunks...)
+
+	t.Run("requires rebatch", func(t *testing.T) {
+		opened := false
+		open := func(ctx context.Context) (iterator.Source, error) {
+			opened = true
+			return openChunks(chunks)(ctx)
+		}
+		_, err := Copy(context.Background(), open, func([]byte) error { return nil }, Options{
+			SchemaGuard: iterator.NewSchemaGuard(iterator.DriftCast, nil),
+		})
+		if err == nil || !strings.Contains(err.Error(), "requires Rebatch") {
+			t.Fatalf("err = %v, want a Rebatch error", err)
+		}
+		if opened {
+			t.Error("the source was opened")
+		}
+	})
+
+	t.Run("with rebatch", func(t *testing.T) {
+		guard := iterator.NewSchemaGuard(iterator.DriftCast, nil)
+		var got []int64
+		_, err := Copy(context.Background(), openChunks(chunks), func(chunk []byte) error {
+			reader, err := ipc.NewReader(bytes.NewReader(chunk))
+			if err != nil {
+				return err
+			}
+			defer reader.Release()
+			if !reader.Schema().Equal(numbers) {
+				return fmt.Errorf("wrote schema %v", reader.Schema())
+			}
+			for reader.Next() {
+				column := reader.Record().Column(0)
+				for i := 0; i < column.Len(); i++ {
+					got = append(got, column.(interface{ Value(int) int64 }).Value(i))
+				}
+			}
+			return reader.Err()
+		}, Options{Workers: 2, Rebatch: &rebatch.CopyOptions{}, SchemaGuard: guard})
+		if err != nil {
+			t.Fatalf("Copy: %v", err)
+		}
+		if fmt.Sprint(got) != "[0 1 2 3 4]" {
+			t.Errorf("rows = %v, want [0 1 2 3 4]", got)
+		}
+		if guard.Drifts() != 1 || !guard.Events()[0].Cast {
+			t.Errorf("drift events = %v, want one cast", guard.Events())
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"fmt"
+	"github.com/dustin/go-humanize"
+	"sync/atomic"
+	"test/utils"
+	"time"
+)
+
+// Stats 拷贝统计，各阶段耗时为所有协程的累计值，用于判断瓶颈所在
+type Stats struct {
+	Chunks        int64 // 接收的非空数据段
+	ReceivedBytes int64
+	Rows          int64
+	Batches       int64 // 写入的批次
+	WrittenBytes  int64
+	Elapsed       time.Duration
+	ReceiveTime   time.Duration // 等待数据流返回数据的时间
+	DecodeTime    time.Duration // 解码校验的累计时间
+	WriteTime     time.Duration // 等待写入返回的时间
+	// Compression 重新分批并压缩时的压缩统计，只在拷贝结束后填写
+	Compression utils.CompressionStats
+}
+
+// ReceiveRate 每秒接收的字节数
+func (s Stats) ReceiveRate() float64 {
+	return rate(float64(s.ReceivedBytes), s.Elapsed)
+}
+
+// WriteRate 每秒写入的字节数
+func (s Stats) WriteRate() float64 {
+	return rate(float64(s.WrittenBytes), s.Elapsed)
+}
+
+// RowRate 每秒处理的行数
+func (s Stats) RowRate() float64 {
+	return rate(float64(s.Rows), s.Elapsed)
+}
+
+func rate(n float64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return n / elapsed.Seconds()
+}
+
+func (s Stats) String() string {
+	str := fmt.Sprintf("%d rows in %v (%.0f rows/s); received %s in %d chunks (%s/s, %v waiting); "+
+		"decoded in %v; wrote %s in %d batches (%s/s, %v waiting)",
+		s.Rows, s.Elapsed.Round(time.Millisecond), s.RowRate(),
+		humanize.IBytes(uint64(s.ReceivedBytes)), s.Chunks, humanize.IBytes(uint64(s.ReceiveRate())), s.ReceiveTime.Round(time.Millisecond),
+		s.DecodeTime.Round(time.Millisecond),
+		humanize.IBytes(uint64(s.WrittenBytes)), s.Batches, humanize.IBytes(uint64(s.WriteRate())), s.WriteTime.Round(time.Millisecond))
+	if s.Compression.Batches > 0 {
+		str += "; compressed " + s.Compression.String()
+	}
+	return str
+}
+
+// counters 各阶段并发更新的计数
+type counters struct {
+	chunks        atomic.Int64
+	receivedBytes atomic.Int64
+	rows          atomic.Int64
+	batches       atomic.Int64
+	writtenBytes  atomic.Int64
+	receiveTime   atomic.Int64
+	decodeTime    atomic.Int64
+	writeTime     atomic.Int64
+}
+
+func (c *counters) snapshot(start time.Time) *Stats {
+	return &Stats{
+		Chunks:        c.chunks.Load(),
+		ReceivedBytes: c.receivedBytes.Load(),
+		Rows:          c.rows.Load(),
+		Batches:       c.batches.Load(),
+		WrittenBytes:  c.writtenBytes.Load(),
+		Elapsed:       time.Since(start),
+		ReceiveTime:   time.Duration(c.receiveTime.Load()),
+		DecodeTime:    time.Duration(c.decodeTime.Load()),
+		WriteTime:     time.Duration(c.writeTime.Load()),
+	}
+}
@@ -0,0 +1,650 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/compute"
+	"github.com/apache/arrow/go/v15/arrow/decimal128"
+	"github.com/apache/arrow/go/v15/arrow/decimal256"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/apache/arrow/go/v15/arrow/scalar"
+	"regexp"
+	"strings"
+	"test/iterator"
+	"time"
+)
+
+// Evaluator 在客户端对记录计算过滤条件和投影，比较和算术使用 arrow compute 的函数，
+// NOT、IS NULL、LIKE 等 compute 中没有的运算逐行计算；非并发安全
+//
+// 过滤条件的结果为 NULL 的行与 SQL 一致视为不满足条件。
+type Evaluator struct {
+	where   Expr
+	project []SelectItem
+	mem     memory.Allocator
+	likes   map[*Like]*regexp.Regexp
+}
+
+// NewEvaluator 创建计算器，where 为 nil 时不过滤，project 为空时保留全部列，mem 为空时使用 GoAllocator
+func NewEvaluator(where Expr, project []SelectItem, mem memory.Allocator) *Evaluator {
+	if mem == nil {
+		mem = memory.NewGoAllocator()
+	}
+	return &Evaluator{where: where, project: project, mem: mem, likes: make(map[*Like]*regexp.Regexp)}
+}
+
+// Apply 过滤并投影一批记录，返回由调用方释放的新记录
+func (e *Evaluator) Apply(record arrow.Record) (arrow.Record, error) {
+	ctx := compute.WithAllocator(context.Background(), e.mem)
+	record.Retain()
+	defer func() { record.Release() }()
+	if e.where != nil {
+		mask, err := e.predicate(ctx, record)
+		if err != nil {
+			return nil, err
+		}
+		filtered, err := compute.FilterRecordBatch(ctx, record, mask, compute.DefaultFilterOptions())
+		mask.Release()
+		if err != nil {
+			return nil, fmt.Errorf("WHERE %v: %v", e.where, err)
+		}
+		record.Release()
+		record = filtered
+	}
+	if len(e.project) == 0 {
+		record.Retain()
+		return record, nil
+	}
+	return e.projectRecord(ctx, record)
+}
+
+// predicate 计算过滤条件，返回布尔数组
+func (e *Evaluator) predicate(ctx context.Context, record arrow.Record) (arrow.Array, error) {
+	result, err := e.eval(ctx, e.where, record)
+	if err != nil {
+		return nil, fmt.Errorf("WHERE %v: %v", e.where, err)
+	}
+	defer result.Release()
+	if t := datumType(result); t.ID() != arrow.BOOL && t.ID() != arrow.NULL {
+		return nil, fmt.Errorf("WHERE %v: expected a boolean condition, got %s", e.where, t)
+	}
+	mask, err := toArray(result, record.NumRows(), e.mem)
+	if err != nil {
+		return nil, err
+	}
+	if mask.DataType().ID() == arrow.NULL {
+		// WHERE NULL 不匹配任何行
+		mask.Release()
+		return e.nullBooleans(record.NumRows()), nil
+	}
+	return mask, nil
+}
+
+// projectRecord 计算 SELECT 中的每一项，列名为别名、列名或表达式本身
+func (e *Evaluator) projectRecord(ctx context.Context, record arrow.Record) (arrow.Record, error) {
+	rows := record.NumRows()
+	fields := make([]arrow.Field, len(e.project))
+	columns := make([]arrow.Array, 0, len(e.project))
+	defer func() {
+		for _, column := range columns {
+			column.Release()
+		}
+	}()
+	for i, item := range e.project {
+		result, err := e.eval(ctx, item.Expr, record)
+		if err != nil {
+			return nil, fmt.Errorf("SELECT %v: %v", item.Expr, err)
+		}
+		column, err := toArray(result, rows, e.mem)
+		result.Release()
+		if err != nil {
+			return nil, fmt.Errorf("SELECT %v: %v", item.Expr, err)
+		}
+		columns = append(columns, column)
+		fields[i] = arrow.Field{Name: item.Name(), Type: column.DataType(), Nullable: true}
+		if c, ok := item.Expr.(*Column); ok {
+			if f, ok := field(record.Schema(), c.Name); ok {
+				fields[i].Nullable, fields[i].Metadata = f.Nullable, f.Metadata
+			}
+		}
+	}
+	return array.NewRecord(arrow.NewSchema(fields, nil), columns, rows), nil
+}
+
+// eval 计算表达式，返回由调用方释放的 Datum
+func (e *Evaluator) eval(ctx context.Context, expr Expr, record arrow.Record) (compute.Datum, error) {
+	switch x := expr.(type) {
+	case *Column:
+		column, err := lookup(record, x.Name)
+		if err != nil {
+			return nil, err
+		}
+		return compute.NewDatum(column), nil
+	case *Literal:
+		return literalDatum(x), nil
+	case *Unary:
+		arg, err := e.eval(ctx, x.X, record)
+		if err != nil {
+			return nil, err
+		}
+		defer arg.Release()
+		if x.Op == "NOT" {
+			return e.not(arg, record.NumRows())
+		}
+		return compute.Negate(ctx, compute.ArithmeticOptions{}, arg)
+	case *Binary:
+		switch x.Op {
+		case "AND", "OR":
+			return e.logical(ctx, x.Op, x.Left, x.Right, record)
+		case "+", "-", "*", "/":
+			return e.arithmetic(ctx, x, record)
+		}
+		return e.compare(ctx, x.Op, x.Left, x.Right, record)
+	case *In:
+		return e.in(ctx, x, record)
+	case *Between:
+		lo := &Binary{Op: ">=", Left: x.X, Right: x.Lo}
+		hi := &Binary{Op: "<=", Left: x.X, Right: x.Hi}
+		result, err := e.logical(ctx, "AND", lo, hi, record)
+		if err != nil || !x.Not {
+			return result, err
+		}
+		defer result.Release()
+		return e.not(result, record.NumRows())
+	case *Like:
+		return e.like(ctx, x, record)
+	case *IsNull:
+		return e.isNull(ctx, x, record)
+	case *Call:
+		return nil, fmt.Errorf("function %s is not supported", x.Name)
+	}
+	return nil, fmt.Errorf("unsupported expression %v", expr)
+}
+
+// logical AND/OR 采用三值逻辑：FALSE AND NULL 为 FALSE，TRUE OR NULL 为 TRUE
+func (e *Evaluator) logical(ctx context.Context, op string, left, right Expr, record arrow.Record) (compute.Datum, error) {
+	l, err := e.evalBoolean(ctx, left, record)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+	r, err := e.evalBoolean(ctx, right, record)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Release()
+	name := "and_kleene"
+	if op == "OR" {
+		name = "or_kleene"
+	}
+	return compute.CallFunction(ctx, name, nil, l, r)
+}
+
+// evalBoolean 计算布尔表达式，NULL 字面量转换为布尔类型的空值
+func (e *Evaluator) evalBoolean(ctx context.Context, expr Expr, record arrow.Record) (compute.Datum, error) {
+	d, err := e.eval(ctx, expr, record)
+	if err != nil {
+		return nil, err
+	}
+	switch datumType(d).ID() {
+	case arrow.BOOL:
+		return d, nil
+	case arrow.NULL:
+		d.Release()
+		return compute.NewDatum(scalar.MakeNullScalar(arrow.FixedWidthTypes.Boolean)), nil
+	}
+	d.Release()
+	return nil, fmt.Errorf("%v is not a boolean expression", expr)
+}
+
+// comparisonFuncs 比较运算符对应的 compute 函数
+var comparisonFuncs = map[string]string{
+	"=": "equal", "!=": "not_equal", "<": "less", "<=": "less_equal", ">": "greater", ">=": "greater_equal",
+}
+
+func (e *Evaluator) compare(ctx context.Context, op string, left, right Expr, record arrow.Record) (compute.Datum, error) {
+	name, ok := comparisonFuncs[op]
+	if !ok {
+		return nil, fmt.Errorf("unsupported operator %s", op)
+	}
+	l, r, err := e.evalPair(ctx, left, right, record)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+	defer r.Release()
+	if datumType(l).ID() == arrow.NULL || datumType(r).ID() == arrow.NULL {
+		return compute.NewDatum(scalar.MakeNullScalar(arrow.FixedWidthTypes.Boolean)), nil
+	}
+	result, err := compute.CallFunction(ctx, name, nil, l, r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compare %s with %s: %v", datumType(l), datumType(r), err)
+	}
+	return result, nil
+}
+
+// arithmeticFuncs 算术运算符对应的 compute 函数，溢出和除零时报错
+var arithmeticFuncs = map[string]func(context.Context, compute.ArithmeticOptions, compute.Datum, compute.Datum) (compute.Datum, error){
+	"+": compute.Add, "-": compute.Subtract, "*": compute.Multiply, "/": compute.Divide,
+}
+
+func (e *Evaluator) arithmetic(ctx context.Context, x *Binary, record arrow.Record) (compute.Datum, error) {
+	l, r, err := e.evalPair(ctx, x.Left, x.Right, record)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+	defer r.Release()
+	return arithmeticFuncs[x.Op](ctx, compute.ArithmeticOptions{}, l, r)
+}
+
+// in 展开为 X = v1 OR X = v2 ...，X 为 NULL 或没有匹配但列表中有 NULL 时结果为 NULL
+func (e *Evaluator) in(ctx context.Context, x *In, record arrow.Record) (compute.Datum, error) {
+	var result compute.Datum
+	for _, v := range x.Values {
+		eq, err := e.compare(ctx, "=", x.X, v, record)
+		if err != nil {
+			if result != nil {
+				result.Release()
+			}
+			return nil, err
+		}
+		if result == nil {
+			result = eq
+			continue
+		}
+		merged, err := compute.CallFunction(ctx, "or_kleene", nil, result, eq)
+		result.Release()
+		eq.Release()
+		if err != nil {
+			return nil, err
+		}
+		result = merged
+	}
+	if !x.Not {
+		return result, nil
+	}
+	defer result.Release()
+	return e.not(result, record.NumRows())
+}
+
+// evalPair 计算二元运算的两边，一边是字面量时按另一边的类型构造
+func (e *Evaluator) evalPair(ctx context.Context, left, right Expr, record arrow.Record) (compute.Datum, compute.Datum, error) {
+	if literal, ok := left.(*Literal); ok {
+		if _, ok := right.(*Literal); !ok {
+			r, l, err := e.evalPair(ctx, right, literal, record)
+			return l, r, err
+		}
+	}
+	l, err := e.eval(ctx, left, record)
+	if err != nil {
+		return nil, nil, err
+	}
+	var r compute.Datum
+	if literal, ok := right.(*Literal); ok {
+		r, err = e.literal(ctx, literal, datumType(l))
+	} else {
+		r, err = e.eval(ctx, right, record)
+	}
+	if err != nil {
+		l.Release()
+		return nil, nil, err
+	}
+	return l, r, nil
+}
+
+// literal 按另一边的类型构造字面量：整数和浮点数之间由 compute 自动提升，与 decimal 比较时按字面量的
+// 十进制文本精确转换，字符串可以与日期、时间戳（不带时区时按 UTC）、decimal 和数字比较，数字不能与字符串比较
+func (e *Evaluator) literal(ctx context.Context, l *Literal, target arrow.DataType) (compute.Datum, error) {
+	switch v := l.Value.(type) {
+	case nil:
+		return compute.NewDatum(scalar.MakeNullScalar(target)), nil
+	case int64, float64:
+		switch target.ID() {
+		case arrow.DECIMAL128, arrow.DECIMAL256:
+			return decimalLiteral(l.String(), target)
+		case arrow.STRING, arrow.LARGE_STRING, arrow.BINARY, arrow.LARGE_BINARY:
+			return nil, fmt.Errorf("cannot compare %s with number %v", target, l)
+		}
+	case string:
+		switch target.ID() {
+		case arrow.DECIMAL128, arrow.DECIMAL256:
+			return decimalLiteral(strings.TrimSpace(v), target)
+		case arrow.DATE32, arrow.DATE64:
+			t, err := time.Parse("2006-01-02", strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("invalid date %v, expected YYYY-MM-DD", l)
+			}
+			if target.ID() == arrow.DATE32 {
+				return compute.NewDatum(scalar.NewDate32Scalar(arrow.Date32FromTime(t))), nil
+			}
+			return compute.NewDatum(scalar.NewDate64Scalar(arrow.Date64FromTime(t))), nil
+		case arrow.TIMESTAMP:
+			ts, err := arrow.TimestampFromString(strings.TrimSpace(v), target.(*arrow.TimestampType).Unit)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp %v: %v", l, err)
+			}
+			return compute.NewDatum(scalar.NewTimestampScalar(ts, target)), nil
+		}
+	}
+
+	d := literalDatum(l)
+	from := datumType(d)
+	if arrow.TypeEqual(from, target) || target.ID() == arrow.NULL || (numeric(from) && numeric(target)) || !compute.CanCast(from, target) {
+		// 类型无法转换时交给 compute 报错
+		return d, nil
+	}
+	defer d.Release()
+	arr, err := toArray(d, 1, e.mem)
+	if err != nil {
+		return nil, err
+	}
+	defer arr.Release()
+	cast, err := compute.CastArray(ctx, arr, compute.SafeCastOptions(target))
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %v to %s: %v", l, target, err)
+	}
+	defer cast.Release()
+	sc, err := scalar.GetScalar(cast, 0)
+	if err != nil {
+		return nil, err
+	}
+	return compute.NewDatumWithoutOwning(sc), nil
+}
+
+// decimalLiteral 按十进制文本构造 decimal 标量，scale 取列和字面量中较大的一个，避免舍入改变比较结果
+func decimalLiteral(text string, target arrow.DataType) (compute.Datum, error) {
+	scale := target.(arrow.DecimalType).GetScale()
+	if i := strings.IndexByte(text, '.'); i >= 0 && !strings.ContainsAny(text, "eE") {
+		scale = max(scale, int32(len(text)-i-1))
+	}
+	if target.ID() == arrow.DECIMAL256 {
+		typ := &arrow.Decimal256Type{Precision: 76, Scale: scale}
+		n, err := decimal256.FromString(text, typ.Precision, typ.Scale)
+		if err != nil {
+			return nil, fmt.Errorf("invalid decimal %s: %v", text, err)
+		}
+		return compute.NewDatum(scalar.NewDecimal256Scalar(n, typ)), nil
+	}
+	typ := &arrow.Decimal128Type{Precision: 38, Scale: scale}
+	n, err := decimal128.FromString(text, typ.Precision, typ.Scale)
+	if err != nil {
+		return nil, fmt.Errorf("invalid decimal %s: %v", text, err)
+	}
+	return compute.NewDatum(scalar.NewDecimal128Scalar(n, typ)), nil
+}
+
+// not 逐行取反，NULL 保持为 NULL
+func (e *Evaluator) not(d compute.Datum, rows int64) (compute.Datum, error) {
+	switch datumType(d).ID() {
+	case arrow.BOOL:
+	case arrow.NULL:
+		return compute.NewDatum(scalar.MakeNullScalar(arrow.FixedWidthTypes.Boolean)), nil
+	default:
+		return nil, fmt.Errorf("NOT requires a boolean operand, got %s", datumType(d))
+	}
+	arr, err := toArray(d, rows, e.mem)
+	if err != nil {
+		return nil, err
+	}
+	defer arr.Release()
+	values := arr.(*array.Boolean)
+	b := array.NewBooleanBuilder(e.mem)
+	defer b.Release()
+	b.Reserve(values.Len())
+	for i := 0; i < values.Len(); i++ {
+		if values.IsNull(i) {
+			b.AppendNull()
+		} else {
+			b.UnsafeAppend(!values.Value(i))
+		}
+	}
+	return newArrayDatum(b.NewArray()), nil
+}
+
+// isNull 根据有效位图计算，结果不含 NULL
+func (e *Evaluator) isNull(ctx context.Context, x *IsNull, record arrow.Record) (compute.Datum, error) {
+	d, err := e.eval(ctx, x.X, record)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Release()
+	arr, err := toArray(d, record.NumRows(), e.mem)
+	if err != nil {
+		return nil, err
+	}
+	defer arr.Release()
+	b := array.NewBooleanBuilder(e.mem)
+	defer b.Release()
+	b.Reserve(arr.Len())
+	for i := 0; i < arr.Len(); i++ {
+		b.UnsafeAppend(arr.IsNull(i) != x.Not)
+	}
+	return newArrayDatum(b.NewArray()), nil
+}
+
+// like 将模式转换为正则表达式后逐行匹配，% 匹配任意个字符，_ 匹配一个字符，\ 转义
+func (e *Evaluator) like(ctx context.Context, x *Like, record arrow.Record) (compute.Datum, error) {
+	re, err := e.likePattern(x)
+	if err != nil {
+		return nil, err
+	}
+	d, err := e.eval(ctx, x.X, record)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Release()
+	arr, err := toArray(d, record.NumRows(), e.mem)
+	if err != nil {
+		return nil, err
+	}
+	defer arr.Release()
+	var value func(int) string
+	switch values := arr.(type) {
+	case *array.String:
+		value = values.Value
+	case *array.LargeString:
+		value = values.Value
+	case *array.Null:
+		return compute.NewDatum(scalar.MakeNullScalar(arrow.FixedWidthTypes.Boolean)), nil
+	default:
+		return nil, fmt.Errorf("LIKE requires a string operand, got %s", arr.DataType())
+	}
+	b := array.NewBooleanBuilder(e.mem)
+	defer b.Release()
+	b.Reserve(arr.Len())
+	for i := 0; i < arr.Len(); i++ {
+		if arr.IsNull(i) {
+			b.AppendNull()
+		} else {
+			b.UnsafeAppend(re.MatchString(value(i)) != x.Not)
+		}
+	}
+	return newArrayDatum(b.NewArray()), nil
+}
+
+func (e *Evaluator) likePattern(x *Like) (*regexp.Regexp, error) {
+	if re, ok := e.likes[x]; ok {
+		return re, nil
+	}
+	literal, ok := x.Pattern.(*Literal)
+	if !ok {
+		return nil, fmt.Errorf("LIKE pattern must be a string literal")
+	}
+	pattern, ok := literal.Value.(string)
+	if !ok {
+		return nil, fmt.Errorf("LIKE pattern must be a string literal")
+	}
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+	escaped := false
+	for _, c := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '%':
+			b.WriteString(".*")
+		case c == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if escaped {
+		b.WriteString(`\\`)
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid LIKE pattern %q: %v", pattern, err)
+	}
+	e.likes[x] = re
+	return re, nil
+}
+
+func (e *Evaluator) nullBooleans(rows int64) arrow.Array {
+	return array.MakeArrayOfNull(e.mem, arrow.FixedWidthTypes.Boolean, int(rows))
+}
+
+// lookup 按名称查找列，限定名 t.col 找不到时按 col 查找
+func lookup(record arrow.Record, name string) (arrow.Array, error) {
+	schema := record.Schema()
+	indices := schema.FieldIndices(name)
+	if len(indices) == 0 {
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			indices = schema.FieldIndices(name[i+1:])
+		}
+	}
+	switch len(indices) {
+	case 0:
+		return nil, fmt.Errorf("unknown column %s", name)
+	case 1:
+		return record.Column(indices[0]), nil
+	}
+	return nil, fmt.Errorf("column %s is ambiguous", name)
+}
+
+func field(schema *arrow.Schema, name string) (arrow.Field, bool) {
+	if indices := schema.FieldIndices(name); len(indices) == 1 {
+		return schema.Field(indices[0]), true
+	}
+	return arrow.Field{}, false
+}
+
+func literalDatum(l *Literal) compute.Datum {
+	if l.Value == nil {
+		return compute.NewDatum(scalar.MakeNullScalar(arrow.Null))
+	}
+	return compute.NewDatum(scalar.MakeScalar(l.Value))
+}
+
+func newArrayDatum(arr arrow.Array) compute.Datum {
+	defer arr.Release()
+	return compute.NewDatum(arr)
+}
+
+func datumType(d compute.Datum) arrow.DataType {
+	return d.(compute.ArrayLikeDatum).Type()
+}
+
+// toArray 将结果转换为 rows 行的数组，标量结果按行数重复
+func toArray(d compute.Datum, rows int64, mem memory.Allocator) (arrow.Array, error) {
+	switch d := d.(type) {
+	case *compute.ArrayDatum:
+		return d.MakeArray(), nil
+	case *compute.ScalarDatum:
+		return scalar.MakeArrayFromScalar(d.Value, int(rows), mem)
+	}
+	return nil, fmt.Errorf("unexpected %s result", d.Kind())
+}
+
+func numeric(t arrow.DataType) bool {
+	return arrow.IsInteger(t.ID()) || arrow.IsFloating(t.ID())
+}
+
+// Reader 对 src 的每批记录执行 Evaluator，过滤后没有剩余行的批次被跳过
+type Reader struct {
+	src    iterator.Records
+	eval   *Evaluator
+	schema *arrow.Schema
+	record arrow.Record
+	err    error
+}
+
+// NewReader 创建读取器，Release 时一并释放 src
+func NewReader(src iterator.Records, eval *Evaluator) *Reader {
+	return &Reader{src: src, eval: eval}
+}
+
+func (r *Reader) Next() bool {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	if r.err != nil || r.src == nil {
+		return false
+	}
+	for r.src.Next() {
+		record, err := r.eval.Apply(r.src.Record())
+		if err != nil {
+			r.err = err
+			return false
+		}
+		r.schema = record.Schema()
+		if record.NumRows() == 0 {
+			record.Release()
+			continue
+		}
+		r.record = record
+		return true
+	}
+	r.err = r.src.Err()
+	return false
+}
+
+func (r *Reader) Record() arrow.Record {
+	return r.record
+}
+
+// Schema 返回计算结果的 schema；所有批次都被过滤掉或 src 没有记录时，
+// 对 src 的 schema 计算一个空批次得到
+func (r *Reader) Schema() *arrow.Schema {
+	if r.schema != nil || r.src == nil {
+		return r.schema
+	}
+	schema := iterator.SchemaOf(r.src)
+	if schema == nil {
+		return nil
+	}
+	empty := array.NewRecordBuilder(r.eval.mem, schema)
+	defer empty.Release()
+	input := empty.NewRecord()
+	defer input.Release()
+	record, err := r.eval.Apply(input)
+	if err != nil {
+		return nil
+	}
+	r.schema = record.Schema()
+	record.Release()
+	return r.schema
+}
+
+func (r *Reader) Err() error {
+	return r.err
+}
+
+func (r *Reader) Release() {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	if r.src != nil {
+		r.src.Release()
+		r.src = nil
+	}
+}
@@ -0,0 +1,264 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"test/iterator"
+	"test/iterator/iteratortest"
+)
+
+var students = arrow.NewSchema([]arrow.Field{
+	{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+	{Name: "dept", Type: arrow.BinaryTypes.String},
+	{Name: "score", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	{Name: "gpa", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+}, nil)
+
+// studentBatches 两批测试数据，包含 name、score、gpa 为 null 的行
+var studentBatches = []string{
+	`[{"name": "Alice", "dept": "math", "score": 90, "gpa": 3.8},
+	  {"name": "Bob", "dept": "math", "score": 55, "gpa": 2.1},
+	  {"name": "Carol", "dept": "cs", "score": null, "gpa": 3.2},
+	  {"name": null, "dept": "cs", "score": 70, "gpa": null}]`,
+	`[{"name": "Dave", "dept": "bio", "score": 60, "gpa": 2.9},
+	  {"name": "Eve", "dept": "cs", "score": 85, "gpa": null}]`,
+}
+
+// readRows 读完 it 并将每行格式化为以 | 分隔的字符串，null 为 NULL；返回列名、行和错误，不释放 it
+func readRows(it iterator.Records) ([]string, []string, error) {
+	var columns, rows []string
+	for it.Next() {
+		record := it.Record()
+		if columns == nil {
+			for _, field := range record.Schema().Fields() {
+				columns = append(columns, field.Name)
+			}
+		}
+		for i := 0; i < int(record.NumRows()); i++ {
+			values := make([]string, record.NumCols())
+			for j, column := range record.Columns() {
+				if column.IsNull(i) {
+					values[j] = "NULL"
+				} else {
+					values[j] = column.ValueStr(i)
+				}
+			}
+			rows = append(rows, strings.Join(values, "|"))
+		}
+	}
+	return columns, rows, it.Err()
+}
+
+// names 取每行的第一列；只选普通列时投影下推到服务端，内存数据源仍返回全部列
+func names(rows []string) []string {
+	var out []string
+	for _, row := range rows {
+		out = append(out, strings.SplitN(row, "|", 2)[0])
+	}
+	return out
+}
+
+// runQuery 解析、规划并在内存数据上执行查询，检查内存是否全部释放
+func runQuery(t *testing.T, sql string, schema *arrow.Schema, batches ...string) (columns, rows []string, err error) {
+	t.Helper()
+	q, err := Parse(sql)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	plan, err := q.Plan()
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	src := iteratortest.NewSource(t, schema, batches...)
+	it := plan.Wrap(src.Iterator(mem), mem)
+	defer it.Release()
+	return readRows(it)
+}
+
+func TestWrapWhere(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		// IN 下推到服务端，客户端不再重复过滤
+		{"pushed condition is not re-evaluated", "SELECT name FROM t WHERE name IN ('Alice')", []string{"Alice", "Bob", "Carol", "NULL", "Dave", "Eve"}},
+		{"comparison", "SELECT name FROM t WHERE score >= 60", []string{"Alice", "NULL", "Dave", "Eve"}},
+		{"mirrored comparison", "SELECT name FROM t WHERE 60 <= score", []string{"Alice", "NULL", "Dave", "Eve"}},
+		{"mirrored strict comparison", "SELECT name FROM t WHERE 60 > score", []string{"Bob"}},
+		{"mirrored equality", "SELECT name FROM t WHERE 'cs' = dept", []string{"Carol", "NULL", "Eve"}},
+		{"integer column with float literal", "SELECT name FROM t WHERE score > 84.5", []string{"Alice", "Eve"}},
+		{"column against column", "SELECT name FROM t WHERE gpa * 25 > score", []string{"Alice", "Dave"}},
+		{"comparison with null never matches", "SELECT name FROM t WHERE score = NULL OR score != NULL", nil},
+		{"null or true is true", "SELECT name FROM t WHERE score > 80 OR gpa > 3", []string{"Alice", "Carol", "Eve"}},
+		{"null and false is false", "SELECT name FROM t WHERE NOT (score > 80 AND gpa > 3)", []string{"Bob", "NULL", "Dave"}},
+		{"not null is null", "SELECT name FROM t WHERE NOT (score > 80)", []string{"Bob", "NULL", "Dave"}},
+		{"is null", "SELECT name FROM t WHERE score IS NULL", []string{"Carol"}},
+		{"is not null", "SELECT name FROM t WHERE name IS NOT NULL AND gpa IS NOT NULL", []string{"Alice", "Bob", "Carol", "Dave"}},
+		{"in with null", "SELECT name FROM t WHERE score IN (90, NULL)", []string{"Alice"}},
+		{"not in with null matches nothing", "SELECT name FROM t WHERE score NOT IN (90, NULL)", nil},
+		{"not in", "SELECT name FROM t WHERE score NOT IN (90, 55)", []string{"NULL", "Dave", "Eve"}},
+		{"between", "SELECT name FROM t WHERE score BETWEEN 55 AND 70", []string{"Bob", "NULL", "Dave"}},
+		{"not between", "SELECT name FROM t WHERE score NOT BETWEEN 55 AND 70", []string{"Alice", "Eve"}},
+		{"like", "SELECT name FROM t WHERE name LIKE 'A%' OR name LIKE '_ve'", []string{"Alice", "Eve"}},
+		{"not like skips null", "SELECT name FROM t WHERE name NOT LIKE '%a%'", []string{"Alice", "Bob", "Eve"}},
+		{"where null", "SELECT name FROM t WHERE NULL", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, rows, err := runQuery(t, tt.sql, students, studentBatches...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rows = names(rows); !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("rows = %q, want %q", rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapProject(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		columns []string
+		rows    []string
+	}{
+		{
+			name:    "computed columns",
+			sql:     "SELECT name, score * 2 AS double, -score, gpa + 1 FROM t WHERE dept = 'math'",
+			columns: []string{"name", "double", "-score", "gpa + 1"},
+			rows:    []string{"Alice|180|-90|4.8", "Bob|110|-55|3.1"},
+		},
+		{
+			name:    "rename and reorder",
+			sql:     "SELECT score AS s, name AS n FROM t WHERE score > 80",
+			columns: []string{"s", "n"},
+			rows:    []string{"90|Alice", "85|Eve"},
+		},
+		{
+			name:    "null propagates through arithmetic",
+			sql:     "SELECT name, score + 1 AS next FROM t WHERE dept = 'cs'",
+			columns: []string{"name", "next"},
+			rows:    []string{"Carol|NULL", "NULL|71", "Eve|86"},
+		},
+		{
+			name:    "literal column",
+			sql:     "SELECT name, 'x' AS tag FROM t WHERE score = 55",
+			columns: []string{"name", "tag"},
+			rows:    []string{"Bob|x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, rows, err := runQuery(t, tt.sql, students, studentBatches...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(columns, tt.columns) {
+				t.Errorf("columns = %q, want %q", columns, tt.columns)
+			}
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("rows = %q, want %q", rows, tt.rows)
+			}
+		})
+	}
+}
+
+func TestWrapErrors(t *testing.T) {
+	tests := []struct {
+		sql string
+		err string
+	}{
+		{"SELECT name FROM t WHERE name > 1", "cannot compare"},
+		{"SELECT name FROM t WHERE missing = 1", "missing"},
+		{"SELECT name FROM t WHERE score + 1", "boolean"},
+		{"SELECT score / 0 FROM t", "divide by zero"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			_, _, err := runQuery(t, tt.sql, students, studentBatches...)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestWrapEmptyResult(t *testing.T) {
+	q, err := Parse("SELECT name AS n, score * 2 AS s FROM t WHERE score > 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := q.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := plan.Wrap(iteratortest.NewSource(t, students, studentBatches...).Iterator(memory.DefaultAllocator), nil)
+	defer it.Release()
+	_, rows, err := readRows(it)
+	if err != nil || len(rows) != 0 {
+		t.Fatalf("rows = %q, err = %v", rows, err)
+	}
+	// 所有行都被过滤掉时仍能得到投影后的 schema
+	schema := iterator.SchemaOf(it)
+	if schema == nil {
+		t.Fatal("SchemaOf = nil")
+	}
+	if got := []string{schema.Field(0).Name, schema.Field(1).Name}; !reflect.DeepEqual(got, []string{"n", "s"}) {
+		t.Errorf("schema fields = %q", got)
+	}
+}
+
+func TestWrapLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		sql   string
+		rows  []string
+		calls int // 数据流被取消前 Source 的调用次数
+	}{
+		{"limit within the first batch", "SELECT name FROM t LIMIT 2", []string{"Alice", "Bob"}, 1},
+		{"limit across batches", "SELECT name FROM t LIMIT 5", []string{"Alice", "Bob", "Carol", "NULL", "Dave"}, 2},
+		{"offset", "SELECT name FROM t LIMIT 2 OFFSET 3", []string{"NULL", "Dave"}, 2},
+		{"limit after filter", "SELECT name FROM t WHERE score IS NOT NULL LIMIT 2 OFFSET 2", []string{"NULL", "Dave"}, 2},
+		{"offset past the end", "SELECT name FROM t OFFSET 10", nil, 3},
+		{"limit 0", "SELECT name FROM t LIMIT 0", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Parse(tt.sql)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plan, err := q.Plan()
+			if err != nil {
+				t.Fatal(err)
+			}
+			mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+			defer mem.AssertSize(t, 0)
+			src := iteratortest.NewSource(t, students, studentBatches...)
+			it := plan.Wrap(src.Iterator(mem), mem)
+			_, rows, err := readRows(it)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 取够行数后不等 Release 就已取消数据流
+			if !src.Closed {
+				t.Error("stream not cancelled after the last row")
+			}
+			it.Release()
+			if rows = names(rows); !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("rows = %q, want %q", rows, tt.rows)
+			}
+			if src.Calls != tt.calls {
+				t.Errorf("Source called %d times, want %d", src.Calls, tt.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr 表达式节点：*Column、*Literal、*Binary、*Unary、*In、*Between、*Like、*IsNull、*Call
+type Expr interface {
+	String() string
+}
+
+// Column 列引用，带限定名时 Name 为 t.col 形式
+type Column struct {
+	Name string
+}
+
+func (c *Column) String() string {
+	return quoteIdent(c.Name)
+}
+
+// Literal 字面量，Value 为 string、int64、float64、bool 或 nil（NULL）
+type Literal struct {
+	Value any
+}
+
+func (l *Literal) String() string {
+	switch v := l.Value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v))
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(l.Value)
+}
+
+// Binary 二元运算，Op 为 AND、OR、=、!=、<、<=、>、>=、+、-、*、/、%，<> 和 == 解析时统一为 != 和 =
+type Binary struct {
+	Op          string
+	Left, Right Expr
+}
+
+func (b *Binary) String() string {
+	prec := precedence(b)
+	return fmt.Sprintf("%s %s %s", wrap(b.Left, prec), b.Op, wrap(b.Right, prec+1))
+}
+
+// Unary 一元运算，Op 为 NOT 或 -
+type Unary struct {
+	Op string
+	X  Expr
+}
+
+func (u *Unary) String() string {
+	if u.Op == "NOT" {
+		return "NOT " + wrap(u.X, precNot)
+	}
+	return u.Op + wrap(u.X, precUnary)
+}
+
+// In X [NOT] IN (Values...)
+type In struct {
+	X      Expr
+	Values []Expr
+	Not    bool
+}
+
+func (e *In) String() string {
+	values := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		values[i] = v.String()
+	}
+	return fmt.Sprintf("%s %sIN (%s)", wrap(e.X, precPredicate+1), not(e.Not), strings.Join(values, ", "))
+}
+
+// Between X [NOT] BETWEEN Lo AND Hi
+type Between struct {
+	X, Lo, Hi Expr
+	Not       bool
+}
+
+func (e *Between) String() string {
+	return fmt.Sprintf("%s %sBETWEEN %s AND %s", wrap(e.X, precPredicate+1), not(e.Not), wrap(e.Lo, precPredicate+1), wrap(e.Hi, precPredicate+1))
+}
+
+// Like X [NOT] LIKE Pattern，% 匹配任意个字符，_ 匹配一个字符
+type Like struct {
+	X, Pattern Expr
+	Not        bool
+}
+
+func (e *Like) String() string {
+	return fmt.Sprintf("%s %sLIKE %s", wrap(e.X, precPredicate+1), not(e.Not), wrap(e.Pattern, precPredicate+1))
+}
+
+// IsNull X IS [NOT] NULL
+type IsNull struct {
+	X   Expr
+	Not bool
+}
+
+func (e *IsNull) String() string {
+	return fmt.Sprintf("%s IS %sNULL", wrap(e.X, precPredicate+1), not(e.Not))
+}
+
+// Call 函数调用，Name 为大写，COUNT(*) 的 Star 为 true
+type Call struct {
+	Name     string
+	Args     []Expr
+	Distinct bool
+	Star     bool
+}
+
+func (c *Call) String() string {
+	if c.Star {
+		return c.Name + "(*)"
+	}
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = arg.String()
+	}
+	distinct := ""
+	if c.Distinct {
+		distinct = "DISTINCT "
+	}
+	return fmt.Sprintf("%s(%s%s)", c.Name, distinct, strings.Join(args, ", "))
+}
+
+// 运算符优先级，与解析顺序一致
+const (
+	precOr = iota + 1
+	precAnd
+	precNot
+	precPredicate
+	precAdditive
+	precMultiplicative
+	precUnary
+	precPrimary
+)
+
+func precedence(e Expr) int {
+	switch e := e.(type) {
+	case *Binary:
+		switch e.Op {
+		case "OR":
+			return precOr
+		case "AND":
+			return precAnd
+		case "+", "-":
+			return precAdditive
+		case "*", "/", "%":
+			return precMultiplicative
+		}
+		return precPredicate
+	case *Unary:
+		if e.Op == "NOT" {
+			return precNot
+		}
+		return precUnary
+	case *In, *Between, *Like, *IsNull:
+		return precPredicate
+	}
+	return precPrimary
+}
+
+// wrap 优先级低于 min 时加括号
+func wrap(e Expr, min int) string {
+	if precedence(e) < min {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
+
+func not(negated bool) string {
+	if negated {
+		return "NOT "
+	}
+	return ""
+}
+
+// quoteIdent 需要时给标识符加双引号，限定名的每一段单独处理
+func quoteIdent(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if !plainIdent(part) {
+			parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func plainIdent(s string) bool {
+	if s == "" || keywords[strings.ToUpper(s)] {
+		return false
+	}
+	for i, c := range s {
+		if i == 0 && !isIdentStart(c) || !isIdentPart(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// conjuncts 将 AND 连接的表达式展开为列表
+func conjuncts(e Expr) []Expr {
+	if b, ok := e.(*Binary); ok && b.Op == "AND" {
+		return append(conjuncts(b.Left), conjuncts(b.Right)...)
+	}
+	if e == nil {
+		return nil
+	}
+	return []Expr{e}
+}
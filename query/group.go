@@ -0,0 +1,183 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"test/aggregate"
+)
+
+// Aggregate 在客户端计算的分组聚合
+type Aggregate struct {
+	GroupBy []string
+	Specs   []aggregate.Spec // Name 为 SELECT 中的别名或表达式本身
+}
+
+func (a *Aggregate) String() string {
+	items := make([]string, len(a.Specs))
+	for i, spec := range a.Specs {
+		arg := "*"
+		if spec.Column != "" {
+			arg = quoteIdent(spec.Column)
+		}
+		fn := strings.ToUpper(spec.Func.String())
+		if spec.Func == aggregate.CountDistinct {
+			fn, arg = "COUNT", "DISTINCT "+arg
+		}
+		items[i] = fmt.Sprintf("%s(%s)", fn, arg)
+		if spec.Name != items[i] {
+			items[i] += " AS " + quoteIdent(spec.Name)
+		}
+	}
+	s := strings.Join(items, ", ")
+	if len(a.GroupBy) > 0 {
+		groups := make([]string, len(a.GroupBy))
+		for i, name := range a.GroupBy {
+			groups[i] = quoteIdent(name)
+		}
+		if s != "" {
+			s += " "
+		}
+		s += "GROUP BY " + strings.Join(groups, ", ")
+	}
+	return s
+}
+
+// aggregates 是否为聚合查询：有 GROUP BY 或 SELECT 中有聚合函数
+func (q *Query) aggregates() bool {
+	if len(q.GroupBy) > 0 {
+		return true
+	}
+	for _, item := range q.Fields {
+		found := false
+		walk(item.Expr, func(x Expr) bool {
+			if call, ok := x.(*Call); ok && isAggregate(call) {
+				found = true
+			}
+			return !found
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+func isAggregate(call *Call) bool {
+	_, err := aggregate.ParseFunc(call.Name, false)
+	return err == nil
+}
+
+// planAggregate 拆分聚合查询：GROUP BY 只能是列名，SELECT 中的每一项只能是分组列或以列、* 为参数的聚合函数，
+// 聚合结果的列顺序或名称与 SELECT 不同时在聚合之后投影
+func (q *Query) planAggregate(plan *Plan) error {
+	if len(q.Fields) == 0 {
+		return unsupported("SELECT", "*", "an aggregate query must list the group columns and aggregates it returns")
+	}
+	agg := &Aggregate{}
+	grouped := make(map[string]bool)
+	columns := newColumnSet()
+	for _, e := range q.GroupBy {
+		column, ok := e.(*Column)
+		if !ok {
+			return unsupported("GROUP BY", e.String(), "only plain columns can be grouped on")
+		}
+		if !grouped[column.Name] {
+			grouped[column.Name] = true
+			agg.GroupBy = append(agg.GroupBy, column.Name)
+			columns.add(column)
+		}
+	}
+
+	var project, output []SelectItem
+	names := make(map[string]bool)
+	for _, name := range agg.GroupBy {
+		output = append(output, SelectItem{Expr: &Column{Name: name}})
+	}
+	for _, item := range q.Fields {
+		name := item.Name()
+		switch x := item.Expr.(type) {
+		case *Column:
+			if !grouped[x.Name] {
+				return unsupported("SELECT", x.String(), "columns must appear in GROUP BY or inside an aggregate function")
+			}
+			project = append(project, SelectItem{Expr: &Column{Name: x.Name}, Alias: item.Alias})
+		case *Call:
+			spec, err := aggregateSpec(x)
+			if err != nil {
+				return err
+			}
+			if grouped[name] {
+				return unsupported("SELECT", x.String(), fmt.Sprintf("%s is also a GROUP BY column, add a different alias", quoteIdent(name)))
+			}
+			spec.Name = name
+			agg.Specs = append(agg.Specs, spec)
+			if spec.Column != "" {
+				columns.add(x.Args[0])
+			}
+			output = append(output, SelectItem{Expr: &Column{Name: name}})
+			project = append(project, SelectItem{Expr: &Column{Name: name}})
+		default:
+			return unsupported("SELECT", item.Expr.String(), "an aggregate query can only select group columns and aggregate functions")
+		}
+		if names[name] {
+			return unsupported("SELECT", item.Expr.String(), fmt.Sprintf("duplicate column name %s, add an alias", quoteIdent(name)))
+		}
+		names[name] = true
+	}
+
+	for _, r := range plan.Residual {
+		columns.add(r.Expr)
+	}
+	plan.Pushdown.DbFields = columns.names
+	plan.Aggregate = agg
+	if !sameItems(project, output) {
+		plan.Project = project
+	}
+
+	// 服务端按分组列排序后，分组按首次出现的顺序输出，结果也就按这些列排好了序
+	for _, item := range q.OrderBy {
+		name, err := q.orderColumn(item)
+		if err == nil && !grouped[name] {
+			err = unsupported("ORDER BY", item.Expr.String(), "an aggregate query can only be sorted on GROUP BY columns")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aggregateSpec 将聚合函数调用转换为 aggregate.Spec
+func aggregateSpec(call *Call) (aggregate.Spec, error) {
+	fn, err := aggregate.ParseFunc(call.Name, call.Distinct)
+	if err != nil {
+		return aggregate.Spec{}, unsupported("SELECT", call.String(), err.Error())
+	}
+	if call.Star {
+		if fn != aggregate.Count {
+			return aggregate.Spec{}, unsupported("SELECT", call.String(), "only COUNT accepts *")
+		}
+		return aggregate.Spec{Func: fn}, nil
+	}
+	if len(call.Args) != 1 {
+		return aggregate.Spec{}, unsupported("SELECT", call.String(), fmt.Sprintf("%s takes exactly one argument", call.Name))
+	}
+	column, ok := call.Args[0].(*Column)
+	if !ok {
+		return aggregate.Spec{}, unsupported("SELECT", call.String(), "aggregate arguments must be plain columns")
+	}
+	return aggregate.Spec{Func: fn, Column: column.Name}, nil
+}
+
+// sameItems 两组投影是否输出相同的列
+func sameItems(a, b []SelectItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name() != b[i].Name() || a[i].Expr.String() != b[i].Expr.String() {
+			return false
+		}
+	}
+	return true
+}
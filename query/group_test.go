@@ -0,0 +1,77 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWrapAggregate(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		columns []string
+		rows    []string
+	}{
+		{
+			name:    "group by",
+			sql:     "SELECT dept, COUNT(*) AS n, SUM(score) AS total FROM t GROUP BY dept",
+			columns: []string{"dept", "n", "total"},
+			rows:    []string{"math|2|145", "cs|3|155", "bio|1|60"},
+		},
+		{
+			name:    "nulls are not counted",
+			sql:     "SELECT dept, COUNT(score) AS scored, COUNT(name) AS named, AVG(score) AS mean FROM t GROUP BY dept",
+			columns: []string{"dept", "scored", "named", "mean"},
+			rows:    []string{"math|2|2|72.5", "cs|2|2|77.5", "bio|1|1|60"},
+		},
+		{
+			name:    "aggregates before the group column",
+			sql:     "SELECT MAX(gpa) AS best, dept FROM t GROUP BY dept",
+			columns: []string{"best", "dept"},
+			rows:    []string{"3.8|math", "3.2|cs", "2.9|bio"},
+		},
+		{
+			name:    "group column renamed",
+			sql:     "SELECT dept AS d, MIN(score) AS low FROM t GROUP BY dept",
+			columns: []string{"d", "low"},
+			rows:    []string{"math|55", "cs|70", "bio|60"},
+		},
+		{
+			name:    "client filter before aggregating",
+			sql:     "SELECT COUNT(*) AS n, COUNT(DISTINCT dept) AS depts FROM t WHERE 60 <= score",
+			columns: []string{"n", "depts"},
+			rows:    []string{"4|3"},
+		},
+		{
+			name:    "no rows without group by",
+			sql:     "SELECT COUNT(*) AS n, SUM(score) AS total FROM t WHERE score > 100",
+			columns: []string{"n", "total"},
+			rows:    []string{"0|NULL"},
+		},
+		{
+			name:    "no rows with group by",
+			sql:     "SELECT dept, COUNT(*) AS n FROM t WHERE score > 100 GROUP BY dept",
+			columns: []string{"dept", "n"},
+		},
+		{
+			name:    "limit and offset on groups",
+			sql:     "SELECT dept, COUNT(*) AS n FROM t GROUP BY dept LIMIT 1 OFFSET 1",
+			columns: []string{"dept", "n"},
+			rows:    []string{"cs|3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, rows, err := runQuery(t, tt.sql, students, studentBatches...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(columns, tt.columns) {
+				t.Errorf("columns = %q, want %q", columns, tt.columns)
+			}
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("rows = %q, want %q", rows, tt.rows)
+			}
+		})
+	}
+}
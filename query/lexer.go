@@ -0,0 +1,170 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF    tokenKind = iota
+	tokIdent            // 未加引号的标识符或关键字
+	tokQuoted           // "name" 或 `name`，不会被当作关键字
+	tokString           // 'text'，'' 表示一个单引号
+	tokNumber
+	tokSymbol // 运算符和标点
+)
+
+type token struct {
+	kind tokenKind
+	text string // 字符串和带引号的标识符为去掉引号后的内容，关键字保持原样
+	pos  int    // 在语句中的字节偏移
+}
+
+// is 判断是否为指定关键字或符号，关键字不区分大小写
+func (t token) is(text string) bool {
+	switch t.kind {
+	case tokIdent:
+		return strings.EqualFold(t.text, text)
+	case tokSymbol:
+		return t.text == text
+	}
+	return false
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return "'" + strings.ReplaceAll(t.text, "'", "''") + "'"
+	case tokQuoted:
+		return `"` + t.text + `"`
+	}
+	return t.text
+}
+
+// symbols 按长度从长到短匹配
+var symbols = []string{"<>", "!=", ">=", "<=", "==", "=", "<", ">", "(", ")", ",", ".", "*", "+", "-", "/", "%", ";"}
+
+// lex 将语句切分为 token，最后一个总是 tokEOF
+func lex(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		c := rune(input[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '-' && strings.HasPrefix(input[i:], "--"):
+			// 行注释
+			for i < len(input) && input[i] != '\n' {
+				i++
+			}
+		case c == '\'':
+			text, n, err := lexQuoted(input, i, '\'')
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: text, pos: i})
+			i += n
+		case c == '"' || c == '`':
+			text, n, err := lexQuoted(input, i, byte(c))
+			if err != nil {
+				return nil, err
+			}
+			if text == "" {
+				return nil, &SyntaxError{Pos: i, Msg: "empty quoted identifier"}
+			}
+			tokens = append(tokens, token{kind: tokQuoted, text: text, pos: i})
+			i += n
+		case isDigit(c) || (c == '.' && i+1 < len(input) && isDigit(rune(input[i+1]))):
+			n := lexNumber(input[i:])
+			if j := i + n; j < len(input) && isIdentPart(rune(input[j])) {
+				return nil, &SyntaxError{Pos: i, Near: input[i : j+1], Msg: "invalid number"}
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: input[i : i+n], pos: i})
+			i += n
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(input) && isIdentPart(rune(input[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: input[i:j], pos: i})
+			i = j
+		default:
+			matched := false
+			for _, s := range symbols {
+				if strings.HasPrefix(input[i:], s) {
+					tokens = append(tokens, token{kind: tokSymbol, text: s, pos: i})
+					i += len(s)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, &SyntaxError{Pos: i, Near: string(c), Msg: fmt.Sprintf("unexpected character %q", c)}
+			}
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(input)}), nil
+}
+
+// lexQuoted 读取以 quote 包围的内容，连续两个 quote 表示一个 quote 字符，返回内容和消耗的字节数
+func lexQuoted(input string, start int, quote byte) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(input); i++ {
+		if input[i] != quote {
+			b.WriteByte(input[i])
+			continue
+		}
+		if i+1 < len(input) && input[i+1] == quote {
+			b.WriteByte(quote)
+			i++
+			continue
+		}
+		return b.String(), i + 1 - start, nil
+	}
+	return "", 0, &SyntaxError{Pos: start, Msg: fmt.Sprintf("unterminated %c", quote)}
+}
+
+// lexNumber 返回 s 开头数字字面量的长度，支持小数和科学计数法
+func lexNumber(s string) int {
+	i := 0
+	for i < len(s) && isDigit(rune(s[i])) {
+		i++
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && isDigit(rune(s[i])) {
+			i++
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && isDigit(rune(s[j])) {
+			for j < len(s) && isDigit(rune(s[j])) {
+				j++
+			}
+			i = j
+		}
+	}
+	return i
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || c >= 0x80
+}
+
+func isIdentPart(c rune) bool {
+	return isIdentStart(c) || isDigit(c) || c == '$'
+}
@@ -0,0 +1,481 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// keywords 保留字，作为列名使用时需要加双引号
+var keywords = map[string]bool{
+	"SELECT": true, "DISTINCT": true, "FROM": true, "WHERE": true, "GROUP": true, "HAVING": true,
+	"ORDER": true, "BY": true, "ASC": true, "DESC": true, "NULLS": true, "LIMIT": true, "OFFSET": true,
+	"AND": true, "OR": true, "NOT": true, "IN": true, "BETWEEN": true, "LIKE": true, "IS": true,
+	"NULL": true, "TRUE": true, "FALSE": true, "AS": true, "JOIN": true, "UNION": true,
+}
+
+// comparisons 比较运算符，== 和 <> 统一为 = 和 !=
+var comparisons = map[string]string{"=": "=", "==": "=", "!=": "!=", "<>": "!=", "<": "<", "<=": "<=", ">": ">", ">=": ">="}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept 下一个 token 为 text 时消耗它
+func (p *parser) accept(text string) bool {
+	if p.peek().is(text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf(p.peek(), "expected %s", text)
+	}
+	return nil
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return &SyntaxError{Pos: t.pos, Near: t.String(), Msg: fmt.Sprintf(format, args...)}
+}
+
+// keyword 判断 token 是否为保留字
+func keyword(t token) bool {
+	return t.kind == tokIdent && keywords[strings.ToUpper(t.text)]
+}
+
+// parseQuery [SELECT items] [FROM name] [WHERE expr] [GROUP BY exprs] [ORDER BY items] [LIMIT n] [OFFSET m]，各子句均可省略但不能全部省略
+func (p *parser) parseQuery() (*Query, error) {
+	q := &Query{Limit: -1}
+	if p.peek().kind == tokEOF {
+		return nil, &SyntaxError{Msg: "empty query"}
+	}
+	if p.accept("SELECT") {
+		if t := p.peek(); t.is("DISTINCT") {
+			return nil, unsupported("SELECT", "DISTINCT", "duplicate rows are not removed by the data service")
+		}
+		if !p.accept("*") {
+			for {
+				item, err := p.parseSelectItem()
+				if err != nil {
+					return nil, err
+				}
+				q.Fields = append(q.Fields, item)
+				if !p.accept(",") {
+					break
+				}
+			}
+		}
+	}
+	if p.accept("FROM") {
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		q.From = name
+		if t := p.peek(); t.is(",") || t.is("JOIN") {
+			return nil, unsupported("FROM", "", "a read request covers a single asset or table")
+		}
+	}
+	if p.accept("WHERE") {
+		where, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		q.Where = where
+	}
+	if p.accept("GROUP") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			q.GroupBy = append(q.GroupBy, expr)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if t := p.peek(); t.is("HAVING") {
+		return nil, unsupported("HAVING", "", "groups cannot be filtered after aggregation")
+	}
+	if p.accept("ORDER") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			item, err := p.parseOrderItem()
+			if err != nil {
+				return nil, err
+			}
+			q.OrderBy = append(q.OrderBy, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if p.accept("LIMIT") {
+		n, err := p.parseCount("LIMIT")
+		if err != nil {
+			return nil, err
+		}
+		q.Limit = n
+	}
+	if p.accept("OFFSET") {
+		n, err := p.parseCount("OFFSET")
+		if err != nil {
+			return nil, err
+		}
+		q.Offset = n
+	}
+	p.accept(";")
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return q, nil
+}
+
+// parseSelectItem expr [[AS] alias]
+func (p *parser) parseSelectItem() (SelectItem, error) {
+	expr, err := p.parseExpr()
+	if err != nil {
+		return SelectItem{}, err
+	}
+	item := SelectItem{Expr: expr}
+	explicit := p.accept("AS")
+	if t := p.peek(); t.kind == tokQuoted || (t.kind == tokIdent && !keyword(t)) {
+		item.Alias = p.next().text
+	} else if explicit {
+		return SelectItem{}, p.errorf(t, "expected alias after AS")
+	}
+	return item, nil
+}
+
+// parseOrderItem expr [ASC|DESC]
+func (p *parser) parseOrderItem() (OrderItem, error) {
+	expr, err := p.parseExpr()
+	if err != nil {
+		return OrderItem{}, err
+	}
+	item := OrderItem{Expr: expr}
+	if p.accept("DESC") {
+		item.Desc = true
+	} else {
+		p.accept("ASC")
+	}
+	if p.peek().is("NULLS") {
+		return OrderItem{}, unsupported("ORDER BY", expr.String()+" NULLS ...", "null ordering is decided by the data source")
+	}
+	return item, nil
+}
+
+// parseCount 解析 LIMIT、OFFSET 后的非负整数
+func (p *parser) parseCount(clause string) (int64, error) {
+	t := p.peek()
+	if t.kind != tokNumber {
+		return 0, p.errorf(t, "expected row count after %s", clause)
+	}
+	n, err := strconv.ParseInt(t.text, 10, 64)
+	if err != nil || n < 0 {
+		return 0, p.errorf(t, "%s must be a non-negative integer", clause)
+	}
+	p.next()
+	return n, nil
+}
+
+// parseName 解析可带限定的名称 a.b.c
+func (p *parser) parseName() (string, error) {
+	var parts []string
+	for {
+		t := p.peek()
+		if t.kind != tokQuoted && (t.kind != tokIdent || keyword(t)) {
+			return "", p.errorf(t, "expected name")
+		}
+		parts = append(parts, p.next().text)
+		if !p.accept(".") {
+			return strings.Join(parts, "."), nil
+		}
+	}
+}
+
+// 运算符优先级从低到高：OR、AND、NOT、比较/IN/BETWEEN/LIKE/IS、+ -、* / %、一元 -
+
+func (p *parser) parseExpr() (Expr, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: "OR", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: "AND", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.accept("NOT") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: "NOT", X: x}, nil
+	}
+	return p.parsePredicate()
+}
+
+// parsePredicate 解析比较、[NOT] IN、[NOT] BETWEEN、[NOT] LIKE 和 IS [NOT] NULL
+func (p *parser) parsePredicate() (Expr, error) {
+	x, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind == tokSymbol {
+		if op, ok := comparisons[t.text]; ok {
+			p.next()
+			y, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &Binary{Op: op, Left: x, Right: y}, nil
+		}
+	}
+	if p.accept("IS") {
+		negated := p.accept("NOT")
+		if err := p.expect("NULL"); err != nil {
+			return nil, err
+		}
+		return &IsNull{X: x, Not: negated}, nil
+	}
+	negated := p.accept("NOT")
+	switch {
+	case p.accept("IN"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		in := &In{X: x, Not: negated}
+		for {
+			v, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			in.Values = append(in.Values, v)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return in, nil
+	case p.accept("BETWEEN"):
+		lo, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("AND"); err != nil {
+			return nil, err
+		}
+		hi, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &Between{X: x, Lo: lo, Hi: hi, Not: negated}, nil
+	case p.accept("LIKE"):
+		pattern, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &Like{X: x, Pattern: pattern, Not: negated}, nil
+	case negated:
+		return nil, p.errorf(p.peek(), "expected IN, BETWEEN or LIKE after NOT")
+	}
+	return x, nil
+}
+
+func (p *parser) parseAdditive() (Expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !t.is("+") && !t.is("-") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: t.text, Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseMultiplicative() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !t.is("*") && !t.is("/") && !t.is("%") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: t.text, Left: left, Right: right}
+	}
+}
+
+// parseUnary 负号作用于数字字面量时直接折叠为负数
+func (p *parser) parseUnary() (Expr, error) {
+	if p.accept("-") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if l, ok := x.(*Literal); ok {
+			switch v := l.Value.(type) {
+			case int64:
+				return &Literal{Value: -v}, nil
+			case float64:
+				return &Literal{Value: -v}, nil
+			}
+		}
+		return &Unary{Op: "-", X: x}, nil
+	}
+	p.accept("+")
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokNumber:
+		p.next()
+		return parseNumber(t)
+	case tokString:
+		p.next()
+		return &Literal{Value: t.text}, nil
+	case tokQuoted:
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		return &Column{Name: name}, nil
+	case tokIdent:
+		switch {
+		case t.is("NULL"):
+			p.next()
+			return &Literal{}, nil
+		case t.is("TRUE"), t.is("FALSE"):
+			p.next()
+			return &Literal{Value: t.is("TRUE")}, nil
+		case keyword(t):
+			return nil, p.errorf(t, "unexpected keyword %s, quote it to use as a column name", strings.ToUpper(t.text))
+		}
+		if p.tokens[p.pos+1].is("(") {
+			return p.parseCall()
+		}
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		return &Column{Name: name}, nil
+	case tokSymbol:
+		if p.accept("(") {
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+	}
+	return nil, p.errorf(t, "expected expression")
+}
+
+// parseCall name([DISTINCT] args...) 或 name(*)
+func (p *parser) parseCall() (Expr, error) {
+	call := &Call{Name: strings.ToUpper(p.next().text)}
+	p.next() // (
+	switch {
+	case p.accept("*"):
+		call.Star = true
+	case p.peek().is(")"):
+	default:
+		call.Distinct = p.accept("DISTINCT")
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// parseNumber 整数解析为 int64，超出范围或带小数、指数时解析为 float64
+func parseNumber(t token) (Expr, error) {
+	if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+		return &Literal{Value: i}, nil
+	}
+	f, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return nil, &SyntaxError{Pos: t.pos, Near: t.text, Msg: "invalid number"}
+	}
+	return &Literal{Value: f}, nil
+}
@@ -0,0 +1,100 @@
+package query
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string // Query.String() 的规范形式
+	}{
+		{"SELECT name, gpa FROM students WHERE name IN ('Alice') AND score >= 60 ORDER BY gpa DESC LIMIT 10",
+			"SELECT name, gpa FROM students WHERE name IN ('Alice') AND score >= 60 ORDER BY gpa DESC LIMIT 10"},
+		{"select * from t", "SELECT * FROM t"},
+		{"WHERE a <> 1", "SELECT * WHERE a != 1"},
+		{"SELECT a AS x, b y FROM t", "SELECT a AS x, b AS y FROM t"},
+		{"SELECT \"select\" FROM t", "SELECT \"select\" FROM t"},
+		{"WHERE a = 1 OR b = 2 AND c = 3", "SELECT * WHERE a = 1 OR b = 2 AND c = 3"},
+		{"WHERE (a = 1 OR b = 2) AND c = 3", "SELECT * WHERE (a = 1 OR b = 2) AND c = 3"},
+		{"SELECT gpa * (2 + 1) FROM t", "SELECT gpa * (2 + 1) FROM t"},
+		{"WHERE NOT a BETWEEN 1 AND 2", "SELECT * WHERE NOT a BETWEEN 1 AND 2"},
+		{"WHERE name NOT LIKE 'A%' AND x IS NOT NULL", "SELECT * WHERE name NOT LIKE 'A%' AND x IS NOT NULL"},
+		{"WHERE name = 'O''Brien'", "SELECT * WHERE name = 'O''Brien'"},
+		{"SELECT dept, COUNT(*), COUNT(DISTINCT name) FROM t GROUP BY dept",
+			"SELECT dept, COUNT(*), COUNT(DISTINCT name) FROM t GROUP BY dept"},
+		{"FROM t LIMIT 5 OFFSET 10;", "SELECT * FROM t LIMIT 5 OFFSET 10"},
+		{"FROM db.t", "SELECT * FROM db.t"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			q, err := Parse(tt.sql)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := q.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			// 规范形式再次解析后不变
+			again, err := Parse(q.String())
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", q.String(), err)
+			}
+			if again.String() != q.String() {
+				t.Errorf("round trip = %q, want %q", again.String(), q.String())
+			}
+		})
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	q, err := Parse("SELECT a FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Limit != -1 || q.Offset != 0 {
+		t.Errorf("Limit, Offset = %d, %d, want -1, 0", q.Limit, q.Offset)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		sql    string
+		syntax bool   // 期望 *SyntaxError，否则期望 *UnsupportedError
+		clause string // UnsupportedError.Clause
+	}{
+		{sql: "", syntax: true},
+		{sql: "SELECT", syntax: true},
+		{sql: "SELECT a FROM", syntax: true},
+		{sql: "SELECT a AS FROM t", syntax: true},
+		{sql: "WHERE a = ", syntax: true},
+		{sql: "WHERE (a = 1", syntax: true},
+		{sql: "WHERE name = 'unterminated", syntax: true},
+		{sql: "LIMIT -1", syntax: true},
+		{sql: "LIMIT x", syntax: true},
+		{sql: "SELECT a FROM t garbage", syntax: true},
+		{sql: "SELECT DISTINCT a FROM t", clause: "SELECT"},
+		{sql: "SELECT a FROM t JOIN u", clause: "FROM"},
+		{sql: "SELECT a FROM t, u", clause: "FROM"},
+		{sql: "SELECT a, COUNT(*) FROM t GROUP BY a HAVING COUNT(*) > 1", clause: "HAVING"},
+		{sql: "FROM t ORDER BY a NULLS FIRST", clause: "ORDER BY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			_, err := Parse(tt.sql)
+			var syntaxErr *SyntaxError
+			var unsupportedErr *UnsupportedError
+			switch {
+			case err == nil:
+				t.Fatal("Parse succeeded, want an error")
+			case tt.syntax && !errors.As(err, &syntaxErr):
+				t.Fatalf("error %v (%T), want *SyntaxError", err, err)
+			case !tt.syntax && !errors.As(err, &unsupportedErr):
+				t.Fatalf("error %v (%T), want *UnsupportedError", err, err)
+			case !tt.syntax && unsupportedErr.Clause != tt.clause:
+				t.Errorf("Clause = %q, want %q", unsupportedErr.Clause, tt.clause)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package query
+
+import (
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"strings"
+	"test/aggregate"
+	"test/filter"
+	"test/iterator"
+)
+
+// Residual 在客户端计算的 WHERE 条件
+type Residual struct {
+	Expr   Expr
+	Reason string // 无法下推的原因
+}
+
+// Plan 查询的执行计划：能下推的部分写入请求，其余的过滤条件和投影在客户端逐批计算
+type Plan struct {
+	Pushdown  *Pushdown
+	Residual  []Residual
+	Aggregate *Aggregate   // 聚合查询时不为空，在客户端过滤之后计算
+	Project   []SelectItem // 为空表示直接返回服务端的列；聚合查询时作用于聚合结果
+	Limit     int64        // 小于 0 表示不限制
+	Offset    int64
+}
+
+// Name 返回结果列名：别名、列名或表达式本身
+func (item SelectItem) Name() string {
+	if item.Alias != "" {
+		return item.Alias
+	}
+	if c, ok := item.Expr.(*Column); ok {
+		return c.Name
+	}
+	return item.Expr.String()
+}
+
+// Plan 拆分查询：WHERE 中能下推的条件写入请求，OR、NOT、列之间的比较、客户端未定义的运算符等
+// 留在客户端计算；SELECT 中的表达式和别名在客户端计算，所需的列都会下推到 DbFields。
+// 有 GROUP BY 或聚合函数时在客户端分组聚合，见 planAggregate。LIMIT 和 OFFSET 在客户端计算之后截取。
+// ORDER BY 只能按列（或重命名列的别名）下推，客户端计算的表达式和函数调用中不支持的写法返回 *UnsupportedError
+func (q *Query) Plan() (*Plan, error) {
+	pd := &Pushdown{From: q.From, Filters: filter.New()}
+	plan := &Plan{Pushdown: pd, Limit: q.Limit, Offset: q.Offset}
+	for _, e := range conjuncts(q.Where) {
+		c, err := condition(e)
+		if err == nil {
+			pd.Filters.Add(c.Field, c.Op, c.Values...)
+			continue
+		}
+		if err := checkLocal("WHERE", e); err != nil {
+			return nil, err
+		}
+		reason := err.Error()
+		var unsupportedErr *UnsupportedError
+		if errors.As(err, &unsupportedErr) {
+			reason = unsupportedErr.Reason
+		}
+		plan.Residual = append(plan.Residual, Residual{Expr: e, Reason: reason})
+	}
+
+	if q.aggregates() {
+		if err := q.planAggregate(plan); err != nil {
+			return nil, err
+		}
+	} else if len(q.Fields) > 0 {
+		plain := true
+		var selected []string
+		for _, item := range q.Fields {
+			if err := checkLocal("SELECT", item.Expr); err != nil {
+				return nil, err
+			}
+			column, ok := item.Expr.(*Column)
+			if !ok || (item.Alias != "" && item.Alias != column.Name) {
+				plain = false
+				continue
+			}
+			selected = append(selected, column.Name)
+		}
+		columns := newColumnSet()
+		for _, item := range q.Fields {
+			columns.add(item.Expr)
+		}
+		for _, r := range plan.Residual {
+			columns.add(r.Expr)
+		}
+		pd.DbFields = columns.names
+		if !plain || len(columns.names) != len(selected) {
+			plan.Project = q.Fields
+		}
+	}
+
+	for _, item := range q.OrderBy {
+		name, err := q.orderColumn(item)
+		if err != nil {
+			return nil, err
+		}
+		pd.SortRules = append(pd.SortRules, sortRule(name, item.Desc))
+	}
+	return plan, nil
+}
+
+// orderColumn 返回排序项对应的服务端列：SELECT 中的别名优先，解析为它重命名的列；
+// 别名代表客户端计算的表达式或聚合结果时服务端无法按它排序
+func (q *Query) orderColumn(item OrderItem) (string, error) {
+	column, ok := item.Expr.(*Column)
+	if !ok {
+		return "", unsupported("ORDER BY", item.Expr.String(), "only plain columns can be sorted on")
+	}
+	for _, field := range q.Fields {
+		if field.Alias != column.Name {
+			continue
+		}
+		if source, ok := field.Expr.(*Column); ok {
+			return source.Name, nil
+		}
+		return "", unsupported("ORDER BY", column.String(), fmt.Sprintf("%s is computed on the client as %s, only plain columns can be sorted on", quoteIdent(column.Name), field.Expr.String()))
+	}
+	return column.Name, nil
+}
+
+// Local 是否有需要在客户端计算的部分
+func (p *Plan) Local() bool {
+	return p.evaluates() || p.limits()
+}
+
+func (p *Plan) evaluates() bool {
+	return len(p.Residual) > 0 || len(p.Project) > 0 || p.Aggregate != nil
+}
+
+func (p *Plan) limits() bool {
+	return p.Limit >= 0 || p.Offset > 0
+}
+
+// Where 返回客户端过滤条件的合取，没有时为 nil
+func (p *Plan) Where() Expr {
+	var where Expr
+	for _, r := range p.Residual {
+		if where == nil {
+			where = r.Expr
+		} else {
+			where = &Binary{Op: "AND", Left: where, Right: r.Expr}
+		}
+	}
+	return where
+}
+
+// Evaluator 创建非聚合查询的客户端计算器，mem 为空时使用 GoAllocator
+func (p *Plan) Evaluator(mem memory.Allocator) *Evaluator {
+	return NewEvaluator(p.Where(), p.Project, mem)
+}
+
+// Wrap 在 src 上执行客户端部分，没有需要计算的部分时原样返回 src。依次为过滤、聚合、投影和 LIMIT，
+// 聚合查询读完 src 后输出一批结果；有 LIMIT 时取够行数后立即释放 src，src 为 ReadStream 等函数创建的迭代器时会取消数据流
+func (p *Plan) Wrap(src iterator.Records, mem memory.Allocator) iterator.Records {
+	switch {
+	case p.Aggregate != nil:
+		if len(p.Residual) > 0 {
+			src = NewReader(src, NewEvaluator(p.Where(), nil, mem))
+		}
+		src = aggregate.NewReader(src, p.Aggregate.GroupBy, p.Aggregate.Specs, mem)
+		if len(p.Project) > 0 {
+			src = NewReader(src, NewEvaluator(nil, p.Project, mem))
+		}
+	case p.evaluates():
+		src = NewReader(src, p.Evaluator(mem))
+	}
+	if p.limits() {
+		src = iterator.Limit(src, p.Offset, p.Limit)
+	}
+	return src
+}
+
+func (p *Plan) String() string {
+	var lines []string
+	pushed := []string{}
+	if len(p.Pushdown.DbFields) > 0 {
+		pushed = append(pushed, "fields "+strings.Join(p.Pushdown.DbFields, ", "))
+	}
+	for _, c := range p.Pushdown.Filters.Conditions() {
+		pushed = append(pushed, "filter "+c.String())
+	}
+	for _, rule := range p.Pushdown.SortRules {
+		order := "ASC"
+		if rule.SortOrder == pb.SortOrder_DESC {
+			order = "DESC"
+		}
+		pushed = append(pushed, fmt.Sprintf("sort %s %s", rule.FieldName, order))
+	}
+	if len(pushed) == 0 {
+		pushed = append(pushed, "nothing")
+	}
+	lines = append(lines, "pushed down: "+strings.Join(pushed, "; "))
+	for _, r := range p.Residual {
+		lines = append(lines, fmt.Sprintf("client filter: %v (%s)", r.Expr, r.Reason))
+	}
+	if p.Aggregate != nil {
+		lines = append(lines, "client aggregate: "+p.Aggregate.String())
+	}
+	if len(p.Project) > 0 {
+		items := make([]string, len(p.Project))
+		for i, item := range p.Project {
+			items[i] = item.Expr.String()
+			if item.Alias != "" {
+				items[i] += " AS " + quoteIdent(item.Alias)
+			}
+		}
+		lines = append(lines, "client projection: "+strings.Join(items, ", "))
+	}
+	if p.limits() {
+		window := "client limit: "
+		if p.Limit >= 0 {
+			window += fmt.Sprintf("first %d rows", p.Limit)
+		} else {
+			window += "all rows"
+		}
+		if p.Offset > 0 {
+			window += fmt.Sprintf(" after skipping %d", p.Offset)
+		}
+		lines = append(lines, window)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkLocal 检查表达式能否在客户端计算：不支持函数调用和取模，LIKE 的模式必须是字符串字面量
+func checkLocal(clause string, e Expr) error {
+	var err error
+	walk(e, func(x Expr) bool {
+		switch x := x.(type) {
+		case *Call:
+			if isAggregate(x) {
+				err = unsupported(clause, x.String(), fmt.Sprintf("aggregate functions are not allowed in %s", clause))
+			} else {
+				err = unsupported(clause, x.String(), fmt.Sprintf("function %s is not supported", x.Name))
+			}
+		case *Binary:
+			if x.Op == "%" {
+				err = unsupported(clause, x.String(), "the % operator is not supported")
+			}
+		case *Like:
+			if literal, ok := x.Pattern.(*Literal); !ok {
+				err = unsupported(clause, x.String(), "LIKE pattern must be a string literal")
+			} else if _, ok := literal.Value.(string); !ok {
+				err = unsupported(clause, x.String(), "LIKE pattern must be a string literal")
+			}
+		}
+		return err == nil
+	})
+	return err
+}
+
+// walk 先序遍历表达式，fn 返回 false 时停止
+func walk(e Expr, fn func(Expr) bool) bool {
+	if e == nil {
+		return true
+	}
+	if !fn(e) {
+		return false
+	}
+	var children []Expr
+	switch x := e.(type) {
+	case *Binary:
+		children = []Expr{x.Left, x.Right}
+	case *Unary:
+		children = []Expr{x.X}
+	case *In:
+		children = append([]Expr{x.X}, x.Values...)
+	case *Between:
+		children = []Expr{x.X, x.Lo, x.Hi}
+	case *Like:
+		children = []Expr{x.X, x.Pattern}
+	case *IsNull:
+		children = []Expr{x.X}
+	case *Call:
+		children = x.Args
+	}
+	for _, child := range children {
+		if !walk(child, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// columnSet 按首次出现的顺序收集表达式引用的列
+type columnSet struct {
+	names []string
+	seen  map[string]bool
+}
+
+func newColumnSet() *columnSet {
+	return &columnSet{seen: make(map[string]bool)}
+}
+
+func (s *columnSet) add(e Expr) {
+	walk(e, func(x Expr) bool {
+		if c, ok := x.(*Column); ok && !s.seen[c.Name] {
+			s.seen[c.Name] = true
+			s.names = append(s.names, c.Name)
+		}
+		return true
+	})
+}
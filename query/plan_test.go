@@ -0,0 +1,331 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+)
+
+// sortRules 将 SortRules 格式化为 "col ASC" 的形式
+func sortRules(rules []*pb.SortRule) []string {
+	var out []string
+	for _, rule := range rules {
+		order := "ASC"
+		if rule.SortOrder == pb.SortOrder_DESC {
+			order = "DESC"
+		}
+		out = append(out, rule.FieldName+" "+order)
+	}
+	return out
+}
+
+// checkUnsupported 检查 err 是 Clause 为 clause 的 *UnsupportedError
+func checkUnsupported(t *testing.T, err error, clause string) {
+	t.Helper()
+	var unsupportedErr *UnsupportedError
+	if !errors.As(err, &unsupportedErr) {
+		t.Fatalf("error %v (%T), want *UnsupportedError", err, err)
+	}
+	if unsupportedErr.Clause != clause {
+		t.Errorf("Clause = %q, want %q (%v)", unsupportedErr.Clause, clause, err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		fields   []string
+		filters  []string // 下推的条件
+		residual []string // 客户端计算的条件
+		sorts    []string
+		project  []string // 客户端投影的列名，nil 表示不投影
+		groupBy  []string
+		aggs     []string
+		err      string // 期望的 UnsupportedError.Clause
+	}{
+		{
+			name:     "split where",
+			sql:      "SELECT name, gpa FROM students WHERE name IN ('Alice', 'Bob') AND score >= 60 ORDER BY gpa DESC",
+			fields:   []string{"name", "gpa", "score"},
+			filters:  []string{`name IN ("Alice", "Bob")`},
+			residual: []string{"score >= 60"},
+			sorts:    []string{"gpa DESC"},
+			project:  []string{"name", "gpa"},
+		},
+		{
+			name:     "mirrored comparison",
+			sql:      "SELECT name FROM t WHERE 60 <= score",
+			fields:   []string{"name", "score"},
+			residual: []string{"60 <= score"},
+			project:  []string{"name"},
+		},
+		{
+			name:     "or and null comparison stay on the client",
+			sql:      "FROM t WHERE (a = 1 OR b = 2) AND c = NULL AND d NOT IN (1, 2)",
+			residual: []string{"a = 1 OR b = 2", "c = NULL", "d NOT IN (1, 2)"},
+		},
+		{
+			name:   "plain columns",
+			sql:    "SELECT a, b FROM t ORDER BY b, a DESC",
+			fields: []string{"a", "b"},
+			sorts:  []string{"b ASC", "a DESC"},
+		},
+		{
+			name:    "computed projection",
+			sql:     "SELECT name, gpa * 2 AS double FROM t",
+			fields:  []string{"name", "gpa"},
+			project: []string{"name", "double"},
+		},
+		{
+			name:    "order by alias of a column",
+			sql:     "SELECT name AS n FROM t ORDER BY n DESC",
+			fields:  []string{"name"},
+			sorts:   []string{"name DESC"},
+			project: []string{"n"},
+		},
+		{
+			name:    "alias shadows a column",
+			sql:     "SELECT a AS b, b AS a FROM t ORDER BY a",
+			fields:  []string{"a", "b"},
+			sorts:   []string{"b ASC"},
+			project: []string{"b", "a"},
+		},
+		{
+			name: "order by alias of an expression",
+			sql:  "SELECT gpa * 2 AS g FROM t ORDER BY g",
+			err:  "ORDER BY",
+		},
+		{
+			name: "order by expression",
+			sql:  "FROM t ORDER BY a + 1",
+			err:  "ORDER BY",
+		},
+		{
+			name: "function call",
+			sql:  "SELECT UPPER(name) FROM t",
+			err:  "SELECT",
+		},
+		{
+			name: "modulo",
+			sql:  "FROM t WHERE a % 2 = 0",
+			err:  "WHERE",
+		},
+		{
+			name:    "aggregate",
+			sql:     "SELECT dept, COUNT(*) AS n, AVG(gpa) FROM t WHERE dept IN ('math') GROUP BY dept ORDER BY dept",
+			fields:  []string{"dept", "gpa"},
+			filters: []string{`dept IN ("math")`},
+			sorts:   []string{"dept ASC"},
+			groupBy: []string{"dept"},
+			aggs:    []string{"n", "AVG(gpa)"},
+		},
+		{
+			name:    "aggregate reordered",
+			sql:     "SELECT COUNT(*) AS n, dept AS d FROM t GROUP BY dept ORDER BY d",
+			fields:  []string{"dept"},
+			sorts:   []string{"dept ASC"},
+			groupBy: []string{"dept"},
+			aggs:    []string{"n"},
+			project: []string{"n", "d"},
+		},
+		{
+			name:   "aggregate without group by",
+			sql:    "SELECT COUNT(DISTINCT name), SUM(score) FROM t",
+			fields: []string{"name", "score"},
+			aggs:   []string{"COUNT(DISTINCT name)", "SUM(score)"},
+		},
+		{
+			name: "column outside group by",
+			sql:  "SELECT name, COUNT(*) FROM t GROUP BY dept",
+			err:  "SELECT",
+		},
+		{
+			name: "order by aggregate",
+			sql:  "SELECT dept, COUNT(*) AS c FROM t GROUP BY dept ORDER BY c",
+			err:  "ORDER BY",
+		},
+		{
+			name: "aggregate in where",
+			sql:  "FROM t WHERE COUNT(*) > 1",
+			err:  "WHERE",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Parse(tt.sql)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			plan, err := q.Plan()
+			if tt.err != "" {
+				checkUnsupported(t, err, tt.err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Plan: %v", err)
+			}
+			var filters, residual, project []string
+			for _, c := range plan.Pushdown.Filters.Conditions() {
+				filters = append(filters, c.String())
+			}
+			for _, r := range plan.Residual {
+				residual = append(residual, r.Expr.String())
+				if r.Reason == "" {
+					t.Errorf("residual %v has no reason", r.Expr)
+				}
+			}
+			for _, item := range plan.Project {
+				project = append(project, item.Name())
+			}
+			check := func(what string, got, want []string) {
+				t.Helper()
+				if len(got) != 0 || len(want) != 0 {
+					if !reflect.DeepEqual(got, want) {
+						t.Errorf("%s = %q, want %q", what, got, want)
+					}
+				}
+			}
+			check("DbFields", plan.Pushdown.DbFields, tt.fields)
+			check("filters", filters, tt.filters)
+			check("residual", residual, tt.residual)
+			check("sort rules", sortRules(plan.Pushdown.SortRules), tt.sorts)
+			check("project", project, tt.project)
+			if plan.Aggregate == nil {
+				if tt.groupBy != nil || tt.aggs != nil {
+					t.Fatal("Aggregate is nil")
+				}
+				return
+			}
+			var aggs []string
+			for _, spec := range plan.Aggregate.Specs {
+				aggs = append(aggs, spec.Name)
+			}
+			check("GroupBy", plan.Aggregate.GroupBy, tt.groupBy)
+			check("aggregates", aggs, tt.aggs)
+		})
+	}
+}
+
+func TestPlanLimit(t *testing.T) {
+	q, err := Parse("SELECT a FROM t LIMIT 5 OFFSET 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := q.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Limit != 5 || plan.Offset != 2 {
+		t.Errorf("Limit, Offset = %d, %d, want 5, 2", plan.Limit, plan.Offset)
+	}
+	if !plan.Local() {
+		t.Error("Local() = false, LIMIT runs on the client")
+	}
+	if !strings.Contains(plan.String(), "client limit: first 5 rows after skipping 2") {
+		t.Errorf("String() = %q", plan.String())
+	}
+}
+
+func TestPushdown(t *testing.T) {
+	tests := []struct {
+		sql    string
+		fields []string
+		sorts  []string
+		err    string
+	}{
+		{sql: "SELECT name, gpa FROM students WHERE name IN ('Alice') ORDER BY gpa DESC", fields: []string{"name", "gpa"}, sorts: []string{"gpa DESC"}},
+		{sql: "SELECT name AS name FROM t ORDER BY name", fields: []string{"name"}, sorts: []string{"name ASC"}},
+		{sql: "FROM t WHERE score >= 60", err: "WHERE"},
+		{sql: "FROM t WHERE 60 <= score", err: "WHERE"},
+		{sql: "FROM t WHERE a = 1 OR b = 2", err: "WHERE"},
+		{sql: "SELECT a AS b FROM t", err: "SELECT"},
+		{sql: "SELECT a + 1 FROM t", err: "SELECT"},
+		{sql: "SELECT COUNT(*) FROM t", err: "SELECT"},
+		{sql: "SELECT a FROM t GROUP BY a", err: "GROUP BY"},
+		{sql: "FROM t LIMIT 1", err: "LIMIT"},
+		{sql: "FROM t OFFSET 1", err: "OFFSET"},
+		{sql: "FROM t ORDER BY a * 2", err: "ORDER BY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			q, err := Parse(tt.sql)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			pd, err := q.Pushdown()
+			if tt.err != "" {
+				checkUnsupported(t, err, tt.err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Pushdown: %v", err)
+			}
+			if !reflect.DeepEqual(pd.DbFields, tt.fields) {
+				t.Errorf("DbFields = %q, want %q", pd.DbFields, tt.fields)
+			}
+			if got := sortRules(pd.SortRules); !reflect.DeepEqual(got, tt.sorts) {
+				t.Errorf("SortRules = %q, want %q", got, tt.sorts)
+			}
+		})
+	}
+}
+
+func TestApplyStream(t *testing.T) {
+	q, err := Parse("SELECT name FROM students WHERE name IN ('Alice', 'Bob') ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &pb.StreamReadRequest{
+		FilterNames:     []string{"dept"},
+		FilterOperators: []pb.FilterOperator{pb.FilterOperator_IN_OPERATOR},
+		FilterValues:    []*pb.FilterValue{{StrValues: []string{"math"}}},
+	}
+	if err := q.ApplyStream(request); err != nil {
+		t.Fatal(err)
+	}
+	if request.AssetName != "students" {
+		t.Errorf("AssetName = %q, want students", request.AssetName)
+	}
+	if !reflect.DeepEqual(request.FilterNames, []string{"dept", "name"}) {
+		t.Errorf("FilterNames = %q", request.FilterNames)
+	}
+	if got := request.FilterValues[1].StrValues; !reflect.DeepEqual(got, []string{"Alice", "Bob"}) {
+		t.Errorf("FilterValues[1] = %q", got)
+	}
+	if !reflect.DeepEqual(sortRules(request.SortRules), []string{"name ASC"}) {
+		t.Errorf("SortRules = %q", sortRules(request.SortRules))
+	}
+
+	// 无法下推时不修改请求
+	other := &pb.StreamReadRequest{AssetName: "other"}
+	if err := q.ApplyStream(other); err == nil {
+		t.Error("ApplyStream to a different asset succeeded")
+	}
+	if other.AssetName != "other" || len(other.FilterNames) != 0 {
+		t.Errorf("request modified on error: %+v", other)
+	}
+}
+
+func TestApplyInternal(t *testing.T) {
+	q, err := Parse("SELECT id FROM mira.students WHERE id IN (1, 2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &pb.InternalReadRequest{}
+	if err := q.ApplyInternal(request); err != nil {
+		t.Fatal(err)
+	}
+	if request.DbName != "mira" || request.TableName != "students" {
+		t.Errorf("DbName, TableName = %q, %q", request.DbName, request.TableName)
+	}
+	if got := request.FilterValues[0].FloatValues; !reflect.DeepEqual(got, []float64{1, 2}) {
+		t.Errorf("FilterValues[0] = %v", got)
+	}
+	if err := q.ApplyInternal(&pb.InternalReadRequest{TableName: "teachers"}); err == nil {
+		t.Error("ApplyInternal to a different table succeeded")
+	}
+}
@@ -0,0 +1,395 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/24
+	@note: 类 SQL 查询解析，将 SELECT/WHERE/ORDER BY 下推为读取请求的 DbFields、过滤条件和 SortRules
+
+*
+*/
+package query
+
+import (
+	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
+	"errors"
+	"fmt"
+	"strings"
+	"test/filter"
+)
+
+// Query 解析后的查询
+//
+//	SELECT name, gpa FROM students WHERE name IN ('Alice') AND score >= 60 ORDER BY gpa DESC LIMIT 10
+//
+// 关键字不区分大小写，字符串使用单引号，列名与关键字冲突时使用双引号或反引号
+type Query struct {
+	Fields  []SelectItem // 为空表示 SELECT * 或省略了 SELECT
+	From    string
+	Where   Expr // 没有 WHERE 时为 nil
+	GroupBy []Expr
+	OrderBy []OrderItem
+	Limit   int64 // 小于 0 表示没有 LIMIT，Parse 默认设为 -1
+	Offset  int64
+}
+
+// SelectItem 选择的列或表达式
+type SelectItem struct {
+	Expr  Expr
+	Alias string
+}
+
+// OrderItem 排序项
+type OrderItem struct {
+	Expr Expr
+	Desc bool
+}
+
+// SyntaxError 语法错误，Pos 为出错位置在语句中的字节偏移
+type SyntaxError struct {
+	Pos  int
+	Near string
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Near == "" {
+		return fmt.Sprintf("syntax error at offset %d: %s", e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("syntax error at offset %d near %s: %s", e.Pos, e.Near, e.Msg)
+}
+
+// UnsupportedError 语法正确但数据服务无法执行的写法
+type UnsupportedError struct {
+	Clause string // SELECT、WHERE、ORDER BY 等
+	Expr   string
+	Reason string
+}
+
+func (e *UnsupportedError) Error() string {
+	if e.Expr == "" {
+		return fmt.Sprintf("%s cannot be pushed down: %s", e.Clause, e.Reason)
+	}
+	return fmt.Sprintf("%s %s cannot be pushed down: %s", e.Clause, e.Expr, e.Reason)
+}
+
+func unsupported(clause, expr, reason string) error {
+	return &UnsupportedError{Clause: clause, Expr: expr, Reason: reason}
+}
+
+// Parse 解析查询语句
+func Parse(sql string) (*Query, error) {
+	tokens, err := lex(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	return p.parseQuery()
+}
+
+func (q *Query) String() string {
+	var parts []string
+	if len(q.Fields) > 0 {
+		fields := make([]string, len(q.Fields))
+		for i, item := range q.Fields {
+			fields[i] = item.Expr.String()
+			if item.Alias != "" {
+				fields[i] += " AS " + quoteIdent(item.Alias)
+			}
+		}
+		parts = append(parts, "SELECT "+strings.Join(fields, ", "))
+	} else {
+		parts = append(parts, "SELECT *")
+	}
+	if q.From != "" {
+		parts = append(parts, "FROM "+quoteIdent(q.From))
+	}
+	if q.Where != nil {
+		parts = append(parts, "WHERE "+q.Where.String())
+	}
+	if len(q.GroupBy) > 0 {
+		groups := make([]string, len(q.GroupBy))
+		for i, e := range q.GroupBy {
+			groups[i] = e.String()
+		}
+		parts = append(parts, "GROUP BY "+strings.Join(groups, ", "))
+	}
+	if len(q.OrderBy) > 0 {
+		orders := make([]string, len(q.OrderBy))
+		for i, item := range q.OrderBy {
+			orders[i] = item.Expr.String()
+			if item.Desc {
+				orders[i] += " DESC"
+			}
+		}
+		parts = append(parts, "ORDER BY "+strings.Join(orders, ", "))
+	}
+	if q.Limit >= 0 {
+		parts = append(parts, fmt.Sprintf("LIMIT %d", q.Limit))
+	}
+	if q.Offset > 0 {
+		parts = append(parts, fmt.Sprintf("OFFSET %d", q.Offset))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Pushdown 转换后的请求字段
+type Pushdown struct {
+	From      string // FROM 子句，为空时沿用请求中的数据源
+	DbFields  []string
+	SortRules []*pb.SortRule
+	Filters   *filter.Builder
+}
+
+// Pushdown 将查询转换为请求字段：SELECT 只能是列名，WHERE 只能是 AND 连接的「列 运算符 字面量」条件
+// 且运算符在客户端的 FilterOperator 中有定义，ORDER BY 只能是列名，不能有聚合、LIMIT 和 OFFSET；
+// 其他写法返回 *UnsupportedError
+func (q *Query) Pushdown() (*Pushdown, error) {
+	if q.aggregates() {
+		clause := "SELECT"
+		if len(q.GroupBy) > 0 {
+			clause = "GROUP BY"
+		}
+		return nil, unsupported(clause, "", "aggregation is not performed by the data service, use Plan to aggregate on the client")
+	}
+	if q.Limit >= 0 {
+		return nil, unsupported("LIMIT", "", "the data service always returns every matching row, use Plan to apply it on the client")
+	}
+	if q.Offset > 0 {
+		return nil, unsupported("OFFSET", "", "the data service always returns every matching row, use Plan to apply it on the client")
+	}
+	pd := &Pushdown{From: q.From, Filters: filter.New()}
+	for _, item := range q.Fields {
+		column, ok := item.Expr.(*Column)
+		if !ok {
+			return nil, unsupported("SELECT", item.Expr.String(), "only plain columns can be selected")
+		}
+		if item.Alias != "" && item.Alias != column.Name {
+			return nil, unsupported("SELECT", column.String()+" AS "+quoteIdent(item.Alias), "the data service cannot rename columns")
+		}
+		pd.DbFields = append(pd.DbFields, column.Name)
+	}
+	for _, e := range conjuncts(q.Where) {
+		c, err := condition(e)
+		if err != nil {
+			return nil, err
+		}
+		pd.Filters.Add(c.Field, c.Op, c.Values...)
+	}
+	for _, item := range q.OrderBy {
+		name, err := q.orderColumn(item)
+		if err != nil {
+			return nil, err
+		}
+		pd.SortRules = append(pd.SortRules, sortRule(name, item.Desc))
+	}
+	return pd, nil
+}
+
+func sortRule(field string, desc bool) *pb.SortRule {
+	rule := &pb.SortRule{FieldName: field, SortOrder: pb.SortOrder_ASC}
+	if desc {
+		rule.SortOrder = pb.SortOrder_DESC
+	}
+	return rule
+}
+
+// ApplyStream 将查询下推到 StreamReadRequest，无法下推时返回 *UnsupportedError 且不修改请求
+func (q *Query) ApplyStream(request *pb.StreamReadRequest) error {
+	pd, err := q.Pushdown()
+	if err != nil {
+		return err
+	}
+	return pd.ApplyStream(request)
+}
+
+// ApplyInternal 将查询下推到 InternalReadRequest，无法下推时返回 *UnsupportedError 且不修改请求
+func (q *Query) ApplyInternal(request *pb.InternalReadRequest) error {
+	pd, err := q.Pushdown()
+	if err != nil {
+		return err
+	}
+	return pd.ApplyInternal(request)
+}
+
+// ApplyStream 写入 StreamReadRequest：指定了列和排序时覆盖 DbFields、SortRules，
+// 过滤条件追加在已有条件之后，From 用作 AssetName；出错时不修改请求
+func (pd *Pushdown) ApplyStream(request *pb.StreamReadRequest) error {
+	if request == nil {
+		return errors.New("nil StreamReadRequest")
+	}
+	if pd.From != "" && request.AssetName != "" && request.AssetName != pd.From {
+		return fmt.Errorf("query reads from %s but the request asset is %s", pd.From, request.AssetName)
+	}
+	if err := pd.Filters.ApplyStream(request); err != nil {
+		return err
+	}
+	if pd.From != "" {
+		request.AssetName = pd.From
+	}
+	if len(pd.DbFields) > 0 {
+		request.DbFields = pd.DbFields
+	}
+	if len(pd.SortRules) > 0 {
+		request.SortRules = pd.SortRules
+	}
+	return nil
+}
+
+// ApplyInternal 写入 InternalReadRequest，From 可以是 table 或 db.table，其余同 ApplyStream
+func (pd *Pushdown) ApplyInternal(request *pb.InternalReadRequest) error {
+	if request == nil {
+		return errors.New("nil InternalReadRequest")
+	}
+	db, table := "", pd.From
+	if i := strings.LastIndex(pd.From, "."); i >= 0 {
+		db, table = pd.From[:i], pd.From[i+1:]
+	}
+	if db != "" && request.DbName != "" && request.DbName != db {
+		return fmt.Errorf("query reads from database %s but the request database is %s", db, request.DbName)
+	}
+	if table != "" && request.TableName != "" && request.TableName != table {
+		return fmt.Errorf("query reads from table %s but the request table is %s", table, request.TableName)
+	}
+	if err := pd.Filters.ApplyInternal(request); err != nil {
+		return err
+	}
+	if db != "" {
+		request.DbName = db
+	}
+	if table != "" {
+		request.TableName = table
+	}
+	if len(pd.DbFields) > 0 {
+		request.DbFields = pd.DbFields
+	}
+	if len(pd.SortRules) > 0 {
+		request.SortRules = pd.SortRules
+	}
+	return nil
+}
+
+// condition 将单个 WHERE 条件转换为 filter.Condition
+func condition(e Expr) (filter.Condition, error) {
+	var (
+		field  string
+		op     filter.Op
+		values []any
+		err    error
+	)
+	switch e := e.(type) {
+	case *Binary:
+		cmp, ok := comparisonOps[e.Op]
+		if e.Op == "OR" {
+			return filter.Condition{}, unsupported("WHERE", e.String(), "OR cannot be pushed down, only AND-ed conditions are sent to the data service")
+		}
+		if !ok {
+			return filter.Condition{}, unsupported("WHERE", e.String(), "arithmetic cannot be pushed down")
+		}
+		column, literal, swapped := columnLiteral(e.Left, e.Right)
+		if column == nil {
+			return filter.Condition{}, unsupported("WHERE", e.String(), "comparisons must be between a column and a literal")
+		}
+		if swapped {
+			cmp = mirrored[cmp]
+		}
+		field, op = column.Name, cmp
+		values, err = literalValues(e, literal)
+	case *In:
+		column, ok := e.X.(*Column)
+		if !ok {
+			return filter.Condition{}, unsupported("WHERE", e.String(), "IN must be applied to a column")
+		}
+		field, op = column.Name, filter.OpIn
+		if e.Not {
+			op = filter.OpNotIn
+		}
+		values, err = literalValues(e, e.Values...)
+	case *Between:
+		column, ok := e.X.(*Column)
+		switch {
+		case e.Not:
+			return filter.Condition{}, unsupported("WHERE", e.String(), "NOT BETWEEN has no filter operator")
+		case !ok:
+			return filter.Condition{}, unsupported("WHERE", e.String(), "BETWEEN must be applied to a column")
+		}
+		field, op = column.Name, filter.OpBetween
+		values, err = literalValues(e, e.Lo, e.Hi)
+	case *Like:
+		column, ok := e.X.(*Column)
+		switch {
+		case e.Not:
+			return filter.Condition{}, unsupported("WHERE", e.String(), "NOT LIKE has no filter operator")
+		case !ok:
+			return filter.Condition{}, unsupported("WHERE", e.String(), "LIKE must be applied to a column")
+		}
+		field, op = column.Name, filter.OpLike
+		values, err = literalValues(e, e.Pattern)
+	case *IsNull:
+		column, ok := e.X.(*Column)
+		if !ok {
+			return filter.Condition{}, unsupported("WHERE", e.String(), "IS NULL must be applied to a column")
+		}
+		field, op = column.Name, filter.OpIsNull
+		if e.Not {
+			op = filter.OpIsNotNull
+		}
+	case *Unary:
+		return filter.Condition{}, unsupported("WHERE", e.String(), "NOT cannot be pushed down")
+	default:
+		return filter.Condition{}, unsupported("WHERE", e.String(), "not a filter condition")
+	}
+	if err != nil {
+		return filter.Condition{}, err
+	}
+	c, err := filter.NewCondition(field, op, values...)
+	if err != nil {
+		return filter.Condition{}, fmt.Errorf("WHERE %v: %v", e, err)
+	}
+	if _, err := op.Proto(); err != nil {
+		return filter.Condition{}, unsupported("WHERE", e.String(), err.Error())
+	}
+	return c, nil
+}
+
+// comparisonOps 比较运算符对应的过滤运算符
+var comparisonOps = map[string]filter.Op{
+	"=": filter.OpEq, "!=": filter.OpNe, "<": filter.OpLt, "<=": filter.OpLe, ">": filter.OpGt, ">=": filter.OpGe,
+}
+
+// mirrored 交换左右两边后的运算符，用于 60 <= score 这类写法
+var mirrored = map[filter.Op]filter.Op{
+	filter.OpEq: filter.OpEq, filter.OpNe: filter.OpNe, filter.OpLt: filter.OpGt, filter.OpLe: filter.OpGe, filter.OpGt: filter.OpLt, filter.OpGe: filter.OpLe,
+}
+
+// columnLiteral 返回比较两边的列和另一边，列在右边时 swapped 为 true
+func columnLiteral(left, right Expr) (column *Column, other Expr, swapped bool) {
+	if c, ok := left.(*Column); ok {
+		if _, ok := right.(*Column); !ok {
+			return c, right, false
+		}
+		return nil, nil, false
+	}
+	if c, ok := right.(*Column); ok {
+		return c, left, true
+	}
+	return nil, nil, false
+}
+
+// literalValues 取出字面量的值，NULL 和布尔值没有对应的 FilterValue
+func literalValues(e Expr, exprs ...Expr) ([]any, error) {
+	values := make([]any, 0, len(exprs))
+	for _, x := range exprs {
+		literal, ok := x.(*Literal)
+		if !ok {
+			return nil, unsupported("WHERE", e.String(), fmt.Sprintf("%v is not a literal", x))
+		}
+		switch literal.Value.(type) {
+		case nil:
+			return nil, unsupported("WHERE", e.String(), "comparing with NULL never matches, use IS NULL or IS NOT NULL")
+		case bool:
+			return nil, unsupported("WHERE", e.String(), "boolean values cannot be sent as filter values")
+		}
+		values = append(values, literal.Value)
+	}
+	return values, nil
+}
@@ -10,22 +10,21 @@
 package main
 
 import (
-	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
 	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
 	"context"
+	"flag"
 	"log"
+	"test/config"
 )
 
 func main() {
 	ctx := context.Background()
 
-	// 创建一个ServerInfo实例
-	serverInfo := &pb.ServerInfo{
-		//Namespace:   "mira1", // 如果在Kubernetes集群中使用，可以指定命名空间
-		ServiceName: "192.168.40.243",
-		ServicePort: "30015",
-	}
-	dataServiceClient, err := client.NewDataServiceClient(ctx, serverInfo)
+	// 解析连接参数，通过 --profile 切换环境
+	opts := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
 	if err != nil {
 		log.Fatalf("failed to initialize DataServiceClient: %v", err)
 	}
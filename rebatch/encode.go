@@ -0,0 +1,108 @@
+package rebatch
+
+import (
+	"context"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"test/iterator"
+	"test/utils"
+)
+
+// Split 序列化记录，超过 maxBytes 时按估算的行数拆分，直到每个批次都不超过上限
+func Split(record arrow.Record, maxBytes int, emit func(rows int64, chunk []byte) error, opts ...ipc.Option) error {
+	rows := record.NumRows()
+	if rows == 0 {
+		return nil
+	}
+	chunk, err := utils.SerializeRecord(record, opts...)
+	if err != nil {
+		return err
+	}
+	if len(chunk) <= maxBytes {
+		return emit(rows, chunk)
+	}
+	if rows == 1 {
+		return fmt.Errorf("a single row serializes to %d bytes, exceeding the %d byte chunk limit", len(chunk), maxBytes)
+	}
+
+	// 按平均行大小估算，留出 10% 的余量给 schema 和对齐
+	step := int64(float64(rows) * float64(maxBytes) / float64(len(chunk)) * 0.9)
+	if step < 1 {
+		step = 1
+	} else if step >= rows {
+		step = rows / 2
+	}
+	for start := int64(0); start < rows; start += step {
+		end := min(start+step, rows)
+		slice := record.NewSlice(start, end)
+		err := Split(slice, maxBytes, emit, opts...)
+		slice.Release()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyOptions Copy 的参数
+type CopyOptions struct {
+	Options
+	MaxChunkBytes int               // 序列化后单个批次的上限，默认 DefaultMaxChunkBytes
+	Compression   utils.Compression // IPC 消息体的压缩方式
+}
+
+// CopyResult Copy 的统计
+type CopyResult struct {
+	InputBatches int
+	Batches      int
+	Rows         int64
+	Bytes        int64
+	MinBatchRows int64
+	MaxBatchRows int64
+	Compression  utils.CompressionStats // 仅在指定压缩方式时统计
+}
+
+// Copy 读取 src 中的全部记录，调整为目标大小后逐批序列化为一段完整的 IPC stream 并调用 write，
+// 最后释放 src
+func Copy(ctx context.Context, src iterator.Records, write func(chunk []byte) error, opts CopyOptions) (*CopyResult, error) {
+	if opts.MaxChunkBytes <= 0 {
+		opts.MaxChunkBytes = DefaultMaxChunkBytes
+	}
+	reader := New(src, opts.Options)
+	defer reader.Release()
+
+	result := &CopyResult{}
+	emit := func(rows int64, chunk []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := write(chunk); err != nil {
+			return fmt.Errorf("failed to write batch %d: %w", result.Batches+1, err)
+		}
+		if result.Batches == 0 || rows < result.MinBatchRows {
+			result.MinBatchRows = rows
+		}
+		result.MaxBatchRows = max(result.MaxBatchRows, rows)
+		result.Batches++
+		result.Rows += rows
+		result.Bytes += int64(len(chunk))
+		return nil
+	}
+	ipcOpts := opts.Compression.IPCOptions()
+	for reader.Next() {
+		record := reader.Record()
+		written := result.Bytes
+		if err := Split(record, opts.MaxChunkBytes, emit, ipcOpts...); err != nil {
+			result.InputBatches = reader.InputBatches()
+			return result, err
+		}
+		if opts.Compression != utils.CompressionNone {
+			if err := result.Compression.AddRecord(record, result.Bytes-written); err != nil {
+				return result, err
+			}
+		}
+	}
+	result.InputBatches = reader.InputBatches()
+	return result, reader.Err()
+}
@@ -0,0 +1,228 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/19
+	@note: 将记录流合并或拆分为目标行数、字节数的批次
+
+*
+*/
+package rebatch
+
+import (
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/apache/arrow/go/v15/arrow/util"
+	"math"
+	"test/iterator"
+)
+
+const (
+	// DefaultTargetBytes 未指定目标大小时每批的字节数
+	DefaultTargetBytes = 1 << 20
+	// DefaultMaxChunkBytes 序列化后单个批次的上限，低于 gRPC 默认的 4MB 消息大小
+	DefaultMaxChunkBytes = 3 << 20
+)
+
+// Options 目标批次大小，两者都指定时以先达到的为准
+type Options struct {
+	TargetRows  int64 // 每批的行数上限，0 表示不限制
+	TargetBytes int64 // 每批数据的字节数上限（按输入批次的平均行大小估算），两者都为 0 时使用 DefaultTargetBytes
+	Allocator   memory.Allocator
+}
+
+// Reader 将 src 中的记录合并或拆分为目标大小的批次，满足 iterator.Records
+//
+// 小批次会被拼接，大批次会被切片；schema 变化时先输出已缓存的行，不会跨 schema 合并。
+type Reader struct {
+	src  iterator.Records
+	opts Options
+
+	input    arrow.Record // 尚未取完的输入批次
+	offset   int64        // input 中已取出的行数
+	rowBytes float64      // input 的平均行大小
+
+	pending      []arrow.Record
+	pendingRows  int64
+	pendingBytes float64
+
+	record arrow.Record
+	err    error
+	done   bool
+
+	inputBatches int
+	inputRows    int64
+}
+
+// New 创建 Reader，Release 时同时释放 src
+func New(src iterator.Records, opts Options) *Reader {
+	if opts.TargetRows <= 0 && opts.TargetBytes <= 0 {
+		opts.TargetBytes = DefaultTargetBytes
+	}
+	if opts.Allocator == nil {
+		opts.Allocator = memory.NewGoAllocator()
+	}
+	return &Reader{src: src, opts: opts}
+}
+
+// Next 返回下一批记录
+func (r *Reader) Next() bool {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	for !r.done {
+		if r.input == nil && !r.nextInput() {
+			break
+		}
+		if len(r.pending) > 0 && !r.pending[0].Schema().Equal(r.input.Schema()) {
+			return r.flush()
+		}
+		n := min(r.space(), r.input.NumRows()-r.offset)
+		if n == 0 {
+			return r.flush()
+		}
+		r.pending = append(r.pending, r.input.NewSlice(r.offset, r.offset+n))
+		r.pendingRows += n
+		r.pendingBytes += float64(n) * r.rowBytes
+		r.offset += n
+		if r.offset == r.input.NumRows() {
+			r.input.Release()
+			r.input = nil
+		}
+	}
+	if len(r.pending) > 0 && r.err == nil {
+		return r.flush()
+	}
+	r.Release()
+	return false
+}
+
+// nextInput 读取下一个非空的输入批次，输入结束或出错时返回 false
+func (r *Reader) nextInput() bool {
+	for r.src.Next() {
+		record := r.src.Record()
+		r.inputBatches++
+		if record.NumRows() == 0 {
+			continue
+		}
+		record.Retain()
+		r.input = record
+		r.offset = 0
+		r.rowBytes = float64(util.TotalRecordSize(record)) / float64(record.NumRows())
+		r.inputRows += record.NumRows()
+		return true
+	}
+	r.err = r.src.Err()
+	r.done = true
+	return false
+}
+
+// space 当前批次还能容纳的输入行数，空批次至少容纳一行
+func (r *Reader) space() int64 {
+	rows := int64(math.MaxInt64)
+	if r.opts.TargetRows > 0 {
+		rows = r.opts.TargetRows - r.pendingRows
+	}
+	if r.opts.TargetBytes > 0 && r.rowBytes > 0 {
+		rows = min(rows, int64((float64(r.opts.TargetBytes)-r.pendingBytes)/r.rowBytes))
+	}
+	if r.pendingRows == 0 {
+		rows = max(rows, 1)
+	}
+	return max(rows, 0)
+}
+
+// flush 将缓存的切片拼接为一批作为当前记录
+func (r *Reader) flush() bool {
+	record, err := Concat(r.pending[0].Schema(), r.pending, r.opts.Allocator)
+	for _, piece := range r.pending {
+		piece.Release()
+	}
+	r.pending = r.pending[:0]
+	r.pendingRows = 0
+	r.pendingBytes = 0
+	if err != nil {
+		r.err = err
+		r.Release()
+		return false
+	}
+	r.record = record
+	return true
+}
+
+// Record 返回当前批次
+func (r *Reader) Record() arrow.Record {
+	return r.record
+}
+
+// Err 返回读取或拼接过程中的错误
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// InputBatches 返回已读取的输入批次数
+func (r *Reader) InputBatches() int {
+	return r.inputBatches
+}
+
+// InputRows 返回已读取的输入行数
+func (r *Reader) InputRows() int64 {
+	return r.inputRows
+}
+
+// Release 释放缓存的记录和 src
+func (r *Reader) Release() {
+	r.done = true
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	if r.input != nil {
+		r.input.Release()
+		r.input = nil
+	}
+	for _, piece := range r.pending {
+		piece.Release()
+	}
+	r.pending = nil
+	// Next 结束时已经调用过 Release，src 只释放一次
+	if r.src != nil {
+		r.src.Release()
+		r.src = nil
+	}
+}
+
+// Concat 将 schema 相同的多批记录拼接为一批，只有一批时直接返回（增加引用计数）
+func Concat(schema *arrow.Schema, records []arrow.Record, mem memory.Allocator) (arrow.Record, error) {
+	if len(records) == 1 {
+		records[0].Retain()
+		return records[0], nil
+	}
+	columns := make([]arrow.Array, schema.NumFields())
+	defer func() {
+		for _, column := range columns {
+			if column != nil {
+				column.Release()
+			}
+		}
+	}()
+	var rows int64
+	for _, record := range records {
+		rows += record.NumRows()
+	}
+	parts := make([]arrow.Array, len(records))
+	for i := range columns {
+		for j, record := range records {
+			parts[j] = record.Column(i)
+		}
+		column, err := array.Concatenate(parts, mem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to concatenate column %s: %v", schema.Field(i).Name, err)
+		}
+		columns[i] = column
+	}
+	return array.NewRecord(schema, columns, rows), nil
+}
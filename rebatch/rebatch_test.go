@@ -0,0 +1,324 @@
+package rebatch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/apache/arrow/go/v15/arrow/util"
+)
+
+var (
+	ints   = arrow.NewSchema([]arrow.Field{{Name: "n", Type: arrow.PrimitiveTypes.Int64}}, nil)
+	floats = arrow.NewSchema([]arrow.Field{{Name: "n", Type: arrow.PrimitiveTypes.Float64}}, nil)
+)
+
+// records 按顺序返回内存中的记录，最后返回 err
+type records struct {
+	list     []arrow.Record
+	i        int
+	err      error
+	released bool
+}
+
+func (r *records) Next() bool {
+	r.i++
+	return r.i <= len(r.list)
+}
+
+func (r *records) Record() arrow.Record { return r.list[r.i-1] }
+
+func (r *records) Err() error {
+	if r.i > len(r.list) {
+		return r.err
+	}
+	return nil
+}
+
+func (r *records) Release() {
+	if r.released {
+		panic("released twice")
+	}
+	r.released = true
+	for _, record := range r.list {
+		record.Release()
+	}
+}
+
+// numbered 生成各批次的记录，数值从 0 开始连续递增；schemas 为空时都使用 ints
+func numbered(mem memory.Allocator, sizes []int, schemas ...*arrow.Schema) *records {
+	src := &records{}
+	n := 0
+	for i, size := range sizes {
+		schema := ints
+		if len(schemas) > 0 {
+			schema = schemas[i]
+		}
+		builder := array.NewRecordBuilder(mem, schema)
+		for j := 0; j < size; j++ {
+			switch b := builder.Field(0).(type) {
+			case *array.Int64Builder:
+				b.Append(int64(n))
+			case *array.Float64Builder:
+				b.Append(float64(n))
+			}
+			n++
+		}
+		src.list = append(src.list, builder.NewRecord())
+		builder.Release()
+	}
+	return src
+}
+
+// values 返回记录第一列的数值
+func values(record arrow.Record) []int64 {
+	var out []int64
+	switch column := record.Column(0).(type) {
+	case *array.Int64:
+		out = append(out, column.Int64Values()...)
+	case *array.Float64:
+		for _, v := range column.Float64Values() {
+			out = append(out, int64(v))
+		}
+	}
+	return out
+}
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		sizes   []int
+		schemas []*arrow.Schema
+		opts    Options
+		perRow  int64 // 不为 0 时 TargetBytes 取 perRow 行的估算大小
+		batches []int64
+	}{
+		{name: "merge small batches", sizes: []int{2, 2, 2, 2}, opts: Options{TargetRows: 3}, batches: []int64{3, 3, 2}},
+		{name: "split a large batch", sizes: []int{10}, opts: Options{TargetRows: 4}, batches: []int64{4, 4, 2}},
+		{name: "exact batches pass through", sizes: []int{3, 3}, opts: Options{TargetRows: 3}, batches: []int64{3, 3}},
+		{name: "empty batches are skipped", sizes: []int{1, 0, 7, 0, 1}, opts: Options{TargetRows: 3}, batches: []int64{3, 3, 3}},
+		{name: "no input", opts: Options{TargetRows: 3}},
+		{name: "only empty batches", sizes: []int{0, 0}, opts: Options{TargetRows: 3}},
+		{name: "target bytes", sizes: []int{5, 5}, perRow: 4, batches: []int64{4, 4, 2}},
+		{name: "rows reached before bytes", sizes: []int{5, 5}, opts: Options{TargetRows: 3, TargetBytes: 1 << 20}, batches: []int64{3, 3, 3, 1}},
+		{name: "default target merges everything", sizes: []int{5, 5, 5}, batches: []int64{15}},
+		{
+			name:    "schema change flushes",
+			sizes:   []int{2, 2, 2, 2},
+			schemas: []*arrow.Schema{ints, floats, floats, ints},
+			opts:    Options{TargetRows: 10},
+			batches: []int64{2, 4, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+			defer mem.AssertSize(t, 0)
+			src := numbered(mem, tt.sizes, tt.schemas...)
+			tt.opts.Allocator = mem
+			if tt.perRow > 0 {
+				first := src.list[0]
+				rowBytes := float64(util.TotalRecordSize(first)) / float64(first.NumRows())
+				tt.opts.TargetBytes = int64(math.Ceil(float64(tt.perRow) * rowBytes))
+			}
+			reader := New(src, tt.opts)
+			var batches []int64
+			var got []int64
+			var prev *arrow.Schema
+			for reader.Next() {
+				record := reader.Record()
+				batches = append(batches, record.NumRows())
+				got = append(got, values(record)...)
+				if tt.schemas != nil && prev != nil && prev.Equal(record.Schema()) {
+					t.Errorf("batch %d was not merged into the previous batch with the same schema", len(batches))
+				}
+				prev = record.Schema()
+			}
+			if err := reader.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !src.released {
+				t.Error("src not released at the end")
+			}
+			reader.Release()
+
+			if !reflect.DeepEqual(batches, tt.batches) {
+				t.Errorf("batches = %v, want %v", batches, tt.batches)
+			}
+			var rows int64
+			want := []int64(nil)
+			for _, size := range tt.sizes {
+				for i := 0; i < size; i++ {
+					want = append(want, rows)
+					rows++
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("values = %v, want %v", got, want)
+			}
+			if reader.InputBatches() != len(tt.sizes) || reader.InputRows() != rows {
+				t.Errorf("read %d batches with %d rows, want %d with %d", reader.InputBatches(), reader.InputRows(), len(tt.sizes), rows)
+			}
+		})
+	}
+}
+
+func TestReaderEarlyRelease(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	src := numbered(mem, []int{5, 5})
+	reader := New(src, Options{TargetRows: 3, Allocator: mem})
+	if !reader.Next() {
+		t.Fatal(reader.Err())
+	}
+	reader.Release()
+	if !src.released {
+		t.Error("src not released")
+	}
+	if reader.Next() {
+		t.Error("Next after Release returned true")
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	failure := errors.New("stream reset")
+	src := numbered(mem, []int{2, 2})
+	src.err = failure
+	reader := New(src, Options{TargetRows: 3, Allocator: mem})
+	defer reader.Release()
+	var rows int64
+	for reader.Next() {
+		rows += reader.Record().NumRows()
+	}
+	if !errors.Is(reader.Err(), failure) {
+		t.Errorf("Err = %v, want %v", reader.Err(), failure)
+	}
+	// 出错时不输出缓存中不完整的批次
+	if rows != 3 {
+		t.Errorf("read %d rows before the error, want 3", rows)
+	}
+}
+
+func TestConcat(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	src := numbered(mem, []int{2, 0, 3})
+	defer src.Release()
+
+	record, err := Concat(ints, src.list, mem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := values(record); record.NumRows() != 5 || !reflect.DeepEqual(got, []int64{0, 1, 2, 3, 4}) {
+		t.Errorf("Concat = %v with %d rows", got, record.NumRows())
+	}
+	record.Release()
+
+	single, err := Concat(ints, src.list[:1], mem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if single != src.list[0] {
+		t.Error("Concat of a single record should return it")
+	}
+	single.Release()
+}
+
+func TestSplit(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	src := numbered(mem, []int{1000})
+	defer src.Release()
+	record := src.list[0]
+
+	tests := []struct {
+		name     string
+		maxBytes int
+		chunks   int // 0 表示拆分为不止一段
+		err      bool
+	}{
+		{name: "fits", maxBytes: 1 << 20, chunks: 1},
+		{name: "split", maxBytes: 2000},
+		{name: "a single row too large", maxBytes: 10, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rows int64
+			var got []int64
+			chunks := 0
+			err := Split(record, tt.maxBytes, func(n int64, chunk []byte) error {
+				if len(chunk) > tt.maxBytes {
+					t.Errorf("chunk of %d bytes exceeds %d", len(chunk), tt.maxBytes)
+				}
+				reader, err := ipc.NewReader(bytes.NewReader(chunk), ipc.WithAllocator(mem))
+				if err != nil {
+					return err
+				}
+				defer reader.Release()
+				for reader.Next() {
+					got = append(got, values(reader.Record())...)
+				}
+				rows += n
+				chunks++
+				return reader.Err()
+			})
+			if tt.err {
+				if err == nil {
+					t.Error("Split: want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rows != 1000 || int64(len(got)) != rows || got[0] != 0 || got[len(got)-1] != 999 {
+				t.Errorf("Split emitted %d rows, decoded %d", rows, len(got))
+			}
+			if (tt.chunks > 0 && chunks != tt.chunks) || (tt.chunks == 0 && chunks < 2) {
+				t.Errorf("Split emitted %d chunks", chunks)
+			}
+		})
+	}
+}
+
+func TestCopy(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	src := numbered(mem, []int{3, 0, 4, 3})
+	var chunks [][]byte
+	result, err := Copy(context.Background(), src, func(chunk []byte) error {
+		chunks = append(chunks, chunk)
+		return nil
+	}, CopyOptions{Options: Options{TargetRows: 4, Allocator: mem}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !src.released {
+		t.Error("src not released")
+	}
+	want := CopyResult{InputBatches: 4, Batches: 3, Rows: 10, MinBatchRows: 2, MaxBatchRows: 4}
+	got := *result
+	got.Bytes = 0
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Copy = %+v, want %+v", got, want)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("wrote %d chunks, want 3", len(chunks))
+	}
+
+	failure := errors.New("disk full")
+	src = numbered(mem, []int{3, 3})
+	result, err = Copy(context.Background(), src, func([]byte) error { return failure }, CopyOptions{Options: Options{Allocator: mem}})
+	if !errors.Is(err, failure) || result.Batches != 0 {
+		t.Errorf("Copy = %+v, %v, want %v", result, err, failure)
+	}
+}
@@ -0,0 +1,105 @@
+package sink
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/shopspring/decimal"
+	"io"
+	"strconv"
+	"test/utils"
+)
+
+// CSVOptions CSV 导出参数
+type CSVOptions struct {
+	Delimiter rune   // 分隔符，默认逗号
+	NoHeader  bool   // 不输出表头
+	NullValue string // null 的输出内容，默认为空字符串
+}
+
+// CSVSink 以 CSV 格式写入记录，第一次写入时输出表头，空批次也会输出表头
+type CSVSink struct {
+	writer     *csv.Writer
+	opts       CSVOptions
+	wroteFirst bool
+	row        []string
+}
+
+// NewCSV 创建 CSV Sink
+func NewCSV(w io.Writer, opts CSVOptions) *CSVSink {
+	writer := csv.NewWriter(w)
+	if opts.Delimiter != 0 {
+		writer.Comma = opts.Delimiter
+	}
+	return &CSVSink{writer: writer, opts: opts}
+}
+
+func (s *CSVSink) Write(record arrow.Record) error {
+	numCols := int(record.NumCols())
+	if !s.wroteFirst {
+		s.wroteFirst = true
+		if !s.opts.NoHeader {
+			header := make([]string, numCols)
+			for i := range header {
+				header[i] = record.ColumnName(i)
+			}
+			if err := s.writer.Write(header); err != nil {
+				return fmt.Errorf("failed to write CSV header: %v", err)
+			}
+		}
+	}
+
+	if cap(s.row) < numCols {
+		s.row = make([]string, numCols)
+	}
+	row := s.row[:numCols]
+	for rowIdx := 0; rowIdx < int(record.NumRows()); rowIdx++ {
+		for colIdx := 0; colIdx < numCols; colIdx++ {
+			value, err := utils.ValueAt(record.Column(colIdx), rowIdx)
+			if err != nil {
+				return fmt.Errorf("column %s: %v", record.ColumnName(colIdx), err)
+			}
+			if row[colIdx], err = s.format(value); err != nil {
+				return fmt.Errorf("column %s: %v", record.ColumnName(colIdx), err)
+			}
+		}
+		if err := s.writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV row: %v", err)
+		}
+	}
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+// format 将单元格的值格式化为字符串，嵌套类型输出为 JSON
+func (s *CSVSink) format(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return s.opts.NullValue, nil
+	case string:
+		return v, nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v), nil
+	case decimal.Decimal:
+		return decimalString(v), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(jsonValue(v))
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func (s *CSVSink) Close() error {
+	s.writer.Flush()
+	return s.writer.Error()
+}
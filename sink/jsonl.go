@@ -0,0 +1,109 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/shopspring/decimal"
+	"io"
+	"math"
+	"test/utils"
+)
+
+// JSONLSink 以 JSON Lines 格式写入记录，每行一个按列顺序输出的 JSON 对象
+//
+// decimal 输出为字符串以保证精度，binary 输出为 base64，NaN/Inf 输出为字符串。
+type JSONLSink struct {
+	writer *bufio.Writer
+	keys   [][]byte
+	schema *arrow.Schema
+}
+
+// NewJSONL 创建 JSON Lines Sink
+func NewJSONL(w io.Writer) *JSONLSink {
+	return &JSONLSink{writer: bufio.NewWriter(w)}
+}
+
+func (s *JSONLSink) Write(record arrow.Record) error {
+	if s.schema == nil || !s.schema.Equal(record.Schema()) {
+		s.schema = record.Schema()
+		s.keys = make([][]byte, record.NumCols())
+		for i := range s.keys {
+			key, err := json.Marshal(record.ColumnName(i))
+			if err != nil {
+				return err
+			}
+			s.keys[i] = key
+		}
+	}
+
+	for rowIdx := 0; rowIdx < int(record.NumRows()); rowIdx++ {
+		s.writer.WriteByte('{')
+		for colIdx := 0; colIdx < int(record.NumCols()); colIdx++ {
+			if colIdx > 0 {
+				s.writer.WriteByte(',')
+			}
+			value, err := utils.ValueAt(record.Column(colIdx), rowIdx)
+			if err != nil {
+				return fmt.Errorf("column %s: %v", record.ColumnName(colIdx), err)
+			}
+			data, err := json.Marshal(jsonValue(value))
+			if err != nil {
+				return fmt.Errorf("column %s: %v", record.ColumnName(colIdx), err)
+			}
+			s.writer.Write(s.keys[colIdx])
+			s.writer.WriteByte(':')
+			s.writer.Write(data)
+		}
+		s.writer.WriteString("}\n")
+	}
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write JSON line: %v", err)
+	}
+	return nil
+}
+
+func (s *JSONLSink) Close() error {
+	return s.writer.Flush()
+}
+
+// jsonValue 处理 encoding/json 无法直接输出的值
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case decimal.Decimal:
+		return decimalString(v)
+	case float32:
+		return jsonFloat(float64(v))
+	case float64:
+		return jsonFloat(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = jsonValue(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = jsonValue(item)
+		}
+		return out
+	}
+	return value
+}
+
+func jsonFloat(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Sprint(f)
+	}
+	return f
+}
+
+// decimalString 按列的 scale 输出 decimal，保留末尾的 0
+func decimalString(d decimal.Decimal) string {
+	if exp := d.Exponent(); exp < 0 {
+		return d.StringFixed(-exp)
+	}
+	return d.String()
+}
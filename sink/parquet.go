@@ -0,0 +1,60 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/parquet"
+	"github.com/apache/arrow/go/v15/parquet/compress"
+	"github.com/apache/arrow/go/v15/parquet/pqarrow"
+	"io"
+)
+
+// ParquetOptions Parquet 导出参数
+type ParquetOptions struct {
+	// Compression 压缩方式，nil 时使用 snappy，
+	// 不压缩需要显式指定 compress.Codecs.Uncompressed
+	Compression *compress.Compression
+}
+
+// ParquetSink 以 Parquet 格式写入记录，schema 由第一批（可以是空批次）记录确定，每批非空记录写为一个 row group
+type ParquetSink struct {
+	w      io.Writer
+	opts   ParquetOptions
+	writer *pqarrow.FileWriter
+}
+
+// NewParquet 创建 Parquet Sink
+func NewParquet(w io.Writer, opts ParquetOptions) *ParquetSink {
+	if opts.Compression == nil {
+		codec := compress.Codecs.Snappy
+		opts.Compression = &codec
+	}
+	return &ParquetSink{w: w, opts: opts}
+}
+
+func (s *ParquetSink) Write(record arrow.Record) error {
+	if s.writer == nil {
+		props := parquet.NewWriterProperties(parquet.WithCompression(*s.opts.Compression))
+		// 包装一层，避免 Parquet writer 在 Close 时关闭调用方的 io.Writer
+		writer, err := pqarrow.NewFileWriter(record.Schema(), struct{ io.Writer }{s.w}, props, pqarrow.DefaultWriterProps())
+		if err != nil {
+			return fmt.Errorf("failed to create Parquet writer: %v", err)
+		}
+		s.writer = writer
+	}
+	if record.NumRows() == 0 {
+		return nil
+	}
+	if err := s.writer.Write(record); err != nil {
+		return fmt.Errorf("failed to write Parquet row group: %v", err)
+	}
+	return nil
+}
+
+func (s *ParquetSink) Close() error {
+	if s.writer == nil {
+		return errors.New("no records or schema written, Parquet file has no schema")
+	}
+	return s.writer.Close()
+}
@@ -0,0 +1,132 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/12
+	@note: 将读取到的记录逐批导出为 CSV、JSON Lines 或 Parquet 文件
+
+*
+*/
+package sink
+
+import (
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"os"
+	"path/filepath"
+	"strings"
+	"test/iterator"
+)
+
+// Sink 逐批写入记录，所有记录写完后需要调用 Close
+type Sink interface {
+	Write(record arrow.Record) error
+	Close() error
+}
+
+// Format 导出格式
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatJSONL   Format = "jsonl"
+	FormatParquet Format = "parquet"
+)
+
+// ParseFormat 解析格式名称
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "csv", "tsv":
+		return FormatCSV, nil
+	case "jsonl", "ndjson", "json":
+		return FormatJSONL, nil
+	case "parquet", "pq":
+		return FormatParquet, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected csv/jsonl/parquet", name)
+}
+
+// FormatFromPath 根据文件扩展名推断格式
+func FormatFromPath(path string) (Format, error) {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if ext == "" {
+		return "", fmt.Errorf("cannot infer format of %s, please specify it explicitly", path)
+	}
+	return ParseFormat(ext)
+}
+
+// Options 创建 Sink 的参数
+type Options struct {
+	CSV     CSVOptions
+	Parquet ParquetOptions
+}
+
+// Create 创建文件并返回对应格式的 Sink，Close 时会关闭文件
+func Create(path string, format Format, opts Options) (Sink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %v", err)
+	}
+
+	var s Sink
+	switch format {
+	case FormatCSV:
+		if opts.CSV.Delimiter == 0 && strings.EqualFold(filepath.Ext(path), ".tsv") {
+			opts.CSV.Delimiter = '\t'
+		}
+		s = NewCSV(file, opts.CSV)
+	case FormatJSONL:
+		s = NewJSONL(file)
+	case FormatParquet:
+		s = NewParquet(file, opts.Parquet)
+	default:
+		file.Close()
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return &fileSink{Sink: s, file: file}, nil
+}
+
+// fileSink 关闭 Sink 后同时关闭文件，Sink 关闭失败时删除文件，避免留下不完整的文件
+type fileSink struct {
+	Sink
+	file *os.File
+}
+
+func (f *fileSink) Close() error {
+	err := f.Sink.Close()
+	if cerr := f.file.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("failed to close file: %v", cerr)
+	}
+	if err != nil {
+		os.Remove(f.file.Name())
+	}
+	return err
+}
+
+// Drain 将迭代器中的所有记录写入 Sink，返回写入的行数；不会关闭 Sink，也不会释放迭代器，由调用方负责。
+// 没有任何记录时按 iterator.SchemaOf 写入一个空批次，CSV 仍输出表头，Parquet 仍输出带 schema 的空文件
+func Drain(it iterator.Records, s Sink) (int64, error) {
+	var rows int64
+	wrote := false
+	for it.Next() {
+		record := it.Record()
+		if err := s.Write(record); err != nil {
+			return rows, err
+		}
+		rows += record.NumRows()
+		wrote = true
+	}
+	if err := it.Err(); err != nil || wrote {
+		return rows, err
+	}
+	if schema := iterator.SchemaOf(it); schema != nil {
+		builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+		defer builder.Release()
+		empty := builder.NewRecord()
+		defer empty.Release()
+		return 0, s.Write(empty)
+	}
+	return 0, nil
+}
@@ -0,0 +1,122 @@
+/*
+*
+
+	@author: shiliang
+	@date: 2024/12/27
+	@note: 以目录形式保存的本地 JSON 状态，供作业、上传等需要断点恢复的功能共用
+
+*
+*/
+package statedir
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dir 以目录形式保存 JSON 状态，每个 key 一个文件
+type Dir[T any] struct {
+	root string
+	kind string // 错误信息中的状态名称，如 "job state"
+}
+
+// Default 默认的状态目录 ~/.mira/<name>，无法获取用户目录时使用当前目录下的 .mira/<name>
+func Default(name string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".mira", name)
+	}
+	return filepath.Join(home, ".mira", name)
+}
+
+// New 创建状态目录，root 为空时使用 Default(name)
+func New[T any](root, name, kind string) *Dir[T] {
+	if root == "" {
+		root = Default(name)
+	}
+	return &Dir[T]{root: root, kind: kind}
+}
+
+// Root 返回状态目录
+func (d *Dir[T]) Root() string {
+	return d.root
+}
+
+// Path 返回 key 对应的文件路径，key 中的路径分隔符替换为 _
+func (d *Dir[T]) Path(key string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(key)
+	return filepath.Join(d.root, name+".json")
+}
+
+// Save 写入状态，先写临时文件再重命名，避免中断时留下不完整的文件
+func (d *Dir[T]) Save(key string, v *T) error {
+	if err := os.MkdirAll(d.root, 0o755); err != nil {
+		return fmt.Errorf("failed to create state dir: %v", err)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %v", d.kind, err)
+	}
+	path := d.Path(key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", d.kind, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write %s: %v", d.kind, err)
+	}
+	return nil
+}
+
+// Load 读取状态，文件不存在时返回的错误满足 errors.Is(err, os.ErrNotExist)
+func (d *Dir[T]) Load(key string) (*T, error) {
+	data, err := os.ReadFile(d.Path(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", d.kind, err)
+	}
+	v := new(T)
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", d.kind, err)
+	}
+	return v, nil
+}
+
+// List 按文件名顺序读取所有状态，目录不存在时返回空
+func (d *Dir[T]) List() ([]*T, error) {
+	entries, err := os.ReadDir(d.root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list state dir: %v", err)
+	}
+	var values []*T
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(d.root, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", d.kind, err)
+		}
+		v := new(T)
+		if err := json.Unmarshal(data, v); err != nil {
+			return nil, fmt.Errorf("failed to parse %s %s: %v", d.kind, entry.Name(), err)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// Remove 删除状态，文件不存在时不报错
+func (d *Dir[T]) Remove(key string) error {
+	err := os.Remove(d.Path(key))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %v", d.kind, err)
+	}
+	return nil
+}
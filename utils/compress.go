@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/dustin/go-humanize"
+	"strings"
+)
+
+// Compression IPC 消息体的压缩方式，读取时 ipc.Reader 会根据消息头自动解压
+type Compression int
+
+const (
+	CompressionNone Compression = iota
+	CompressionLZ4              // LZ4_FRAME
+	CompressionZstd             // ZSTD
+)
+
+// ParseCompression 解析 none、lz4（lz4_frame）、zstd
+func ParseCompression(name string) (Compression, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return CompressionNone, nil
+	case "lz4", "lz4_frame", "lz4-frame":
+		return CompressionLZ4, nil
+	case "zstd":
+		return CompressionZstd, nil
+	}
+	return CompressionNone, fmt.Errorf("unknown compression %q, expected none, lz4 or zstd", name)
+}
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionLZ4:
+		return "lz4_frame"
+	case CompressionZstd:
+		return "zstd"
+	}
+	return "none"
+}
+
+// IPCOptions 返回 ipc.NewWriter/ipc.NewFileWriter 的压缩选项
+func (c Compression) IPCOptions() []ipc.Option {
+	switch c {
+	case CompressionLZ4:
+		return []ipc.Option{ipc.WithLZ4()}
+	case CompressionZstd:
+		return []ipc.Option{ipc.WithZstd()}
+	}
+	return nil
+}
+
+// CompressionStats 统计压缩前后的 IPC 字节数，非并发安全
+type CompressionStats struct {
+	Batches int64
+	Raw     int64 // 不压缩时的字节数
+	Encoded int64 // 实际写入的字节数
+}
+
+// Add 记录一个批次不压缩时序列化的字节数和实际写入的字节数
+func (s *CompressionStats) Add(raw, encoded int64) {
+	s.Batches++
+	s.Raw += raw
+	s.Encoded += encoded
+}
+
+// AddRecord 计算记录不压缩时序列化的字节数并与实际写入的字节数一起记录
+func (s *CompressionStats) AddRecord(record arrow.Record, encoded int64) error {
+	raw, err := EncodedSize(record)
+	if err != nil {
+		return err
+	}
+	s.Add(raw, encoded)
+	return nil
+}
+
+// Merge 合并另一组统计
+func (s *CompressionStats) Merge(other CompressionStats) {
+	s.Batches += other.Batches
+	s.Raw += other.Raw
+	s.Encoded += other.Encoded
+}
+
+// Ratio 压缩比，即不压缩的字节数与实际字节数之比
+func (s CompressionStats) Ratio() float64 {
+	if s.Encoded == 0 {
+		return 1
+	}
+	return float64(s.Raw) / float64(s.Encoded)
+}
+
+func (s CompressionStats) String() string {
+	return fmt.Sprintf("%s -> %s (%.2fx) in %d batches",
+		humanize.IBytes(uint64(s.Raw)), humanize.IBytes(uint64(s.Encoded)), s.Ratio(), s.Batches)
+}
+
+// EncodedSize 返回记录不压缩时序列化为 IPC stream 的字节数，只统计长度不复制数据
+func EncodedSize(record arrow.Record) (int64, error) {
+	var counter byteCounter
+	writer := ipc.NewWriter(&counter, ipc.WithSchema(record.Schema()))
+	if err := writer.Write(record); err != nil {
+		writer.Close()
+		return 0, fmt.Errorf("failed to write record to IPC: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close IPC writer: %v", err)
+	}
+	return int64(counter), nil
+}
+
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,415 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/shopspring/decimal"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	decimalType  = reflect.TypeOf(decimal.Decimal{})
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	bytesType    = reflect.TypeOf([]byte(nil))
+)
+
+// DecodeRecords 将 Arrow Record 解码为结构体切片
+//
+// 列与字段通过 `arrow:"name"` tag 对应，没有 tag 的字段按字段名（不区分大小写）匹配，
+// 匹配不到的字段保持零值；显式指定了列名但 Record 中不存在该列时返回错误。
+// 可以为 null 的列需要使用指针、切片、map 或 interface{} 类型的字段。
+// Decimal128/Decimal256 可解码为 decimal.Decimal，timestamp/date 可解码为 time.Time，
+// list、struct、map 分别对应切片、结构体和 map。
+func DecodeRecords[T any](record arrow.Record) ([]T, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("DecodeRecords: %v is not a struct type", t)
+	}
+
+	d := &decoder{plans: map[planKey][]fieldPlan{}}
+	columns := make([]arrow.Field, record.NumCols())
+	for i := range columns {
+		columns[i] = record.Schema().Field(i)
+	}
+	plan, err := d.plan(t, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	numRows := int(record.NumRows())
+	out := make([]T, numRows)
+	for rowIdx := 0; rowIdx < numRows; rowIdx++ {
+		dst := reflect.ValueOf(&out[rowIdx]).Elem()
+		for _, f := range plan {
+			if err := d.decodeValue(record.Column(f.column), rowIdx, dst.FieldByIndex(f.index)); err != nil {
+				return nil, fmt.Errorf("row %d, column %s -> field %s: %v", rowIdx, f.columnName, f.fieldName, err)
+			}
+		}
+	}
+	return out, nil
+}
+
+// fieldPlan 结构体字段与列的对应关系
+type fieldPlan struct {
+	index      []int
+	column     int
+	fieldName  string
+	columnName string
+}
+
+type planKey struct {
+	goType      reflect.Type
+	fingerprint string
+}
+
+// decoder 在一次解码中缓存嵌套结构体的字段映射
+type decoder struct {
+	plans map[planKey][]fieldPlan
+}
+
+// plan 计算结构体字段与列的对应关系
+func (d *decoder) plan(t reflect.Type, columns []arrow.Field) ([]fieldPlan, error) {
+	byName := make(map[string]int, len(columns))
+	byLower := make(map[string]int, len(columns))
+	for i, col := range columns {
+		byName[col.Name] = i
+		byLower[strings.ToLower(col.Name)] = i
+	}
+
+	var plan []fieldPlan
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := parseFieldTag(field)
+		if !ok {
+			continue
+		}
+		colIdx, found := byName[tag.name]
+		if !found && !tag.explicit {
+			colIdx, found = byLower[strings.ToLower(tag.name)]
+		}
+		if !found {
+			if tag.explicit {
+				return nil, fmt.Errorf("column %q for field %s.%s not found", tag.name, t.Name(), field.Name)
+			}
+			continue
+		}
+		plan = append(plan, fieldPlan{
+			index:      field.Index,
+			column:     colIdx,
+			fieldName:  field.Name,
+			columnName: columns[colIdx].Name,
+		})
+	}
+	return plan, nil
+}
+
+func (d *decoder) decodeValue(column arrow.Array, i int, dst reflect.Value) error {
+	if column.IsNull(i) {
+		switch dst.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+		return fmt.Errorf("null value cannot be stored in non-pointer type %v", dst.Type())
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(dst.Type().Elem())
+		if err := d.decodeValue(column, i, elem.Elem()); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	case reflect.Interface:
+		v, err := ValueAt(column, i)
+		if err != nil {
+			return err
+		}
+		rv := reflect.ValueOf(v)
+		if !rv.Type().AssignableTo(dst.Type()) {
+			return mismatch(column, dst)
+		}
+		dst.Set(rv)
+		return nil
+	}
+
+	switch c := column.(type) {
+	case *array.Boolean:
+		if dst.Kind() != reflect.Bool {
+			return mismatch(column, dst)
+		}
+		dst.SetBool(c.Value(i))
+		return nil
+	case *array.Int8:
+		return setInt(column, dst, int64(c.Value(i)))
+	case *array.Int16:
+		return setInt(column, dst, int64(c.Value(i)))
+	case *array.Int32:
+		return setInt(column, dst, int64(c.Value(i)))
+	case *array.Int64:
+		return setInt(column, dst, c.Value(i))
+	case *array.Uint8:
+		return setUint(column, dst, uint64(c.Value(i)))
+	case *array.Uint16:
+		return setUint(column, dst, uint64(c.Value(i)))
+	case *array.Uint32:
+		return setUint(column, dst, uint64(c.Value(i)))
+	case *array.Uint64:
+		return setUint(column, dst, c.Value(i))
+	case *array.Float16:
+		return setFloat(column, dst, float64(c.Value(i).Float32()))
+	case *array.Float32:
+		return setFloat(column, dst, float64(c.Value(i)))
+	case *array.Float64:
+		return setFloat(column, dst, c.Value(i))
+	case *array.String:
+		return setString(column, dst, c.Value(i))
+	case *array.LargeString:
+		return setString(column, dst, c.Value(i))
+	case *array.Binary:
+		return setBytes(column, dst, c.Value(i))
+	case *array.LargeBinary:
+		return setBytes(column, dst, c.Value(i))
+	case *array.FixedSizeBinary:
+		return setBytes(column, dst, c.Value(i))
+	case *array.Decimal128, *array.Decimal256:
+		v, err := ValueAt(column, i)
+		if err != nil {
+			return err
+		}
+		return setDecimal(column, dst, v.(decimal.Decimal))
+	case *array.Date32:
+		return setTime(column, dst, c.Value(i).ToTime(), "2006-01-02")
+	case *array.Date64:
+		return setTime(column, dst, c.Value(i).ToTime(), "2006-01-02")
+	case *array.Timestamp:
+		unit := c.DataType().(*arrow.TimestampType).Unit
+		return setTime(column, dst, c.Value(i).ToTime(unit).UTC(), "2006-01-02 "+timeLayout(unit))
+	case *array.Time32:
+		unit := c.DataType().(*arrow.Time32Type).Unit
+		return setTime(column, dst, c.Value(i).ToTime(unit), timeLayout(unit))
+	case *array.Time64:
+		unit := c.DataType().(*arrow.Time64Type).Unit
+		return setTime(column, dst, c.Value(i).ToTime(unit), timeLayout(unit))
+	case *array.Duration:
+		if dst.Type() != durationType {
+			return setInt(column, dst, int64(c.Value(i)))
+		}
+		unit := c.DataType().(*arrow.DurationType).Unit
+		dst.SetInt(int64(time.Duration(c.Value(i)) * unit.Multiplier()))
+		return nil
+	case *array.Map:
+		return d.decodeMap(c, i, dst)
+	case array.ListLike:
+		if dst.Kind() != reflect.Slice || dst.Type() == bytesType {
+			return mismatch(column, dst)
+		}
+		start, end := c.ValueOffsets(i)
+		values := c.ListValues()
+		slice := reflect.MakeSlice(dst.Type(), int(end-start), int(end-start))
+		for j := int(start); j < int(end); j++ {
+			if err := d.decodeValue(values, j, slice.Index(j-int(start))); err != nil {
+				return fmt.Errorf("list element %d: %v", j-int(start), err)
+			}
+		}
+		dst.Set(slice)
+		return nil
+	case *array.Struct:
+		return d.decodeStruct(c, i, dst)
+	case *array.Dictionary:
+		return d.decodeValue(c.Dictionary(), c.GetValueIndex(i), dst)
+	default:
+		return fmt.Errorf("unsupported column type: %v", column.DataType())
+	}
+}
+
+func (d *decoder) decodeStruct(c *array.Struct, i int, dst reflect.Value) error {
+	if dst.Kind() == reflect.Map && dst.Type().Key().Kind() == reflect.String {
+		v, err := ValueAt(c, i)
+		if err != nil {
+			return err
+		}
+		m := reflect.MakeMap(dst.Type())
+		for k, item := range v.(map[string]interface{}) {
+			iv := reflect.ValueOf(item)
+			if item == nil {
+				iv = reflect.Zero(dst.Type().Elem())
+			} else if !iv.Type().AssignableTo(dst.Type().Elem()) {
+				return mismatch(c, dst)
+			}
+			m.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), iv)
+		}
+		dst.Set(m)
+		return nil
+	}
+	if dst.Kind() != reflect.Struct || dst.Type() == timeType || dst.Type() == decimalType {
+		return mismatch(c, dst)
+	}
+
+	structType := c.DataType().(*arrow.StructType)
+	key := planKey{goType: dst.Type(), fingerprint: structType.Fingerprint()}
+	plan, ok := d.plans[key]
+	if !ok {
+		var err error
+		if plan, err = d.plan(dst.Type(), structType.Fields()); err != nil {
+			return err
+		}
+		d.plans[key] = plan
+	}
+	for _, f := range plan {
+		if err := d.decodeValue(c.Field(f.column), i, dst.FieldByIndex(f.index)); err != nil {
+			return fmt.Errorf("field %s: %v", f.fieldName, err)
+		}
+	}
+	return nil
+}
+
+func (d *decoder) decodeMap(c *array.Map, i int, dst reflect.Value) error {
+	if dst.Kind() != reflect.Map {
+		return mismatch(c, dst)
+	}
+	start, end := c.ValueOffsets(i)
+	keys, items := c.Keys(), c.Items()
+	m := reflect.MakeMapWithSize(dst.Type(), int(end-start))
+	for j := int(start); j < int(end); j++ {
+		k := reflect.New(dst.Type().Key()).Elem()
+		if err := d.decodeValue(keys, j, k); err != nil {
+			return fmt.Errorf("map key: %v", err)
+		}
+		v := reflect.New(dst.Type().Elem()).Elem()
+		if err := d.decodeValue(items, j, v); err != nil {
+			return fmt.Errorf("map item: %v", err)
+		}
+		m.SetMapIndex(k, v)
+	}
+	dst.Set(m)
+	return nil
+}
+
+func setInt(column arrow.Array, dst reflect.Value, v int64) error {
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if dst.OverflowInt(v) {
+			return fmt.Errorf("value %d overflows %v", v, dst.Type())
+		}
+		dst.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v < 0 || dst.OverflowUint(uint64(v)) {
+			return fmt.Errorf("value %d overflows %v", v, dst.Type())
+		}
+		dst.SetUint(uint64(v))
+	case reflect.Float32, reflect.Float64:
+		dst.SetFloat(float64(v))
+	default:
+		if dst.Type() == decimalType {
+			dst.Set(reflect.ValueOf(decimal.NewFromInt(v)))
+			return nil
+		}
+		return mismatch(column, dst)
+	}
+	return nil
+}
+
+func setUint(column arrow.Array, dst reflect.Value, v uint64) error {
+	switch dst.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if dst.OverflowUint(v) {
+			return fmt.Errorf("value %d overflows %v", v, dst.Type())
+		}
+		dst.SetUint(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v > 1<<63-1 || dst.OverflowInt(int64(v)) {
+			return fmt.Errorf("value %d overflows %v", v, dst.Type())
+		}
+		dst.SetInt(int64(v))
+	case reflect.Float32, reflect.Float64:
+		dst.SetFloat(float64(v))
+	default:
+		if dst.Type() == decimalType {
+			dst.Set(reflect.ValueOf(decimal.NewFromUint64(v)))
+			return nil
+		}
+		return mismatch(column, dst)
+	}
+	return nil
+}
+
+func setFloat(column arrow.Array, dst reflect.Value, v float64) error {
+	switch dst.Kind() {
+	case reflect.Float32, reflect.Float64:
+		dst.SetFloat(v)
+	default:
+		if dst.Type() == decimalType {
+			dst.Set(reflect.ValueOf(decimal.NewFromFloat(v)))
+			return nil
+		}
+		return mismatch(column, dst)
+	}
+	return nil
+}
+
+func setString(column arrow.Array, dst reflect.Value, v string) error {
+	switch {
+	case dst.Kind() == reflect.String:
+		dst.SetString(v)
+	case dst.Type() == bytesType:
+		dst.SetBytes([]byte(v))
+	default:
+		return mismatch(column, dst)
+	}
+	return nil
+}
+
+func setBytes(column arrow.Array, dst reflect.Value, v []byte) error {
+	switch {
+	case dst.Type() == bytesType:
+		dst.SetBytes(copyBytes(v))
+	case dst.Kind() == reflect.String:
+		dst.SetString(string(v))
+	default:
+		return mismatch(column, dst)
+	}
+	return nil
+}
+
+func setDecimal(column arrow.Array, dst reflect.Value, v decimal.Decimal) error {
+	switch {
+	case dst.Type() == decimalType:
+		dst.Set(reflect.ValueOf(v))
+	case dst.Kind() == reflect.String:
+		dst.SetString(v.String())
+	case dst.Kind() == reflect.Float32 || dst.Kind() == reflect.Float64:
+		f, _ := v.Float64()
+		dst.SetFloat(f)
+	default:
+		return mismatch(column, dst)
+	}
+	return nil
+}
+
+// setTime 写入时间类型，time32/time64 写入 time.Duration 时为距离零点的时长
+func setTime(column arrow.Array, dst reflect.Value, v time.Time, layout string) error {
+	switch {
+	case dst.Type() == timeType:
+		dst.Set(reflect.ValueOf(v))
+	case dst.Type() == durationType:
+		if column.DataType().ID() != arrow.TIME32 && column.DataType().ID() != arrow.TIME64 {
+			return mismatch(column, dst)
+		}
+		dst.SetInt(int64(v.Sub(v.Truncate(24 * time.Hour))))
+	case dst.Kind() == reflect.String:
+		dst.SetString(v.Format(layout))
+	default:
+		return mismatch(column, dst)
+	}
+	return nil
+}
+
+func mismatch(column arrow.Array, dst reflect.Value) error {
+	return fmt.Errorf("cannot decode %v value into %v", column.DataType(), dst.Type())
+}
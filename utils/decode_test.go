@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+)
+
+// recordFromJSON 按 schema 从 JSON 行构建 Record
+func recordFromJSON(t *testing.T, schema *arrow.Schema, rows string) arrow.Record {
+	t.Helper()
+	record, _, err := array.RecordFromJSON(memory.NewGoAllocator(), schema, strings.NewReader(rows))
+	if err != nil {
+		t.Fatalf("build record: %v", err)
+	}
+	t.Cleanup(record.Release)
+	return record
+}
+
+func TestDecodeRecordsTagMapping(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "student_id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "NAME", Type: arrow.BinaryTypes.String},
+		{Name: "score", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+		{Name: "secret", Type: arrow.BinaryTypes.String},
+	}, nil)
+	record := recordFromJSON(t, schema, `[
+		{"student_id": 1, "NAME": "Alice", "score": 90.5, "secret": "x"},
+		{"student_id": 2, "NAME": "Bob", "score": null, "secret": "y"}
+	]`)
+
+	type student struct {
+		ID     int64    `arrow:"student_id"`
+		Name   string   // 无 tag，按字段名不区分大小写匹配
+		Score  *float64 `arrow:"score"`
+		Secret string   `arrow:"-"`
+		Extra  int      // 无 tag 且无对应列，保持零值
+		hidden string
+	}
+	rows, err := DecodeRecords[student](record)
+	if err != nil {
+		t.Fatalf("DecodeRecords: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	if rows[0].ID != 1 || rows[0].Name != "Alice" || rows[0].Score == nil || *rows[0].Score != 90.5 {
+		t.Errorf("row 0 = %+v", rows[0])
+	}
+	if rows[1].ID != 2 || rows[1].Name != "Bob" || rows[1].Score != nil {
+		t.Errorf("row 1 = %+v", rows[1])
+	}
+	for i, row := range rows {
+		if row.Secret != "" || row.Extra != 0 || row.hidden != "" {
+			t.Errorf("row %d: ignored fields were set: %+v", i, row)
+		}
+	}
+}
+
+func TestDecodeRecordsErrors(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+	}, nil)
+	record := recordFromJSON(t, schema, `[{"id": 1, "name": "Alice"}, {"id": 300, "name": null}]`)
+
+	tests := []struct {
+		name    string
+		decode  func() error
+		wantErr string
+	}{
+		{
+			"not a struct",
+			func() error { _, err := DecodeRecords[int](record); return err },
+			"DecodeRecords: int is not a struct type",
+		},
+		{
+			"missing column",
+			func() error {
+				type row struct {
+					ID  int64 `arrow:"id"`
+					Age int   `arrow:"age"`
+				}
+				_, err := DecodeRecords[row](record)
+				return err
+			},
+			`column "age" for field row.Age not found`,
+		},
+		{
+			"null into non-pointer",
+			func() error {
+				type row struct {
+					Name string `arrow:"name"`
+				}
+				_, err := DecodeRecords[row](record)
+				return err
+			},
+			"row 1, column name -> field Name: null value cannot be stored in non-pointer type string",
+		},
+		{
+			"type mismatch",
+			func() error {
+				type row struct {
+					Name int `arrow:"name"`
+				}
+				_, err := DecodeRecords[row](record)
+				return err
+			},
+			"row 0, column name -> field Name: cannot decode utf8 value into int",
+		},
+		{
+			"overflow",
+			func() error {
+				type row struct {
+					ID int8 `arrow:"id"`
+				}
+				_, err := DecodeRecords[row](record)
+				return err
+			},
+			"row 1, column id -> field ID: value 300 overflows int8",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.decode()
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
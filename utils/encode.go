@@ -0,0 +1,344 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/decimal128"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 编码时 decimal.Decimal 字段的默认精度
+const (
+	DefaultDecimalPrecision = 38
+	DefaultDecimalScale     = 0
+)
+
+// SchemaOf 根据结构体类型推断 Arrow schema
+//
+// 字段 tag 格式为 `arrow:"name,nullable,precision=10,scale=2,unit=ms,date"`：
+//   - name：列名，为空时使用字段名
+//   - nullable：列可以为 null，指针、切片和 map 类型的字段总是可以为 null
+//   - precision/scale：decimal.Decimal 字段的精度，默认 38 和 0
+//   - unit：time.Time 和 time.Duration 字段的时间单位 s/ms/us/ns，默认分别为 us 和 ns
+//   - date：time.Time 字段编码为 date32 而不是 timestamp
+func SchemaOf[T any]() (*arrow.Schema, error) {
+	fields, err := encodeFieldsOf(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	arrowFields := make([]arrow.Field, len(fields))
+	for i, f := range fields {
+		arrowFields[i] = f.field
+	}
+	return arrow.NewSchema(arrowFields, nil), nil
+}
+
+// BuildRecord 将结构体切片构建为一个 Arrow Record，调用方负责 Release
+func BuildRecord[T any](mem memory.Allocator, rows []T) (arrow.Record, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	fields, err := encodeFieldsOf(t)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := SchemaOf[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	builder := array.NewRecordBuilder(mem, schema)
+	defer builder.Release()
+
+	for rowIdx := range rows {
+		row := reflect.ValueOf(&rows[rowIdx]).Elem()
+		for i, f := range fields {
+			if err := appendValue(builder.Field(i), row.FieldByIndex(f.index)); err != nil {
+				return nil, fmt.Errorf("row %d, field %s: %v", rowIdx, f.field.Name, err)
+			}
+		}
+	}
+	return builder.NewRecord(), nil
+}
+
+// EncodeRecords 将结构体切片序列化为 Arrow IPC stream 格式的字节，
+// 可直接用于 WriterExternalDataRequest/WriterInternalDataRequest 的 ArrowBatch
+func EncodeRecords[T any](rows []T, opts ...ipc.Option) ([]byte, error) {
+	mem := memory.NewGoAllocator()
+	record, err := BuildRecord(mem, rows)
+	if err != nil {
+		return nil, err
+	}
+	defer record.Release()
+	return SerializeRecord(record, append([]ipc.Option{ipc.WithAllocator(mem)}, opts...)...)
+}
+
+// SerializeRecord 将 Record 写为 Arrow IPC stream 格式的字节
+func SerializeRecord(record arrow.Record, opts ...ipc.Option) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := ipc.NewWriter(&buf, append([]ipc.Option{ipc.WithSchema(record.Schema())}, opts...)...)
+	if err := writer.Write(record); err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("failed to write record to IPC: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close IPC writer: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeField 结构体字段与 Arrow 字段的对应关系
+type encodeField struct {
+	index []int
+	field arrow.Field
+}
+
+func encodeFieldsOf(t reflect.Type) ([]encodeField, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%v is not a struct type", t)
+	}
+	var fields []encodeField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := parseFieldTag(field)
+		if !ok {
+			continue
+		}
+		dt, nullable, err := arrowTypeOf(field.Type, tag)
+		if err != nil {
+			return nil, fmt.Errorf("field %s.%s: %v", t.Name(), field.Name, err)
+		}
+		fields = append(fields, encodeField{
+			index: field.Index,
+			field: arrow.Field{Name: tag.name, Type: dt, Nullable: nullable || tag.has("nullable")},
+		})
+	}
+	return fields, nil
+}
+
+// arrowTypeOf 返回 Go 类型对应的 Arrow 类型以及是否可以为 null
+func arrowTypeOf(t reflect.Type, tag fieldTag) (arrow.DataType, bool, error) {
+	switch t {
+	case decimalType:
+		precision, scale := int32(DefaultDecimalPrecision), int32(DefaultDecimalScale)
+		if v, ok := tag.options["precision"]; ok {
+			p, err := strconv.Atoi(v)
+			if err != nil || p < 1 || p > 38 {
+				return nil, false, fmt.Errorf("invalid decimal precision %q", v)
+			}
+			precision = int32(p)
+		}
+		if v, ok := tag.options["scale"]; ok {
+			s, err := strconv.Atoi(v)
+			if err != nil || s < 0 || int32(s) > precision {
+				return nil, false, fmt.Errorf("invalid decimal scale %q", v)
+			}
+			scale = int32(s)
+		}
+		return &arrow.Decimal128Type{Precision: precision, Scale: scale}, false, nil
+	case timeType:
+		if tag.has("date") {
+			return arrow.FixedWidthTypes.Date32, false, nil
+		}
+		unit, err := parseTimeUnit(tag.options["unit"], arrow.Microsecond)
+		if err != nil {
+			return nil, false, err
+		}
+		return &arrow.TimestampType{Unit: unit, TimeZone: "UTC"}, false, nil
+	case durationType:
+		unit, err := parseTimeUnit(tag.options["unit"], arrow.Nanosecond)
+		if err != nil {
+			return nil, false, err
+		}
+		return &arrow.DurationType{Unit: unit}, false, nil
+	case bytesType:
+		return arrow.BinaryTypes.Binary, true, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		dt, _, err := arrowTypeOf(t.Elem(), tag)
+		return dt, true, err
+	case reflect.Bool:
+		return arrow.FixedWidthTypes.Boolean, false, nil
+	case reflect.Int8:
+		return arrow.PrimitiveTypes.Int8, false, nil
+	case reflect.Int16:
+		return arrow.PrimitiveTypes.Int16, false, nil
+	case reflect.Int32:
+		return arrow.PrimitiveTypes.Int32, false, nil
+	case reflect.Int, reflect.Int64:
+		return arrow.PrimitiveTypes.Int64, false, nil
+	case reflect.Uint8:
+		return arrow.PrimitiveTypes.Uint8, false, nil
+	case reflect.Uint16:
+		return arrow.PrimitiveTypes.Uint16, false, nil
+	case reflect.Uint32:
+		return arrow.PrimitiveTypes.Uint32, false, nil
+	case reflect.Uint, reflect.Uint64:
+		return arrow.PrimitiveTypes.Uint64, false, nil
+	case reflect.Float32:
+		return arrow.PrimitiveTypes.Float32, false, nil
+	case reflect.Float64:
+		return arrow.PrimitiveTypes.Float64, false, nil
+	case reflect.String:
+		return arrow.BinaryTypes.String, false, nil
+	case reflect.Slice, reflect.Array:
+		elem, elemNullable, err := arrowTypeOf(t.Elem(), tag)
+		if err != nil {
+			return nil, false, err
+		}
+		return arrow.ListOfField(arrow.Field{Name: "item", Type: elem, Nullable: elemNullable}), true, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, false, fmt.Errorf("unsupported map key type %v, only string keys are supported", t.Key())
+		}
+		item, _, err := arrowTypeOf(t.Elem(), tag)
+		if err != nil {
+			return nil, false, err
+		}
+		return arrow.MapOf(arrow.BinaryTypes.String, item), true, nil
+	case reflect.Struct:
+		fields, err := encodeFieldsOf(t)
+		if err != nil {
+			return nil, false, err
+		}
+		arrowFields := make([]arrow.Field, len(fields))
+		for i, f := range fields {
+			arrowFields[i] = f.field
+		}
+		return arrow.StructOf(arrowFields...), false, nil
+	}
+	return nil, false, fmt.Errorf("unsupported type %v", t)
+}
+
+func parseTimeUnit(s string, def arrow.TimeUnit) (arrow.TimeUnit, error) {
+	switch s {
+	case "":
+		return def, nil
+	case "s":
+		return arrow.Second, nil
+	case "ms":
+		return arrow.Millisecond, nil
+	case "us":
+		return arrow.Microsecond, nil
+	case "ns":
+		return arrow.Nanosecond, nil
+	}
+	return 0, fmt.Errorf("invalid time unit %q, expected s/ms/us/ns", s)
+}
+
+// appendValue 将 Go 值追加到对应的 Arrow builder
+func appendValue(b array.Builder, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			b.AppendNull()
+			return nil
+		}
+		if v.Kind() == reflect.Ptr {
+			return appendValue(b, v.Elem())
+		}
+	}
+
+	switch builder := b.(type) {
+	case *array.BooleanBuilder:
+		builder.Append(v.Bool())
+	case *array.Int8Builder:
+		builder.Append(int8(v.Int()))
+	case *array.Int16Builder:
+		builder.Append(int16(v.Int()))
+	case *array.Int32Builder:
+		builder.Append(int32(v.Int()))
+	case *array.Int64Builder:
+		builder.Append(v.Int())
+	case *array.Uint8Builder:
+		builder.Append(uint8(v.Uint()))
+	case *array.Uint16Builder:
+		builder.Append(uint16(v.Uint()))
+	case *array.Uint32Builder:
+		builder.Append(uint32(v.Uint()))
+	case *array.Uint64Builder:
+		builder.Append(v.Uint())
+	case *array.Float32Builder:
+		builder.Append(float32(v.Float()))
+	case *array.Float64Builder:
+		builder.Append(v.Float())
+	case *array.StringBuilder:
+		builder.Append(v.String())
+	case *array.BinaryBuilder:
+		builder.Append(v.Bytes())
+	case *array.Decimal128Builder:
+		dt := builder.Type().(*arrow.Decimal128Type)
+		num, err := ToDecimal128(v.Interface().(decimal.Decimal), dt.Precision, dt.Scale)
+		if err != nil {
+			return err
+		}
+		builder.Append(num)
+	case *array.TimestampBuilder:
+		ts, err := arrow.TimestampFromTime(v.Interface().(time.Time), builder.Type().(*arrow.TimestampType).Unit)
+		if err != nil {
+			return err
+		}
+		builder.Append(ts)
+	case *array.Date32Builder:
+		builder.Append(arrow.Date32FromTime(v.Interface().(time.Time)))
+	case *array.DurationBuilder:
+		unit := builder.Type().(*arrow.DurationType).Unit
+		builder.Append(arrow.Duration(time.Duration(v.Int()) / unit.Multiplier()))
+	case *array.MapBuilder:
+		// MapBuilder 需要在 ListBuilder 之前匹配
+		builder.Append(true)
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, key := range keys {
+			if err := appendValue(builder.KeyBuilder(), key); err != nil {
+				return err
+			}
+			if err := appendValue(builder.ItemBuilder(), v.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+	case *array.ListBuilder:
+		builder.Append(true)
+		for i := 0; i < v.Len(); i++ {
+			if err := appendValue(builder.ValueBuilder(), v.Index(i)); err != nil {
+				return fmt.Errorf("list element %d: %v", i, err)
+			}
+		}
+	case *array.StructBuilder:
+		fields, err := encodeFieldsOf(v.Type())
+		if err != nil {
+			return err
+		}
+		builder.Append(true)
+		for i, f := range fields {
+			if err := appendValue(builder.FieldBuilder(i), v.FieldByIndex(f.index)); err != nil {
+				return fmt.Errorf("field %s: %v", f.field.Name, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported builder type %T", b)
+	}
+	return nil
+}
+
+// ToDecimal128 将 decimal.Decimal 按指定精度转换为 Decimal128，小数位超过 scale 或超出精度时返回错误
+func ToDecimal128(d decimal.Decimal, precision, scale int32) (decimal128.Num, error) {
+	shifted := d.Shift(scale)
+	if !shifted.Equal(shifted.Truncate(0)) {
+		return decimal128.Num{}, fmt.Errorf("decimal %s has more than %d fractional digits", d, scale)
+	}
+	bigInt := shifted.BigInt()
+	if len(new(big.Int).Abs(bigInt).String()) > int(precision) {
+		return decimal128.Num{}, fmt.Errorf("decimal %s exceeds precision %d", d, precision)
+	}
+	return decimal128.FromBigInt(bigInt), nil
+}
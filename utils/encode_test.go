@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/shopspring/decimal"
+)
+
+// roundTrip 编码为 IPC stream 后读回并解码
+func roundTrip[T any](t *testing.T, rows []T) ([]T, *arrow.Schema) {
+	t.Helper()
+	data, err := EncodeRecords(rows)
+	if err != nil {
+		t.Fatalf("EncodeRecords: %v", err)
+	}
+	reader, err := ipc.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("read IPC stream: %v", err)
+	}
+	defer reader.Release()
+	if !reader.Next() {
+		t.Fatalf("no record in stream: %v", reader.Err())
+	}
+	decoded, err := DecodeRecords[T](reader.Record())
+	if err != nil {
+		t.Fatalf("DecodeRecords: %v", err)
+	}
+	return decoded, reader.Schema()
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	type address struct {
+		City string `arrow:"city"`
+	}
+	type row struct {
+		ID       int64            `arrow:"id"`
+		Name     *string          `arrow:"name"`
+		Amount   decimal.Decimal  `arrow:"amount,precision=10,scale=2"`
+		Created  time.Time        `arrow:"created,unit=ms"`
+		Birthday time.Time        `arrow:"birthday,date"`
+		Elapsed  time.Duration    `arrow:"elapsed,unit=us"`
+		Tags     []string         `arrow:"tags"`
+		Attrs    map[string]int32 `arrow:"attrs"`
+		Home     *address         `arrow:"home"`
+		Raw      []byte           `arrow:"raw"`
+		Skip     string           `arrow:"-"`
+	}
+	name := "Alice"
+	rows := []row{
+		{
+			ID:       1,
+			Name:     &name,
+			Amount:   decimal.RequireFromString("1234.50"),
+			Created:  time.Date(2024, 12, 5, 1, 2, 3, 456000000, time.UTC),
+			Birthday: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC),
+			Elapsed:  1500 * time.Microsecond,
+			Tags:     []string{"a", "b"},
+			Attrs:    map[string]int32{"x": 1, "y": 2},
+			Home:     &address{City: "Beijing"},
+			Raw:      []byte{1, 2},
+		},
+		{
+			ID:       2,
+			Amount:   decimal.RequireFromString("-0.05"),
+			Created:  time.Date(2024, 12, 6, 0, 0, 0, 0, time.UTC),
+			Birthday: time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	decoded, schema := roundTrip(t, rows)
+
+	wantTypes := map[string]arrow.DataType{
+		"amount":   &arrow.Decimal128Type{Precision: 10, Scale: 2},
+		"created":  &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"},
+		"birthday": arrow.FixedWidthTypes.Date32,
+		"elapsed":  &arrow.DurationType{Unit: arrow.Microsecond},
+	}
+	for colName, want := range wantTypes {
+		fields, ok := schema.FieldsByName(colName)
+		if !ok || !arrow.TypeEqual(fields[0].Type, want) {
+			t.Errorf("column %s type = %v, want %v", colName, fields, want)
+		}
+	}
+	if fields, _ := schema.FieldsByName("name"); len(fields) == 0 || !fields[0].Nullable {
+		t.Errorf("pointer field should be nullable: %v", fields)
+	}
+	if _, ok := schema.FieldsByName("Skip"); ok {
+		t.Errorf("ignored field was encoded")
+	}
+
+	if len(decoded) != len(rows) {
+		t.Fatalf("got %d rows, want %d", len(decoded), len(rows))
+	}
+	for i := range rows {
+		want, got := rows[i], decoded[i]
+		if !got.Amount.Equal(want.Amount) || got.Amount.Exponent() != -2 {
+			t.Errorf("row %d amount = %v (exp %d), want %v", i, got.Amount, got.Amount.Exponent(), want.Amount)
+		}
+		if !got.Created.Equal(want.Created) || !got.Birthday.Equal(want.Birthday) {
+			t.Errorf("row %d times = %v/%v, want %v/%v", i, got.Created, got.Birthday, want.Created, want.Birthday)
+		}
+		got.Amount, want.Amount = decimal.Decimal{}, decimal.Decimal{}
+		got.Created, want.Created = time.Time{}, time.Time{}
+		got.Birthday, want.Birthday = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("row %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestEncodeTimeUnitTruncation(t *testing.T) {
+	type row struct {
+		At time.Time     `arrow:"at,unit=s"`
+		D  time.Duration `arrow:"d,unit=ms"`
+	}
+	decoded, _ := roundTrip(t, []row{{
+		At: time.Date(2024, 12, 5, 1, 2, 3, 999999999, time.UTC),
+		D:  1500*time.Millisecond + 999*time.Microsecond,
+	}})
+	if want := time.Date(2024, 12, 5, 1, 2, 3, 0, time.UTC); !decoded[0].At.Equal(want) {
+		t.Errorf("at = %v, want %v", decoded[0].At, want)
+	}
+	if decoded[0].D != 1500*time.Millisecond {
+		t.Errorf("d = %v, want 1.5s", decoded[0].D)
+	}
+}
+
+func TestEncodeRecordsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		encode  func() error
+		wantErr string
+	}{
+		{
+			"unsupported field type",
+			func() error {
+				type row struct {
+					C chan int `arrow:"c"`
+				}
+				_, err := EncodeRecords([]row{{}})
+				return err
+			},
+			"field row.C: unsupported type chan int",
+		},
+		{
+			"non-string map key",
+			func() error {
+				type row struct {
+					M map[int]string `arrow:"m"`
+				}
+				_, err := EncodeRecords([]row{{}})
+				return err
+			},
+			"field row.M: unsupported map key type int",
+		},
+		{
+			"invalid time unit",
+			func() error {
+				type row struct {
+					At time.Time `arrow:"at,unit=h"`
+				}
+				_, err := EncodeRecords([]row{{}})
+				return err
+			},
+			`field row.At: invalid time unit "h"`,
+		},
+		{
+			"decimal exceeds scale",
+			func() error {
+				type row struct {
+					V decimal.Decimal `arrow:"v,precision=5,scale=1"`
+				}
+				_, err := EncodeRecords([]row{{V: decimal.RequireFromString("1.25")}})
+				return err
+			},
+			"row 0, field v: decimal 1.25 has more than 1 fractional digits",
+		},
+		{
+			"decimal exceeds precision",
+			func() error {
+				type row struct {
+					V decimal.Decimal `arrow:"v,precision=3,scale=1"`
+				}
+				_, err := EncodeRecords([]row{{V: decimal.RequireFromString("123.4")}})
+				return err
+			},
+			"row 0, field v: decimal 123.4 exceeds precision 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.encode()
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want prefix %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,87 +5,189 @@ import (
 	"github.com/apache/arrow/go/v15/arrow"
 	"github.com/apache/arrow/go/v15/arrow/array"
 	"github.com/shopspring/decimal"
-	"log"
 	"time"
 )
 
+// ExtractRowData 从 Arrow Record 中提取每一行的数据，null 值返回 nil
+//
+// 各类型对应的 Go 值：
+//   - 整数、浮点数、bool：对应宽度的 Go 基本类型
+//   - string/large string：string；binary/large binary/fixed size binary：[]byte
+//   - decimal128/decimal256：decimal.Decimal
+//   - date32/date64：YYYY-MM-DD 字符串；time32/time64：HH:MM:SS[.fff] 字符串
+//   - timestamp：按单位格式化的 UTC 时间字符串；duration：time.Duration
+//   - list/large list/fixed size list：[]interface{}；struct：map[string]interface{}
+//   - map：map[string]interface{}，非字符串的 key 使用 fmt.Sprint 转换
+//   - dictionary：字典中对应的值
 func ExtractRowData(record arrow.Record) ([][]interface{}, error) {
-	var rows [][]interface{}
-	numRows := record.NumRows() // 获取行数
+	numRows := int(record.NumRows()) // 获取行数
+	numCols := int(record.NumCols())
+	rows := make([][]interface{}, 0, numRows)
 
-	for rowIdx := int64(0); rowIdx < numRows; rowIdx++ { // 使用 int64
-		rowData := []interface{}{}
-		for colIdx := 0; colIdx < int(record.NumCols()); colIdx++ {
-			column := record.Column(colIdx)
-			var value interface{}
-
-			switch column.DataType().ID() {
-			case arrow.INT32:
-				int32Array := column.(*array.Int32)
-				value = int32Array.Value(int(rowIdx)) // 转换为 int
-			case arrow.INT64:
-				int64Array := column.(*array.Int64)
-				value = int64Array.Value(int(rowIdx)) // 转换为 int64
-			case arrow.STRING:
-				stringArray := column.(*array.String)
-				value = stringArray.Value(int(rowIdx)) // 转换为 string
-			case arrow.FLOAT64:
-				float64Array := column.(*array.Float64)
-				value = float64Array.Value(int(rowIdx)) // 转换为 float64
-			case arrow.DECIMAL128:
-				decimal128Array := column.(*array.Decimal128)
-				decimalValue := decimal128Array.Value(int(rowIdx))
-				scale := decimal128Array.DataType().(*arrow.Decimal128Type).Scale
-				bigInt := decimalValue.BigInt()
+	for rowIdx := 0; rowIdx < numRows; rowIdx++ {
+		rowData := make([]interface{}, numCols)
+		for colIdx := 0; colIdx < numCols; colIdx++ {
+			value, err := ValueAt(record.Column(colIdx), rowIdx)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %v", record.ColumnName(colIdx), err)
+			}
+			rowData[colIdx] = value
+		}
+		rows = append(rows, rowData)
+	}
+	return rows, nil
+}
 
-				// 根据 scale 调整小数点
-				adjustment := decimal.New(1, int32(scale)) // 10^scale
-				dec := decimal.NewFromBigInt(bigInt, 0).Div(adjustment)
+// ValueAt 返回数组中第 i 个元素对应的 Go 值，null 返回 nil
+func ValueAt(column arrow.Array, i int) (interface{}, error) {
+	if column.IsNull(i) {
+		return nil, nil
+	}
 
-				// 调试日志，查看中间值
-				log.Printf("Decimal128 value: bigInt=%s, scale=%d, adjusted=%s", bigInt.String(), scale, dec.String())
+	switch c := column.(type) {
+	case *array.Null:
+		return nil, nil
+	case *array.Boolean:
+		return c.Value(i), nil
+	case *array.Int8:
+		return c.Value(i), nil
+	case *array.Int16:
+		return c.Value(i), nil
+	case *array.Int32:
+		return c.Value(i), nil
+	case *array.Int64:
+		return c.Value(i), nil
+	case *array.Uint8:
+		return c.Value(i), nil
+	case *array.Uint16:
+		return c.Value(i), nil
+	case *array.Uint32:
+		return c.Value(i), nil
+	case *array.Uint64:
+		return c.Value(i), nil
+	case *array.Float16:
+		return c.Value(i).Float32(), nil
+	case *array.Float32:
+		return c.Value(i), nil
+	case *array.Float64:
+		return c.Value(i), nil
+	case *array.String:
+		return c.Value(i), nil
+	case *array.LargeString:
+		return c.Value(i), nil
+	case *array.Binary:
+		return copyBytes(c.Value(i)), nil
+	case *array.LargeBinary:
+		return copyBytes(c.Value(i)), nil
+	case *array.FixedSizeBinary:
+		return copyBytes(c.Value(i)), nil
+	case *array.Decimal128:
+		scale := c.DataType().(*arrow.Decimal128Type).Scale
+		return decimal.NewFromBigInt(c.Value(i).BigInt(), -scale), nil
+	case *array.Decimal256:
+		scale := c.DataType().(*arrow.Decimal256Type).Scale
+		return decimal.NewFromBigInt(c.Value(i).BigInt(), -scale), nil
+	case *array.Date32:
+		return c.Value(i).ToTime().Format("2006-01-02"), nil // 格式化为 YYYY-MM-DD
+	case *array.Date64:
+		return c.Value(i).ToTime().Format("2006-01-02"), nil
+	case *array.Time32:
+		unit := c.DataType().(*arrow.Time32Type).Unit
+		return c.Value(i).ToTime(unit).Format(timeLayout(unit)), nil
+	case *array.Time64:
+		unit := c.DataType().(*arrow.Time64Type).Unit
+		return c.Value(i).ToTime(unit).Format(timeLayout(unit)), nil
+	case *array.Timestamp:
+		unit := c.DataType().(*arrow.TimestampType).Unit
+		return c.Value(i).ToTime(unit).UTC().Format("2006-01-02 " + timeLayout(unit)), nil
+	case *array.Duration:
+		unit := c.DataType().(*arrow.DurationType).Unit
+		return time.Duration(c.Value(i)) * unit.Multiplier(), nil
+	case *array.MonthInterval:
+		return c.Value(i), nil
+	case *array.DayTimeInterval:
+		return c.Value(i), nil
+	case *array.MonthDayNanoInterval:
+		return c.Value(i), nil
+	case *array.Map:
+		// Map 需要在 List 之前匹配，它同时也是一种 list
+		return mapValue(c, i)
+	case array.ListLike:
+		start, end := c.ValueOffsets(i)
+		return listValues(c.ListValues(), int(start), int(end))
+	case *array.Struct:
+		fields := c.DataType().(*arrow.StructType).Fields()
+		value := make(map[string]interface{}, len(fields))
+		for fieldIdx, field := range fields {
+			v, err := ValueAt(c.Field(fieldIdx), i)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %v", field.Name, err)
+			}
+			value[field.Name] = v
+		}
+		return value, nil
+	case *array.Dictionary:
+		return ValueAt(c.Dictionary(), c.GetValueIndex(i))
+	// 可以根据需要添加更多类型的支持
+	default:
+		return nil, fmt.Errorf("unsupported column type: %v", column.DataType())
+	}
+}
 
-				value = dec // 转换为 decimal.Decimal
-			case arrow.DATE32:
-				date32Array := column.(*array.Date32)
-				daysSinceEpoch := date32Array.Value(int(rowIdx))
-				timestamp := time.Unix(int64(daysSinceEpoch)*86400, 0).UTC()
-				formattedDate := timestamp.Format("2006-01-02") // 格式化为 YYYY-MM-DD
-				value = formattedDate
-			case arrow.TIMESTAMP:
-				timestampArray := column.(*array.Timestamp)
-				timestampValue := timestampArray.Value(int(rowIdx))
-				unit := timestampArray.DataType().(*arrow.TimestampType).Unit
+func listValues(values arrow.Array, start, end int) ([]interface{}, error) {
+	list := make([]interface{}, 0, end-start)
+	for j := start; j < end; j++ {
+		v, err := ValueAt(values, j)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
 
-				// 根据时间戳的单位进行转换
-				switch unit {
-				case arrow.Second:
-					timestamp := time.Unix(int64(timestampValue), 0).UTC()
-					formattedTimestamp := timestamp.Format("2006-01-02 15:04:05")
-					value = formattedTimestamp
-				case arrow.Millisecond:
-					timestamp := time.Unix(0, int64(timestampValue*1e6)).UTC()
-					formattedTimestamp := timestamp.Format("2006-01-02 15:04:05.000")
-					value = formattedTimestamp
-				case arrow.Microsecond:
-					timestamp := time.Unix(0, int64(timestampValue*1e3)).UTC()
-					formattedTimestamp := timestamp.Format("2006-01-02 15:04:05.000000")
-					value = formattedTimestamp
-				case arrow.Nanosecond:
-					timestamp := time.Unix(0, int64(timestampValue)).UTC()
-					formattedTimestamp := timestamp.Format("2006-01-02 15:04:05.000000000")
-					value = formattedTimestamp
-				default:
-					return nil, fmt.Errorf("unsupported timestamp unit: %v", unit)
-				}
-			// 可以根据需要添加更多类型的支持
-			default:
-				return nil, fmt.Errorf("unsupported column type: %v", column.DataType().ID())
+func mapValue(c *array.Map, i int) (map[string]interface{}, error) {
+	start, end := c.ValueOffsets(i)
+	keys, items := c.Keys(), c.Items()
+	value := make(map[string]interface{}, end-start)
+	for j := int(start); j < int(end); j++ {
+		k, err := ValueAt(keys, j)
+		if err != nil {
+			return nil, fmt.Errorf("map key: %v", err)
+		}
+		v, err := ValueAt(items, j)
+		if err != nil {
+			return nil, fmt.Errorf("map item: %v", err)
+		}
+		key, ok := k.(string)
+		if !ok {
+			if b, isBytes := k.([]byte); isBytes {
+				key = string(b)
+			} else {
+				key = fmt.Sprint(k)
 			}
-
-			rowData = append(rowData, value)
 		}
-		rows = append(rows, rowData)
+		value[key] = v
 	}
-	return rows, nil
+	return value, nil
+}
+
+// timeLayout 根据时间单位返回时分秒的格式
+func timeLayout(unit arrow.TimeUnit) string {
+	switch unit {
+	case arrow.Millisecond:
+		return "15:04:05.000"
+	case arrow.Microsecond:
+		return "15:04:05.000000"
+	case arrow.Nanosecond:
+		return "15:04:05.000000000"
+	default:
+		return "15:04:05"
+	}
+}
+
+func copyBytes(b []byte) []byte {
+	out := make([]byte, len(b))
+	copy(out, b)
+	return out
 }
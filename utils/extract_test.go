@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/shopspring/decimal"
+)
+
+func TestExtractRowData(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  arrow.DataType
+		json string // 第一个元素的 JSON，第二行总是 null
+		want interface{}
+	}{
+		{"bool", arrow.FixedWidthTypes.Boolean, `true`, true},
+		{"int8", arrow.PrimitiveTypes.Int8, `-8`, int8(-8)},
+		{"int16", arrow.PrimitiveTypes.Int16, `-16`, int16(-16)},
+		{"int32", arrow.PrimitiveTypes.Int32, `-32`, int32(-32)},
+		{"int64", arrow.PrimitiveTypes.Int64, `-64`, int64(-64)},
+		{"uint8", arrow.PrimitiveTypes.Uint8, `8`, uint8(8)},
+		{"uint16", arrow.PrimitiveTypes.Uint16, `16`, uint16(16)},
+		{"uint32", arrow.PrimitiveTypes.Uint32, `32`, uint32(32)},
+		{"uint64", arrow.PrimitiveTypes.Uint64, `64`, uint64(64)},
+		{"float16", arrow.FixedWidthTypes.Float16, `1.5`, float32(1.5)},
+		{"float32", arrow.PrimitiveTypes.Float32, `2.5`, float32(2.5)},
+		{"float64", arrow.PrimitiveTypes.Float64, `3.25`, 3.25},
+		{"string", arrow.BinaryTypes.String, `"Alice"`, "Alice"},
+		{"large string", arrow.BinaryTypes.LargeString, `"Bob"`, "Bob"},
+		{"binary", arrow.BinaryTypes.Binary, `"AQI="`, []byte{1, 2}},
+		{"large binary", arrow.BinaryTypes.LargeBinary, `"AwQ="`, []byte{3, 4}},
+		{"fixed size binary", &arrow.FixedSizeBinaryType{ByteWidth: 2}, `"BQY="`, []byte{5, 6}},
+		{"date32", arrow.FixedWidthTypes.Date32, `"2024-12-05"`, "2024-12-05"},
+		{"date64", arrow.FixedWidthTypes.Date64, `"2024-12-06"`, "2024-12-06"},
+		{"time32 s", arrow.FixedWidthTypes.Time32s, `"12:34:56"`, "12:34:56"},
+		{"time32 ms", arrow.FixedWidthTypes.Time32ms, `"12:34:56.789"`, "12:34:56.789"},
+		{"time64 us", arrow.FixedWidthTypes.Time64us, `"12:34:56.000001"`, "12:34:56.000001"},
+		{"time64 ns", arrow.FixedWidthTypes.Time64ns, `"12:34:56.000000001"`, "12:34:56.000000001"},
+		{"timestamp s", arrow.FixedWidthTypes.Timestamp_s, `"2024-12-05T01:02:03Z"`, "2024-12-05 01:02:03"},
+		{"timestamp ms", arrow.FixedWidthTypes.Timestamp_ms, `"2024-12-05T01:02:03.456Z"`, "2024-12-05 01:02:03.456"},
+		{"duration", arrow.FixedWidthTypes.Duration_ms, `1500`, 1500 * time.Millisecond},
+		{"month interval", arrow.FixedWidthTypes.MonthInterval, `{"months": 3}`, arrow.MonthInterval(3)},
+		{"day time interval", arrow.FixedWidthTypes.DayTimeInterval, `{"days": 1, "milliseconds": 2}`, arrow.DayTimeInterval{Days: 1, Milliseconds: 2}},
+		{"list", arrow.ListOf(arrow.PrimitiveTypes.Int32), `[1, null, 3]`, []interface{}{int32(1), nil, int32(3)}},
+		{"large list", arrow.LargeListOf(arrow.BinaryTypes.String), `["a"]`, []interface{}{"a"}},
+		{"fixed size list", arrow.FixedSizeListOf(2, arrow.PrimitiveTypes.Int64), `[7, 8]`, []interface{}{int64(7), int64(8)}},
+		{
+			"struct",
+			arrow.StructOf(arrow.Field{Name: "a", Type: arrow.PrimitiveTypes.Int32, Nullable: true}, arrow.Field{Name: "b", Type: arrow.BinaryTypes.String, Nullable: true}),
+			`{"a": 1, "b": null}`,
+			map[string]interface{}{"a": int32(1), "b": nil},
+		},
+		{
+			"map",
+			arrow.MapOf(arrow.BinaryTypes.String, arrow.PrimitiveTypes.Int64),
+			`[{"key": "x", "value": 1}, {"key": "y", "value": null}]`,
+			map[string]interface{}{"x": int64(1), "y": nil},
+		},
+		{
+			"map with integer keys",
+			arrow.MapOf(arrow.PrimitiveTypes.Int32, arrow.BinaryTypes.String),
+			`[{"key": 1, "value": "one"}]`,
+			map[string]interface{}{"1": "one"},
+		},
+	}
+
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	fields := make([]arrow.Field, len(tests))
+	columns := make([]arrow.Array, len(tests))
+	for i, tt := range tests {
+		column, _, err := array.FromJSON(mem, tt.typ, strings.NewReader("["+tt.json+", null]"))
+		if err != nil {
+			t.Fatalf("%s: FromJSON: %v", tt.name, err)
+		}
+		defer column.Release()
+		fields[i] = arrow.Field{Name: tt.name, Type: tt.typ, Nullable: true}
+		columns[i] = column
+	}
+	record := array.NewRecord(arrow.NewSchema(fields, nil), columns, 2)
+	defer record.Release()
+
+	rows, err := ExtractRowData(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	for i, tt := range tests {
+		if got := rows[0][i]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v (%T), want %#v (%T)", tt.name, got, got, tt.want, tt.want)
+		}
+		if rows[1][i] != nil {
+			t.Errorf("%s: null row = %#v, want nil", tt.name, rows[1][i])
+		}
+	}
+}
+
+func TestValueAtDecimal(t *testing.T) {
+	tests := []struct {
+		typ  arrow.DataType
+		json string
+		want string
+	}{
+		{&arrow.Decimal128Type{Precision: 10, Scale: 2}, `["123.45", "-0.05", null]`, "123.45"},
+		{&arrow.Decimal128Type{Precision: 38, Scale: 10}, `["12345678901234567890.0123456789"]`, "12345678901234567890.0123456789"},
+		{&arrow.Decimal128Type{Precision: 5, Scale: 0}, `["99999"]`, "99999"},
+		{&arrow.Decimal256Type{Precision: 50, Scale: 3}, `["-1.250"]`, "-1.25"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ.String(), func(t *testing.T) {
+			column, _, err := array.FromJSON(memory.DefaultAllocator, tt.typ, strings.NewReader(tt.json))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer column.Release()
+			v, err := ValueAt(column, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, ok := v.(decimal.Decimal)
+			if !ok {
+				t.Fatalf("got %T, want decimal.Decimal", v)
+			}
+			// 按列的 scale 还原，不经过浮点数
+			if !d.Equal(decimal.RequireFromString(tt.want)) {
+				t.Errorf("got %v, want %v", d, tt.want)
+			}
+			scale := int32(0)
+			switch typ := tt.typ.(type) {
+			case *arrow.Decimal128Type:
+				scale = typ.Scale
+			case *arrow.Decimal256Type:
+				scale = typ.Scale
+			}
+			if d.Exponent() != -scale {
+				t.Errorf("exponent = %d, want %d", d.Exponent(), -scale)
+			}
+			for i := 1; i < column.Len(); i++ {
+				v, err := ValueAt(column, i)
+				if err != nil || (column.IsNull(i) && v != nil) {
+					t.Errorf("row %d = %v, %v", i, v, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValueAtDictionary(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	dictType := &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int8, ValueType: arrow.BinaryTypes.String}
+	builder := array.NewDictionaryBuilder(mem, dictType).(*array.BinaryDictionaryBuilder)
+	defer builder.Release()
+	builder.AppendString("math")
+	builder.AppendString("cs")
+	builder.AppendNull()
+	builder.AppendString("math")
+	column := builder.NewArray()
+	defer column.Release()
+
+	want := []interface{}{"math", "cs", nil, "math"}
+	for i, w := range want {
+		got, err := ValueAt(column, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != w {
+			t.Errorf("row %d = %v, want %v", i, got, w)
+		}
+	}
+}
+
+func TestValueAtNull(t *testing.T) {
+	column := array.NewNull(2)
+	defer column.Release()
+	if v, err := ValueAt(column, 1); v != nil || err != nil {
+		t.Errorf("ValueAt = %v, %v, want nil", v, err)
+	}
+}
+
+func TestValueAtUnsupported(t *testing.T) {
+	column, _, err := array.FromJSON(memory.DefaultAllocator, arrow.SparseUnionOf(
+		[]arrow.Field{{Name: "a", Type: arrow.PrimitiveTypes.Int32}}, []arrow.UnionTypeCode{0}),
+		strings.NewReader(`[[0, 1]]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer column.Release()
+	if _, err := ValueAt(column, 0); err == nil || !strings.Contains(err.Error(), "unsupported column type") {
+		t.Errorf("ValueAt error = %v, want unsupported column type", err)
+	}
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+)
+
+// TagName 结构体字段与 Arrow 列映射使用的 tag 名称
+//
+// 格式为 `arrow:"name,option,key=value"`，name 为空时使用字段名，"-" 表示忽略该字段。
+const TagName = "arrow"
+
+// fieldTag 解析后的结构体字段 tag
+type fieldTag struct {
+	name     string
+	explicit bool // 是否在 tag 中显式指定了列名
+	options  map[string]string
+}
+
+// has 判断 tag 中是否包含某个选项
+func (t fieldTag) has(option string) bool {
+	_, ok := t.options[option]
+	return ok
+}
+
+// parseFieldTag 解析字段的 arrow tag，返回 false 表示该字段应被忽略
+func parseFieldTag(field reflect.StructField) (fieldTag, bool) {
+	if !field.IsExported() {
+		return fieldTag{}, false
+	}
+	raw, _ := field.Tag.Lookup(TagName)
+	if raw == "-" {
+		return fieldTag{}, false
+	}
+
+	parts := strings.Split(raw, ",")
+	tag := fieldTag{name: strings.TrimSpace(parts[0]), options: map[string]string{}}
+	tag.explicit = tag.name != ""
+	if !tag.explicit {
+		tag.name = field.Name
+	}
+	for _, part := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if key != "" {
+			tag.options[key] = value
+		}
+	}
+	return tag, true
+}
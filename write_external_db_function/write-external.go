@@ -10,63 +10,60 @@
 package main
 
 import (
-	"bytes"
-	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
 	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
 	"context"
-	"github.com/apache/arrow/go/v15/arrow"
-	"github.com/apache/arrow/go/v15/arrow/array"
-	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"flag"
 	"github.com/apache/arrow/go/v15/arrow/memory"
 	"log"
+	"test/config"
+	"test/utils"
 )
 
+// student 写入外部表的一行数据
+type student struct {
+	ID   int32  `arrow:"id"`
+	Name string `arrow:"name"`
+}
+
 func main() {
 	ctx := context.Background()
 
-	// 创建一个ServerInfo实例
-	serverInfo := &pb.ServerInfo{
-		//Namespace:   "mira1", // 如果在Kubernetes集群中使用，可以指定命名空间
-		ServiceName: "192.168.40.243",
-		ServicePort: "30015",
+	// 解析连接参数，通过 --profile 切换环境
+	opts := config.RegisterFlags(flag.CommandLine)
+	compressionName := flag.String("compression", "none", "IPC compression: none, lz4 or zstd")
+	flag.Parse()
+	compression, err := utils.ParseCompression(*compressionName)
+	if err != nil {
+		log.Fatal(err)
 	}
-	dataServiceClient, err := client.NewDataServiceClient(ctx, serverInfo)
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
 	if err != nil {
 		log.Fatalf("failed to initialize DataServiceClient: %v", err)
 	}
-	// 创建内存分配器
-	pool := memory.NewGoAllocator()
-
-	// 定义 Arrow 表的 schema
-	schema := arrow.NewSchema([]arrow.Field{
-		{Name: "id", Type: arrow.PrimitiveTypes.Int32, Nullable: false},
-		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: false},
-	}, nil)
-
-	// 创建 RecordBuilder 用于填充数据
-	recordBuilder := array.NewRecordBuilder(pool, schema)
-	defer recordBuilder.Release()
-
-	// 向 RecordBuilder 中添加数据
-	recordBuilder.Field(0).(*array.Int32Builder).AppendValues([]int32{1, 2}, nil)
-	recordBuilder.Field(1).(*array.StringBuilder).AppendValues([]string{"shi", "liang"}, nil)
-
-	// 生成 RecordBatch
-	recordBatch := recordBuilder.NewRecord()
-	defer recordBatch.Release()
-
-	// 使用 IPC 写入器将 RecordBatch 写入到字节缓冲区中
-	var buffer bytes.Buffer
-	writer := ipc.NewWriter(&buffer, ipc.WithSchema(schema))
-	defer writer.Close()
-
-	// 将 RecordBatch 写入 IPC 流
-	if err := writer.Write(recordBatch); err != nil {
-		log.Fatalf("Failed to write RecordBatch to IPC buffer: %v", err)
+	// 待写入的数据，列名和类型由 arrow tag 推断
+	students := []student{
+		{ID: 1, Name: "shi"},
+		{ID: 2, Name: "liang"},
 	}
 
-	// 获取序列化的 Arrow 批次字节数据
-	arrowBatchBytes := buffer.Bytes()
+	// 构建记录并序列化为 Arrow IPC 字节数据
+	record, err := utils.BuildRecord(memory.NewGoAllocator(), students)
+	if err != nil {
+		log.Fatalf("Failed to build record: %v", err)
+	}
+	defer record.Release()
+	arrowBatchBytes, err := utils.SerializeRecord(record, compression.IPCOptions()...)
+	if err != nil {
+		log.Fatalf("Failed to encode records: %v", err)
+	}
+	if compression != utils.CompressionNone {
+		var stats utils.CompressionStats
+		if err := stats.AddRecord(record, int64(len(arrowBatchBytes))); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Compression %s: %v", compression, stats)
+	}
 
 	request := &pb.WriterExternalDataRequest{
 		ArrowBatch:  arrowBatchBytes,
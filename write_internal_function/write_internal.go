@@ -10,61 +10,66 @@
 package main
 
 import (
-	"bytes"
-	client "chainweaver.org.cn/chainweaver/mira/mira-data-service-client"
 	pb "chainweaver.org.cn/chainweaver/mira/mira-data-service-client/proto/datasource"
 	"context"
+	"flag"
 	"fmt"
-	"github.com/apache/arrow/go/v15/arrow"
-	"github.com/apache/arrow/go/v15/arrow/array"
-	"github.com/apache/arrow/go/v15/arrow/ipc"
 	"github.com/apache/arrow/go/v15/arrow/memory"
 	"log"
+	"test/config"
+	"test/utils"
 )
 
+// internalRow 写入内部表的一行数据
+type internalRow struct {
+	ID   *int64  `arrow:"id"`
+	Data *string `arrow:"data"`
+}
+
 func main() {
 	ctx := context.Background()
 
-	// 创建一个ServerInfo实例
-	serverInfo := &pb.ServerInfo{
-		//Namespace:   "mira1", // 如果在Kubernetes集群中使用，可以指定命名空间
-		ServiceName: "192.168.40.243",
-		ServicePort: "30015",
+	// 解析连接参数，通过 --profile 切换环境
+	opts := config.RegisterFlags(flag.CommandLine)
+	compressionName := flag.String("compression", "none", "IPC compression: none, lz4 or zstd")
+	flag.Parse()
+	compression, err := utils.ParseCompression(*compressionName)
+	if err != nil {
+		log.Fatal(err)
 	}
-	dataServiceClient, err := client.NewDataServiceClient(ctx, serverInfo)
+
+	dataServiceClient, err := config.NewClient(ctx, opts)
 	if err != nil {
 		log.Fatalf("failed to initialize DataServiceClient: %v", err)
 	}
 
-	// 创建示例数据
-	schema := arrow.NewSchema(
-		[]arrow.Field{
-			{Name: "id", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
-			{Name: "data", Type: arrow.BinaryTypes.String, Nullable: true},
-		},
-		nil,
-	)
-
-	// 创建一个记录批次
-	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
-	defer builder.Release()
-
-	// 添加示例数据
-	builder.Field(0).(*array.Int64Builder).Append(99999)
-	builder.Field(1).(*array.StringBuilder).Append("example data")
-
-	record := builder.NewRecord()
-	defer record.Release()
+	// 创建示例数据，可为 null 的列使用指针字段
+	id, data := int64(99999), "example data"
+	rows := []internalRow{
+		{ID: &id, Data: &data},
+	}
 
 	// 将记录批次序列化为 Arrow IPC 格式
-	buf, err := serializeRecord(record)
+	record, err := utils.BuildRecord(memory.NewGoAllocator(), rows)
+	if err != nil {
+		log.Fatalf("failed to build record: %v", err)
+	}
+	defer record.Release()
+	buf, err := utils.SerializeRecord(record, compression.IPCOptions()...)
 	if err != nil {
 		log.Fatalf("failed to serialize record: %v", err)
 	}
-	fmt.Printf("Serialized record size: %d bytes\n", buf.Len())
+	fmt.Printf("Serialized record size: %d bytes\n", len(buf))
+	if compression != utils.CompressionNone {
+		var stats utils.CompressionStats
+		if err := stats.AddRecord(record, int64(len(buf))); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Compression %s: %v", compression, stats)
+	}
 
 	request := &pb.WriterInternalDataRequest{
-		ArrowBatch: buf.Bytes(),
+		ArrowBatch: buf,
 		DbName:     "stream_task",
 		TableName:  "defrgt",
 	}
@@ -76,28 +81,3 @@ func main() {
 	response := dataServiceClient.WriteInternalDBData(ctx, requestArray)
 	fmt.Println(response)
 }
-
-func serializeRecord(record arrow.Record) (*bytes.Buffer, error) {
-	// 创建一个字节缓冲区用于存储序列化的 IPC 数据
-	buf := new(bytes.Buffer)
-
-	// 创建 Arrow IPC 文件写入器
-	writer := ipc.NewWriter(buf, ipc.WithSchema(record.Schema()), ipc.WithAllocator(memory.DefaultAllocator))
-	if writer == nil {
-		return nil, fmt.Errorf("failed to create IPC writer")
-	}
-	defer writer.Close()
-
-	// 将记录写入到 IPC 文件
-	if err := writer.Write(record); err != nil {
-		return nil, fmt.Errorf("failed to write record to IPC: %v", err)
-	}
-
-	// 关闭写入器
-	if err := writer.Close(); err != nil {
-		log.Fatalf("Failed to close IPC writer: %v", err)
-	}
-
-	// 返回包含 IPC 数据的字节缓冲区
-	return buf, nil
-}